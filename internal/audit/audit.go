@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+const (
+	ActionAPIKeyStore           = "api_key.store"
+	ActionAPIKeyRotate          = "api_key.rotate"
+	ActionAPIKeyDelete          = "api_key.delete"
+	ActionSnapshotDelete        = "snapshot.delete"
+	ActionSnapshotChangesDelete = "snapshot.changes_delete"
+	ActionSnapshotUnmask        = "snapshot.unmask"
+	ActionCollectionImport      = "collection.import"
+	ActionCollectionUpload      = "collection.upload"
+	ActionCollectionImportAll   = "collection.import_all"
+	ActionEnvironmentImport     = "environment.import"
+	ActionMaskingPolicySave     = "masking_policy.save"
+	ActionMaskingPolicyDelete   = "masking_policy.delete"
+	ActionJobCancel             = "job.cancel"
+	ActionJobRetry              = "job.retry"
+	ActionDeadLetterRequeue     = "dead_letter.requeue"
+	ActionDeadLetterDelete      = "dead_letter.delete"
+)
+
+const (
+	TargetAPIKey        = "api_key"
+	TargetSnapshot      = "snapshot"
+	TargetCollection    = "collection"
+	TargetEnvironment   = "environment"
+	TargetMaskingPolicy = "masking_policy"
+	TargetJob           = "job"
+	TargetTask          = "task"
+)
+
+// Event is what a handler knows about an action; Record adds who performed it and from where.
+type Event struct {
+	Action       string
+	TargetType   string
+	TargetID     string
+	CollectionID string
+	Outcome      string
+	Err          error
+	Details      map[string]interface{}
+}
+
+// Record appends an event to the audit log. A failed write is logged rather than returned so
+// auditing never changes the response of the action it records.
+func Record(c echo.Context, event Event) {
+	record := &db.AuditEvent{
+		Action:       event.Action,
+		TargetType:   event.TargetType,
+		TargetID:     optional(event.TargetID),
+		CollectionID: optional(event.CollectionID),
+		Outcome:      event.Outcome,
+		IPAddress:    optional(c.RealIP()),
+		UserAgent:    optional(c.Request().UserAgent()),
+	}
+	if record.Outcome == "" {
+		record.Outcome = OutcomeSuccess
+	}
+
+	if userID, ok := c.Get("user_id").(int64); ok {
+		record.UserID = &userID
+	}
+	if orgID, ok := c.Get("organization_id").(int64); ok {
+		record.OrganizationID = &orgID
+	}
+	if sessionID, ok := c.Get("session_id").(string); ok {
+		record.SessionID = &sessionID
+	}
+	if tokenID, ok := c.Get("access_token_id").(int64); ok {
+		record.AccessTokenID = &tokenID
+	}
+	if event.Err != nil {
+		msg := event.Err.Error()
+		record.Error = &msg
+	}
+	if len(event.Details) > 0 {
+		details, err := json.Marshal(event.Details)
+		if err != nil {
+			slog.Warn("Failed to encode audit event details", "error", err, "action", event.Action)
+		} else {
+			record.Details = details
+		}
+	}
+
+	if err := db.InsertAuditEvent(record); err != nil {
+		slog.Error("Failed to write audit event", "error", err, "action", event.Action, "target_id", event.TargetID)
+	}
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
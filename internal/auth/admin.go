@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireAdmin limits a route to users flagged as administrators in the users table.
+func RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID := c.Get("user_id").(int64)
+
+		isAdmin, err := db.IsUserAdmin(userID)
+		if err != nil {
+			slog.Error("Failed to check admin role", "error", err, "user_id", userID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify permissions"})
+		}
+		if !isAdmin {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Administrator access is required"})
+		}
+
+		return next(c)
+	}
+}
@@ -3,8 +3,8 @@ package auth
 import (
 	"database/sql"
 	"errors"
+	"log/slog"
 	"net/http"
-	"os"
 	"regexp"
 	"time"
 
@@ -63,6 +63,7 @@ type User struct {
 	ID        int64     `db:"id" json:"id"`
 	Email     string    `db:"email" json:"email" validate:"required,email"`
 	Password  string    `db:"password" json:"-" validate:"required,password"`
+	IsAdmin   bool      `db:"is_admin" json:"is_admin"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -78,7 +79,14 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
 func CreateUser(email, password string) (*User, error) {
@@ -119,24 +127,6 @@ func GetUserByEmail(email string) (*User, error) {
 	return user, nil
 }
 
-func GenerateToken(user *User) (string, error) {
-	
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key" 
-	}
-
-	return token.SignedString([]byte(secret))
-}
-
 func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
@@ -157,17 +147,18 @@ func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token format"})
 		}
 
+		if isPersonalAccessToken(tokenString) {
+			return authenticateAccessToken(c, tokenString, next)
+		}
+
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, errors.New("unexpected signing method")
 			}
-			
-			secret := os.Getenv("JWT_SECRET")
-			if secret == "" {
-				secret = "your-secret-key" 
+			if len(jwtSecret) == 0 {
+				return nil, errors.New("JWT secret is not initialized")
 			}
-			
-			return []byte(secret), nil
+			return jwtSecret, nil
 		})
 
 		if err != nil {
@@ -176,9 +167,24 @@ func JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 
+			sessionID, _ := claims["sid"].(string)
+			if claims["typ"] != accessTokenType || sessionID == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
+			}
+
+			active, err := db.IsUserSessionActive(sessionID)
+			if err != nil {
+				slog.Error("Failed to check session", "error", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to verify session"})
+			}
+			if !active {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Session has been revoked"})
+			}
+
 			userID := int64(claims["user_id"].(float64))
 			c.Set("user_id", userID)
-			return next(c)
+			c.Set("session_id", sessionID)
+			return resolveOrganization(next)(c)
 		}
 
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid token"})
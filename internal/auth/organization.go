@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+const OrganizationHeader = "X-Organization-ID"
+
+var (
+	viewerRoutesMu sync.RWMutex
+	viewerRoutes   = map[string]bool{}
+)
+
+// ViewerRoute opens a route that reads data despite its method, like a POST taking a query in
+// its body, to organization viewers. Viewers may otherwise only use GET, HEAD and OPTIONS.
+func ViewerRoute(route *echo.Route) {
+	viewerRoutesMu.Lock()
+	defer viewerRoutesMu.Unlock()
+	viewerRoutes[route.Method+" "+route.Path] = true
+}
+
+// resolveOrganization switches the request into the organization named by the
+// X-Organization-ID header. Requests without the header work on the user's personal collections.
+func resolveOrganization(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		orgHeader := c.Request().Header.Get(OrganizationHeader)
+		if orgHeader == "" {
+			return next(c)
+		}
+
+		orgID, err := strconv.ParseInt(orgHeader, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid organization ID"})
+		}
+
+		userID := c.Get("user_id").(int64)
+		member, err := db.GetOrganizationMember(orgID, userID)
+		if errors.Is(err, db.ErrMemberNotFound) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "You are not a member of this organization"})
+		}
+		if err != nil {
+			slog.Error("Failed to resolve organization", "error", err, "user_id", userID, "organization_id", orgID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to resolve organization"})
+		}
+
+		if member.Role == db.RoleViewer && !isReadOnlyRequest(c) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Viewers have read-only access to this organization"})
+		}
+
+		c.Set("organization_id", orgID)
+		c.Set("organization_role", member.Role)
+		return next(c)
+	}
+}
+
+func isReadOnlyRequest(c echo.Context) bool {
+	method := c.Request().Method
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+		return true
+	}
+	viewerRoutesMu.RLock()
+	defer viewerRoutesMu.RUnlock()
+	return viewerRoutes[method+" "+c.Path()]
+}
+
+// ScopeFromContext returns the owner of the resources the request works with.
+func ScopeFromContext(c echo.Context) db.Scope {
+	scope := db.Scope{UserID: c.Get("user_id").(int64)}
+	if orgID, ok := c.Get("organization_id").(int64); ok {
+		scope.OrganizationID = &orgID
+	}
+	return scope
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CollectionOwnership checks the collection, snapshot and change IDs in the route path against
+// the request scope. Resources outside the scope get a 404 so their existence is not revealed.
+func CollectionOwnership(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		scope := ScopeFromContext(c)
+
+		collectionID := c.Param("collectionId")
+		if collectionID == "" {
+			collectionID = c.Param("id")
+		}
+
+		if collectionID != "" {
+			ok, err := db.CollectionInScope(collectionID, scope)
+			if err != nil {
+				return ownershipCheckFailed(c, err)
+			}
+			if !ok {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+			}
+		}
+
+		if param := c.Param("snapshotId"); param != "" {
+			snapshotID, err := strconv.ParseInt(param, 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+			}
+			ok, err := db.SnapshotInScope(snapshotID, collectionID, scope)
+			if err != nil {
+				return ownershipCheckFailed(c, err)
+			}
+			if !ok {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+			}
+		}
+
+		if param := c.Param("changeId"); param != "" {
+			changeID, err := strconv.ParseInt(param, 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid change ID"})
+			}
+			ok, err := db.ChangeInScope(changeID, scope)
+			if err != nil {
+				return ownershipCheckFailed(c, err)
+			}
+			if !ok {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Change not found"})
+			}
+		}
+
+		return next(c)
+	}
+}
+
+func ownershipCheckFailed(c echo.Context, err error) error {
+	slog.Error("Failed to check resource ownership", "error", err, "user_id", c.Get("user_id"), "path", c.Path())
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check access"})
+}
+
+// EnvironmentOwnership checks the environment ID in the route path against the request scope.
+func EnvironmentOwnership(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if environmentID := c.Param("id"); environmentID != "" {
+			ok, err := db.EnvironmentInScope(environmentID, ScopeFromContext(c))
+			if err != nil {
+				return ownershipCheckFailed(c, err)
+			}
+			if !ok {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "Environment not found"})
+			}
+		}
+		return next(c)
+	}
+}
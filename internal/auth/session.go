@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"integratorV2/internal/db"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	accessTokenType = "access"
+)
+
+var jwtSecret []byte
+
+// InitJWT loads the signing secret for access tokens. The server must not start without one.
+func InitJWT() error {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return errors.New("JWT_SECRET is not set")
+	}
+	jwtSecret = []byte(secret)
+	return nil
+}
+
+func generateAccessToken(userID int64, email, sessionID string) (string, error) {
+	if len(jwtSecret) == 0 {
+		return "", errors.New("JWT secret is not initialized")
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"email":   email,
+		"sid":     sessionID,
+		"typ":     accessTokenType,
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTokenTTL).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+func generateRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newLoginResponse(accessToken, refreshToken string) *LoginResponse {
+	return &LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+	}
+}
+
+// StartSession opens a new login session and returns its first access and refresh tokens.
+func StartSession(user *User, userAgent, ipAddress string) (*LoginResponse, error) {
+	refreshToken, refreshHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := uuid.New().String()
+	expiresAt := time.Now().Add(RefreshTokenTTL)
+	if err := db.CreateUserSession(sessionID, user.ID, userAgent, ipAddress, expiresAt, refreshHash); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := generateAccessToken(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLoginResponse(accessToken, refreshToken), nil
+}
+
+// RefreshSession exchanges a refresh token for a new token pair. Each refresh token is single
+// use; replaying one revokes its session.
+func RefreshSession(refreshToken string) (*LoginResponse, error) {
+	newToken, newHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := db.RotateRefreshToken(hashToken(refreshToken), newHash)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := generateAccessToken(session.UserID, session.Email, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLoginResponse(accessToken, newToken), nil
+}
+
+func EndSession(refreshToken string) error {
+	return db.RevokeUserSessionByRefreshToken(hashToken(refreshToken))
+}
+
+func EndAllSessions(userID int64) (int64, error) {
+	return db.RevokeAllUserSessions(userID)
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	ErrAccessTokenInvalid  = errors.New("access token is invalid, expired or revoked")
+)
+
+type PersonalAccessToken struct {
+	ID          int64          `db:"id" json:"id"`
+	UserID      int64          `db:"user_id" json:"user_id"`
+	Name        string         `db:"name" json:"name"`
+	TokenPrefix string         `db:"token_prefix" json:"token_prefix"`
+	TokenHash   string         `db:"token_hash" json:"-"`
+	Scopes      pq.StringArray `db:"scopes" json:"scopes"`
+	ExpiresAt   *time.Time     `db:"expires_at" json:"expires_at"`
+	LastUsedAt  *time.Time     `db:"last_used_at" json:"last_used_at"`
+	RevokedAt   *time.Time     `db:"revoked_at" json:"revoked_at"`
+	CreatedAt   time.Time      `db:"created_at" json:"created_at"`
+}
+
+func CreatePersonalAccessToken(userID int64, name, tokenPrefix, tokenHash string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, error) {
+	token := &PersonalAccessToken{}
+	err := DB.Get(token, `
+		INSERT INTO personal_access_tokens (user_id, name, token_prefix, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`, userID, name, tokenPrefix, tokenHash, pq.StringArray(scopes), expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token: %v", err)
+	}
+	return token, nil
+}
+
+func GetUserPersonalAccessTokens(userID int64) ([]PersonalAccessToken, error) {
+	tokens := []PersonalAccessToken{}
+	err := DB.Select(&tokens, `
+		SELECT * FROM personal_access_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// GetActivePersonalAccessToken looks up a usable token by its hash and records that it was used.
+// The last-used timestamp is only written once a minute to keep busy automation from turning
+// every request into an UPDATE.
+func GetActivePersonalAccessToken(tokenHash string) (*PersonalAccessToken, error) {
+	token := &PersonalAccessToken{}
+	err := DB.Get(token, `
+		SELECT * FROM personal_access_tokens
+		WHERE token_hash = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrAccessTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %v", err)
+	}
+
+	_, err = DB.Exec(`
+		UPDATE personal_access_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, token.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update access token last used: %v", err)
+	}
+	return token, nil
+}
+
+func RevokePersonalAccessToken(tokenID, userID int64) error {
+	result, err := DB.Exec(`
+		UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AuditEvent struct {
+	ID             int64           `db:"id" json:"id"`
+	UserID         *int64          `db:"user_id" json:"user_id"`
+	UserEmail      *string         `db:"user_email" json:"user_email"`
+	OrganizationID *int64          `db:"organization_id" json:"organization_id"`
+	SessionID      *string         `db:"session_id" json:"session_id"`
+	AccessTokenID  *int64          `db:"access_token_id" json:"access_token_id"`
+	Action         string          `db:"action" json:"action"`
+	TargetType     string          `db:"target_type" json:"target_type"`
+	TargetID       *string         `db:"target_id" json:"target_id"`
+	CollectionID   *string         `db:"collection_id" json:"collection_id"`
+	Outcome        string          `db:"outcome" json:"outcome"`
+	Error          *string         `db:"error" json:"error"`
+	Details        json.RawMessage `db:"details" json:"details"`
+	IPAddress      *string         `db:"ip_address" json:"ip_address"`
+	UserAgent      *string         `db:"user_agent" json:"user_agent"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
+
+// AuditFilter narrows the audit log. A nil Scope returns events of every user and organization.
+type AuditFilter struct {
+	Scope        *Scope
+	UserID       *int64
+	Action       string
+	TargetType   string
+	TargetID     string
+	CollectionID string
+	Outcome      string
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+// InsertAuditEvent appends an event. The actor's email is copied onto the event so the record
+// still names them after the account is gone.
+func InsertAuditEvent(event *AuditEvent) error {
+	var details interface{}
+	if len(event.Details) > 0 {
+		details = string(event.Details)
+	}
+
+	_, err := DB.Exec(`
+		INSERT INTO audit_events (
+			user_id, user_email, organization_id, session_id, access_token_id,
+			action, target_type, target_id, collection_id, outcome, error, details,
+			ip_address, user_agent
+		)
+		VALUES (
+			$1, (SELECT email FROM users WHERE id = $1), $2, $3, $4,
+			$5, $6, $7, $8, $9, $10, $11,
+			$12, $13
+		)
+	`, event.UserID, event.OrganizationID, event.SessionID, event.AccessTokenID,
+		event.Action, event.TargetType, event.TargetID, event.CollectionID, event.Outcome, event.Error, details,
+		event.IPAddress, event.UserAgent)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit event: %v", err)
+	}
+	return nil
+}
+
+func GetAuditEvents(filter AuditFilter) ([]AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Scope != nil {
+		args = append(args, filter.Scope.UserID, filter.Scope.OrganizationID)
+		conditions = append(conditions, ownerCondition("", len(args)-1, len(args)))
+	}
+	if filter.UserID != nil {
+		addCondition("user_id = $%d", *filter.UserID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if filter.CollectionID != "" {
+		addCondition("collection_id = $%d", filter.CollectionID)
+	}
+	if filter.Outcome != "" {
+		addCondition("outcome = $%d", filter.Outcome)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at <= $%d", *filter.To)
+	}
+
+	whereClause := "TRUE"
+	if len(conditions) > 0 {
+		whereClause = strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := DB.Get(&total, "SELECT COUNT(*) FROM audit_events WHERE "+whereClause, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %v", err)
+	}
+
+	events := []AuditEvent{}
+	query := fmt.Sprintf(`
+		SELECT * FROM audit_events
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, whereClause, len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, filter.Offset)
+
+	if err := DB.Select(&events, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get audit events: %v", err)
+	}
+	return events, total, nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// JobTypeBulkImport is a parent job whose child import jobs each import one collection. It is
+// running until every child has finished.
+const (
+	JobTypeBulkImport = "bulk_import"
+
+	JobStatusRunning = "running"
+)
+
+type BulkImportItem struct {
+	CollectionID string
+	Name         string
+}
+
+// JobProgress counts the child jobs of a bulk import by status. Unchanged children completed
+// without a new snapshot because the collection had not changed.
+type JobProgress struct {
+	Total     int `db:"total" json:"total"`
+	Pending   int `db:"pending" json:"pending"`
+	Running   int `db:"running" json:"running"`
+	Succeeded int `db:"succeeded" json:"succeeded"`
+	Unchanged int `db:"unchanged" json:"unchanged"`
+	Failed    int `db:"failed" json:"failed"`
+	Canceled  int `db:"canceled" json:"canceled"`
+}
+
+// CreateBulkImportJob creates a running parent job and a pending import job for each item whose
+// collection has no import in flight in the scope, under the same lock as
+// CreateCollectionJobUnlessActive. The jobs already in flight are returned instead of children;
+// when every item has one, no parent job is created and the returned parent is nil.
+func CreateBulkImportJob(scope Scope, name string, items []BulkImportItem) (*CollectionJob, []CollectionJob, []CollectionJob, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	parent := &CollectionJob{}
+	err = tx.Get(parent, `
+		INSERT INTO collection_jobs (user_id, organization_id, collection_id, name, job_type, status, started_at)
+		VALUES ($1, $2, '', $3, $4, $5, CURRENT_TIMESTAMP)
+		RETURNING *
+	`, scope.UserID, scope.OrganizationID, name, JobTypeBulkImport, JobStatusRunning)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create bulk import job: %v", err)
+	}
+
+	// Locking the collections in a fixed order keeps concurrent bulk imports from deadlocking.
+	items = append([]BulkImportItem(nil), items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].CollectionID < items[j].CollectionID })
+
+	children := make([]CollectionJob, 0, len(items))
+	active := []CollectionJob{}
+	for _, item := range items {
+		job, err := lockActiveCollectionJob(tx, scope, item.CollectionID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if job != nil {
+			active = append(active, *job)
+			continue
+		}
+
+		var child CollectionJob
+		err = tx.Get(&child, `
+			INSERT INTO collection_jobs (user_id, organization_id, collection_id, name, job_type, parent_job_id)
+			VALUES ($1, $2, $3, $4, 'import', $5)
+			RETURNING *
+		`, scope.UserID, scope.OrganizationID, item.CollectionID, item.Name, parent.ID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to create collection job: %v", err)
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		return nil, nil, active, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return parent, children, active, nil
+}
+
+func GetChildCollectionJobs(parentJobID int64) ([]CollectionJob, error) {
+	jobs := []CollectionJob{}
+	err := DB.Select(&jobs, `
+		SELECT * FROM collection_jobs
+		WHERE parent_job_id = $1
+		ORDER BY id
+	`, parentJobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get child collection jobs: %v", err)
+	}
+	return jobs, nil
+}
+
+func GetBulkImportProgress(parentJobID int64) (*JobProgress, error) {
+	progress := &JobProgress{}
+	err := DB.Get(progress, `
+		SELECT
+			COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+			COUNT(*) FILTER (WHERE status NOT IN ('pending', 'completed', 'failed', 'canceled')) AS running,
+			COUNT(*) FILTER (WHERE status = 'completed' AND error_code IS DISTINCT FROM $2) AS succeeded,
+			COUNT(*) FILTER (WHERE status = 'completed' AND error_code = $2) AS unchanged,
+			COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+			COUNT(*) FILTER (WHERE status = 'canceled') AS canceled
+		FROM collection_jobs
+		WHERE parent_job_id = $1
+	`, parentJobID, JobErrorIdenticalSnapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bulk import progress: %v", err)
+	}
+	return progress, nil
+}
+
+// FinishBulkImportJob completes a running bulk import once none of its children is left
+// unfinished. It reports whether this call finished the job, so only one caller acts on it.
+func FinishBulkImportJob(parentJobID int64, status string, result json.RawMessage) (bool, error) {
+	res, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, result = $2, finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status = 'running'
+		AND NOT EXISTS (
+			SELECT 1 FROM collection_jobs child
+			WHERE child.parent_job_id = $3
+			AND child.status NOT IN ('completed', 'failed', 'canceled')
+		)
+	`, status, result, parentJobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to finish bulk import job: %v", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to finish bulk import job: %v", err)
+	}
+	return rows > 0, nil
+}
+
+// ReopenBulkImportJob puts a finished bulk import back to running when one of its children is
+// retried, so that it is finished and summarized again once the child finishes.
+func ReopenBulkImportJob(parentJobID int64) error {
+	_, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, result = NULL, finished_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND job_type = $3
+	`, JobStatusRunning, parentJobID, JobTypeBulkImport)
+	if err != nil {
+		return fmt.Errorf("failed to reopen bulk import job: %v", err)
+	}
+	return nil
+}
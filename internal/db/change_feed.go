@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ChangeSourceCollection  = "collection"
+	ChangeSourceEnvironment = "environment"
+)
+
+// ChangeFeedEntry is a collection or environment change. For environment changes Path is the
+// variable key, followed by the changed field for modified variables.
+type ChangeFeedEntry struct {
+	Source       string    `db:"source" json:"source"`
+	ResourceID   string    `db:"resource_id" json:"resource_id"`
+	ResourceName string    `db:"resource_name" json:"resource_name"`
+	ChangeID     int64     `db:"change_id" json:"change_id"`
+	SnapshotID   int64     `db:"snapshot_id" json:"snapshot_id"`
+	ChangeType   string    `db:"change_type" json:"change_type"`
+	Path         string    `db:"path" json:"path"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+}
+
+type ChangeFeedFilter struct {
+	Scope  Scope
+	Source string
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// changeFeedQuery merges the changes of every collection and environment in the scope.
+func changeFeedQuery(userParam, orgParam int) string {
+	return `
+		SELECT 'collection' AS source, ch.collection_id AS resource_id, c.name AS resource_name,
+			ch.id AS change_id, ch.new_snapshot_id AS snapshot_id, ch.change_type, ch.path, ch.created_at
+		FROM changes ch
+		JOIN collections c ON c.id = ch.collection_id
+		WHERE ` + ownerCondition("c", userParam, orgParam) + `
+		UNION ALL
+		SELECT 'environment', ec.environment_id, e.name,
+			ec.id, ec.new_snapshot_id, ec.change_type, ec.variable_key || COALESCE('.' || ec.field, ''), ec.created_at
+		FROM environment_changes ec
+		JOIN environments e ON e.id = ec.environment_id
+		WHERE ` + ownerCondition("e", userParam, orgParam)
+}
+
+func GetChangeFeed(filter ChangeFeedFilter) ([]ChangeFeedEntry, int, error) {
+	args := []interface{}{filter.Scope.UserID, filter.Scope.OrganizationID}
+	var conditions []string
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Source != "" {
+		addCondition("source = $%d", filter.Source)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at <= $%d", *filter.To)
+	}
+
+	whereClause := "TRUE"
+	if len(conditions) > 0 {
+		whereClause = strings.Join(conditions, " AND ")
+	}
+	from := "(" + changeFeedQuery(1, 2) + ") feed WHERE " + whereClause
+
+	var total int
+	if err := DB.Get(&total, "SELECT COUNT(*) FROM "+from, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count changes: %v", err)
+	}
+
+	entries := []ChangeFeedEntry{}
+	query := fmt.Sprintf(`
+		SELECT * FROM %s
+		ORDER BY created_at DESC, change_id DESC
+		LIMIT $%d OFFSET $%d
+	`, from, len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, filter.Offset)
+
+	if err := DB.Select(&entries, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get change feed: %v", err)
+	}
+	return entries, total, nil
+}
@@ -595,7 +595,7 @@ func GetChangesByEndpoint(collectionID string, snapshotID int64) (map[string][]*
 	return endpointChanges, nil
 }
 
-func GetChangeDetails(changeID int64) (change ChangeDetail, err error) {
+func GetChangeDetails(changeID int64, scope Scope) (change ChangeDetail, err error) {
 		 
 	query := `
 		SELECT 
@@ -603,9 +603,10 @@ func GetChangeDetails(changeID int64) (change ChangeDetail, err error) {
 			change_type, path, modification, created_at
 		FROM changes
 		WHERE id = $1
+		AND collection_id IN (` + scopedCollectionIDs(2, 3) + `)
 	`
 	change = ChangeDetail{}
-	err = DB.QueryRow(query, changeID).Scan(
+	err = DB.QueryRow(query, changeID, scope.UserID, scope.OrganizationID).Scan(
 		&change.ID,
 		&change.CollectionID,
 		&change.OldSnapshotID,
@@ -618,7 +619,7 @@ func GetChangeDetails(changeID int64) (change ChangeDetail, err error) {
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return ChangeDetail{}, fmt.Errorf("Change not found")
+			return ChangeDetail{}, ErrChangeNotFound
 		}
 
 		return ChangeDetail{}, fmt.Errorf("internal server error")
@@ -72,6 +72,10 @@ func AnalyzeChangeImpact(collectionID string, snapshotID int64) (*ChangeImpactAn
 		return nil, fmt.Errorf("failed to get changes: %w", err)
 	}
 	
+	return AnalyzeChanges(collectionID, snapshotID, changes), nil
+}
+
+func AnalyzeChanges(collectionID string, snapshotID int64, changes []*ChangeDetail) *ChangeImpactAnalysis {
 	analysis := &ChangeImpactAnalysis{
 		CollectionID:    collectionID,
 		SnapshotID:      snapshotID,
@@ -98,7 +102,7 @@ func AnalyzeChangeImpact(collectionID string, snapshotID int64) (*ChangeImpactAn
 	
 	analysis.Summary = calculateImpactSummary(analysis)
 	
-	return analysis, nil
+	return analysis
 }
 
 func analyzeIndividualChange(change *ChangeDetail) *ImpactDetail {
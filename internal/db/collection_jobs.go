@@ -0,0 +1,190 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Collection import jobs move through the stages below and end completed, failed or canceled. A
+// failed attempt that asynq will retry leaves the job retrying until the next attempt starts.
+// Once a job is canceled, its remaining attempts no longer change its status.
+const (
+	JobStatusPending      = "pending"
+	JobStatusFetching     = "fetching"
+	JobStatusMasking      = "masking"
+	JobStatusSnapshotting = "snapshotting"
+	JobStatusDiffing      = "diffing"
+	JobStatusRetrying     = "retrying"
+	JobStatusCompleted    = "completed"
+	JobStatusFailed       = "failed"
+	JobStatusCanceled     = "canceled"
+)
+
+// Error codes classify why an import failed. JobErrorIdenticalSnapshot is also set on completed
+// imports that stored nothing because the collection had not changed.
+const (
+	JobErrorAPIKeyMissing       = "api_key_missing"
+	JobErrorPostmanUnauthorized = "postman_unauthorized"
+	JobErrorPostmanForbidden    = "postman_forbidden"
+	JobErrorPostmanNotFound     = "postman_not_found"
+	JobErrorPostmanRateLimited  = "postman_rate_limited"
+	JobErrorPostmanUnavailable  = "postman_unavailable"
+	JobErrorPostmanError        = "postman_error"
+	JobErrorPostmanUnreachable  = "postman_unreachable"
+	JobErrorInvalidCollection   = "invalid_collection"
+	JobErrorMaskingFailed       = "masking_failed"
+	JobErrorDatabaseWriteFailed = "database_write_failed"
+	JobErrorIdenticalSnapshot   = "identical_snapshot"
+)
+
+var ErrCollectionJobNotFound = errors.New("collection job not found")
+
+// JobOutcome is the result of a job attempt. StageDurations holds the milliseconds spent in each
+// stage of the attempt.
+type JobOutcome struct {
+	Status         string
+	SnapshotID     *int64
+	ChangeCount    *int
+	ErrorCode      *string
+	Error          *string
+	StageDurations map[string]int64
+}
+
+func SetCollectionJobTaskID(jobID int64, taskID string) error {
+	_, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET task_id = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`, taskID, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to set collection job task ID: %v", err)
+	}
+	return nil
+}
+
+func GetCollectionJobByTaskID(taskID string) (*CollectionJob, error) {
+	job := &CollectionJob{}
+	err := DB.Get(job, `
+		SELECT * FROM collection_jobs
+		WHERE task_id = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, taskID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCollectionJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection job: %v", err)
+	}
+	return job, nil
+}
+
+// StartCollectionJobAttempt counts a new attempt, enters its first stage and clears the outcome
+// of the previous attempt. started_at keeps the start of the first attempt.
+func StartCollectionJobAttempt(jobID int64, stage string) error {
+	_, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, attempts = attempts + 1,
+			started_at = COALESCE(started_at, CURRENT_TIMESTAMP), finished_at = NULL,
+			stage_durations = NULL, error = NULL, error_code = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND status <> 'canceled'
+	`, stage, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to start collection job attempt: %v", err)
+	}
+	return nil
+}
+
+func UpdateCollectionJobStage(jobID int64, stage string, stageDurations map[string]int64) error {
+	durations, err := json.Marshal(stageDurations)
+	if err != nil {
+		return fmt.Errorf("failed to encode stage durations: %v", err)
+	}
+
+	_, err = DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, stage_durations = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND status <> 'canceled'
+	`, stage, durations, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to update collection job stage: %v", err)
+	}
+	return nil
+}
+
+// FinishCollectionJobAttempt records the outcome of an attempt. finished_at is only set once the
+// job is completed or failed.
+func FinishCollectionJobAttempt(jobID int64, outcome JobOutcome) error {
+	durations, err := json.Marshal(outcome.StageDurations)
+	if err != nil {
+		return fmt.Errorf("failed to encode stage durations: %v", err)
+	}
+
+	_, err = DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, snapshot_id = $2, change_count = $3, error_code = $4, error = $5,
+			stage_durations = $6,
+			finished_at = CASE WHEN $1 IN ('completed', 'failed') THEN CURRENT_TIMESTAMP END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7 AND status <> 'canceled'
+	`, outcome.Status, outcome.SnapshotID, outcome.ChangeCount, outcome.ErrorCode, outcome.Error,
+		durations, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to finish collection job attempt: %v", err)
+	}
+	return nil
+}
+
+// CancelCollectionJob marks a job canceled unless it already finished, and reports whether it did.
+func CancelCollectionJob(jobID int64) (bool, error) {
+	result, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = 'canceled', finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status NOT IN ('completed', 'failed', 'canceled')
+	`, jobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel collection job: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel collection job: %v", err)
+	}
+	return rows > 0, nil
+}
+
+// ResetCollectionJob puts a failed or canceled job back to pending for a retry, and reports
+// whether it did. Only one of several concurrent retries of a job claims it. The attempt count
+// is kept.
+func ResetCollectionJob(jobID int64) (bool, error) {
+	result, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = 'pending', finished_at = NULL, error = NULL, error_code = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('failed', 'canceled')
+	`, jobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to reset collection job: %v", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reset collection job: %v", err)
+	}
+	return rows > 0, nil
+}
+
+// ReleaseCollectionJob undoes ResetCollectionJob for a retry that enqueued nothing, restoring the
+// outcome the job had before.
+func ReleaseCollectionJob(job *CollectionJob) error {
+	_, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, error = $2, error_code = $3, finished_at = $4, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5 AND status = 'pending'
+	`, job.Status, job.Error, job.ErrorCode, job.FinishedAt, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to release collection job: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// collectionLockSpace is the first key of the advisory locks taken on collections, keeping them
+// apart from any other advisory lock on the database.
+const collectionLockSpace = 1001
+
+// ActiveImportWindow is how long an unfinished import job counts as in flight. A job left behind
+// by a crashed worker stops blocking new imports of its collection once it is this old.
+const ActiveImportWindow = time.Hour
+
+// BeginCollectionTx begins a transaction holding the lock that serializes writers of a
+// collection's snapshots and changes. It blocks until no other transaction holds the lock on the
+// collection; the lock is released when the transaction ends.
+func BeginCollectionTx(collectionID string) (*sqlx.Tx, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, collectionLockSpace, collectionID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to lock collection: %v", err)
+	}
+	return tx, nil
+}
+
+// CreateCollectionJobUnlessActive creates an import job for the collection unless the scope
+// already has one in flight, which it returns instead. It reports whether the job was created.
+func CreateCollectionJobUnlessActive(scope Scope, collectionID, name string) (*CollectionJob, bool, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	job, err := lockActiveCollectionJob(tx, scope, collectionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if job != nil {
+		return job, false, nil
+	}
+
+	job = &CollectionJob{}
+	err = tx.Get(job, `
+		INSERT INTO collection_jobs (user_id, organization_id, collection_id, name, job_type)
+		VALUES ($1, $2, $3, $4, 'import')
+		RETURNING *
+	`, scope.UserID, scope.OrganizationID, collectionID, name)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create collection job: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return job, true, nil
+}
+
+// lockActiveCollectionJob takes the lock that serializes job creation for the collection until tx
+// ends, and returns the scope's import job in flight for it, or nil when there is none.
+func lockActiveCollectionJob(tx *sqlx.Tx, scope Scope, collectionID string) (*CollectionJob, error) {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, collectionLockSpace, "job:"+collectionID); err != nil {
+		return nil, fmt.Errorf("failed to lock collection: %v", err)
+	}
+
+	job := &CollectionJob{}
+	err := tx.Get(job, `
+		SELECT * FROM collection_jobs
+		WHERE collection_id = $3 AND job_type = 'import'
+		AND status NOT IN ('completed', 'failed', 'canceled')
+		AND updated_at > $4
+		AND `+ownerCondition("", 1, 2)+`
+		ORDER BY id DESC
+		LIMIT 1
+	`, scope.UserID, scope.OrganizationID, collectionID, time.Now().Add(-ActiveImportWindow))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active collection job: %v", err)
+	}
+	return job, nil
+}
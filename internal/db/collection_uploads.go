@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrCollectionUploadNotFound = errors.New("collection upload not found")
+
+// SaveCollectionUpload stores the uploaded export of an upload job until the job has imported it.
+// The content is sealed by the configured encryption provider, so the raw export is never kept
+// in plaintext, nor in the task payload.
+func SaveCollectionUpload(jobID int64, sealedContent string) error {
+	_, err := DB.Exec(`
+		INSERT INTO collection_uploads (job_id, content)
+		VALUES ($1, $2)
+	`, jobID, sealedContent)
+	if err != nil {
+		return fmt.Errorf("failed to save collection upload: %v", err)
+	}
+	return nil
+}
+
+// GetCollectionUpload returns the sealed export stored for an upload job.
+func GetCollectionUpload(jobID int64) (string, error) {
+	var sealedContent string
+	err := DB.Get(&sealedContent, `SELECT content FROM collection_uploads WHERE job_id = $1`, jobID)
+	if err == sql.ErrNoRows {
+		return "", ErrCollectionUploadNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get collection upload: %v", err)
+	}
+	return sealedContent, nil
+}
+
+func DeleteCollectionUpload(jobID int64) error {
+	if _, err := DB.Exec(`DELETE FROM collection_uploads WHERE job_id = $1`, jobID); err != nil {
+		return fmt.Errorf("failed to delete collection upload: %v", err)
+	}
+	return nil
+}
@@ -3,21 +3,23 @@ package db
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"	
 	"time"
 
-	"integratorV2/internal/config"
+	"integratorV2/internal/encryption"
 )
 
 type Collection struct {
-	ID        string    `db:"id" json:"id"`
-	UserID    string    `db:"user_id" json:"user_id"`
-	Name      string    `db:"name" json:"name"`
-	FirstSeen time.Time `db:"first_seen" json:"first_seen"`
-	LastSeen  time.Time `db:"last_seen" json:"last_seen"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	ID             string    `db:"id" json:"id"`
+	UserID         string    `db:"user_id" json:"user_id"`
+	OrganizationID *int64    `db:"organization_id" json:"organization_id"`
+	Name           string    `db:"name" json:"name"`
+	FirstSeen      time.Time `db:"first_seen" json:"first_seen"`
+	LastSeen       time.Time `db:"last_seen" json:"last_seen"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
 }
 
 type Snapshot struct {
@@ -43,14 +45,26 @@ type Change struct {
 }
 
 type CollectionJob struct {
-	ID           int64     `db:"id" json:"id"`
-	UserID       int64     `db:"user_id" json:"user_id"`
-	CollectionID string    `db:"collection_id" json:"collection_id"`
-	Name         string    `db:"name" json:"name"`
-	Status       string    `db:"status" json:"status"`
-	Error        *string   `db:"error" json:"error"`
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+	ID             int64           `db:"id" json:"id"`
+	UserID         int64           `db:"user_id" json:"user_id"`
+	OrganizationID *int64          `db:"organization_id" json:"organization_id"`
+	CollectionID   string          `db:"collection_id" json:"collection_id"`
+	Name           string          `db:"name" json:"name"`
+	Status         string          `db:"status" json:"status"`
+	Error          *string         `db:"error" json:"error"`
+	JobType        string          `db:"job_type" json:"job_type"`
+	Result         json.RawMessage `db:"result" json:"result,omitempty"`
+	TaskID         *string         `db:"task_id" json:"task_id"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	StartedAt      *time.Time      `db:"started_at" json:"started_at"`
+	FinishedAt     *time.Time      `db:"finished_at" json:"finished_at"`
+	StageDurations json.RawMessage `db:"stage_durations" json:"stage_durations,omitempty"`
+	SnapshotID     *int64          `db:"snapshot_id" json:"snapshot_id"`
+	ChangeCount    *int            `db:"change_count" json:"change_count"`
+	ErrorCode      *string         `db:"error_code" json:"error_code"`
+	ParentJobID    *int64          `db:"parent_job_id" json:"parent_job_id"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updated_at"`
 }
 
 type APIKeyInfo struct {
@@ -69,20 +83,66 @@ type ChangesResponse struct {
 }
 
 
-func StoreCollection(id, name string, user_id int64) error {
+func StoreCollection(id, name string, scope Scope) error {
 	_, err := DB.Exec(`
-		INSERT INTO collections (id, name, user_id)
-		VALUES ($1, $2, $3)
+		INSERT INTO collections (id, name, user_id, organization_id)
+		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (id) DO UPDATE
 		SET name = $2,
 		    last_seen = CURRENT_TIMESTAMP
-	`, id, name, user_id)
+	`, id, name, scope.UserID, scope.OrganizationID)
 	return err
 }
 
-func StorePostmanAPIKey(userID int64, apiKey string) error {
+func GetScopedCollection(collectionID string, scope Scope) (*Collection, error) {
+	collection := &Collection{}
+	err := DB.Get(collection, `
+		SELECT * FROM collections
+		WHERE id = $1 AND `+ownerCondition("", 2, 3),
+		collectionID, scope.UserID, scope.OrganizationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	return collection, nil
+}
+
+func IsCollectionOwnedOutsideScope(collectionID string, scope Scope) (bool, error) {
+	var exists bool
+	err := DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM collections
+			WHERE id = $1 AND NOT COALESCE(`+ownerCondition("", 2, 3)+`, false)
+		)
+	`, collectionID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check collection owner: %v", err)
+	}
+	return exists, nil
+}
+
+// GetCollectionScope returns the scope that owns the collection.
+func GetCollectionScope(collectionID string) (Scope, error) {
+	var scope Scope
+	err := DB.QueryRow(`
+		SELECT user_id, organization_id FROM collections WHERE id = $1
+	`, collectionID).Scan(&scope.UserID, &scope.OrganizationID)
+	if err == sql.ErrNoRows {
+		return Scope{}, ErrCollectionNotFound
+	}
+	if err != nil {
+		return Scope{}, fmt.Errorf("failed to get collection owner: %w", err)
+	}
+	return scope, nil
+}
+
+func StorePostmanAPIKey(scope Scope, apiKey string) error {
+	userID := scope.UserID
+
 	
-	encryptedKey, err := config.EncryptAPIKey(apiKey)
+	encryptedKey, keyVersion, err := encryptAPIKey(apiKey)
 	if err != nil {
 		slog.Error("Failed to encrypt API key", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to encrypt API key: %v", err)
@@ -94,11 +154,11 @@ func StorePostmanAPIKey(userID int64, apiKey string) error {
 	
 	_, err = DB.Exec(`
 		INSERT INTO postman_api_keys (
-			user_id, encrypted_key, key_version,
+			user_id, organization_id, encrypted_key, key_version,
 			expires_at, last_rotated_at, is_active
 		)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, userID, encryptedKey, 1, expiresAt, time.Now(), true)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, userID, scope.OrganizationID, encryptedKey, keyVersion, expiresAt, time.Now(), true)
 	if err != nil {
 		slog.Error("Failed to store API key", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to store API key: %v", err)
@@ -108,16 +168,17 @@ func StorePostmanAPIKey(userID int64, apiKey string) error {
 	return nil
 }
 
-func GetPostmanAPIKey(userID int64) (string, error) {
+func GetPostmanAPIKey(scope Scope) (string, error) {
+	userID := scope.UserID
 	var encryptedKey string
 	err := DB.Get(&encryptedKey, `
 		SELECT encrypted_key FROM postman_api_keys
-		WHERE user_id = $1
+		WHERE `+ownerCondition("", 1, 2)+`
 		AND is_active = true
 		AND expires_at > NOW()
 		ORDER BY created_at DESC
 		LIMIT 1
-	`, userID)
+	`, userID, scope.OrganizationID)
 	if err == sql.ErrNoRows {
 		slog.Warn("No active API key found", "user_id", userID)
 		return "", fmt.Errorf("no active API key found for user")
@@ -128,7 +189,7 @@ func GetPostmanAPIKey(userID int64) (string, error) {
 	}
 
 	
-	apiKey, err := config.DecryptAPIKey(encryptedKey)
+	apiKey, err := encryption.DecryptString(encryptedKey)
 	if err != nil {
 		slog.Error("Failed to decrypt API key", "error", err, "user_id", userID)
 		return "", fmt.Errorf("failed to decrypt API key: %v", err)
@@ -138,9 +199,10 @@ func GetPostmanAPIKey(userID int64) (string, error) {
 	return apiKey, nil
 }
 
-func RotateAPIKey(userID int64, newAPIKey string) error {
+func RotateAPIKey(scope Scope, newAPIKey string) error {
+	userID := scope.UserID
 	
-	encryptedKey, err := config.EncryptAPIKey(newAPIKey)
+	encryptedKey, keyVersion, err := encryptAPIKey(newAPIKey)
 	if err != nil {
 		slog.Error("Failed to encrypt new API key", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to encrypt API key: %v", err)
@@ -161,8 +223,8 @@ func RotateAPIKey(userID int64, newAPIKey string) error {
 	_, err = tx.Exec(`
 		UPDATE postman_api_keys
 		SET is_active = false
-		WHERE user_id = $1 AND is_active = true
-	`, userID)
+		WHERE `+ownerCondition("", 1, 2)+` AND is_active = true
+	`, userID, scope.OrganizationID)
 	if err != nil {
 		slog.Error("Failed to deactivate old keys", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to deactivate old keys: %v", err)
@@ -171,11 +233,11 @@ func RotateAPIKey(userID int64, newAPIKey string) error {
 	
 	_, err = tx.Exec(`
 		INSERT INTO postman_api_keys (
-			user_id, encrypted_key, key_version,
+			user_id, organization_id, encrypted_key, key_version,
 			expires_at, last_rotated_at, is_active
 		)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, userID, encryptedKey, 1, expiresAt, time.Now(), true)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, userID, scope.OrganizationID, encryptedKey, keyVersion, expiresAt, time.Now(), true)
 	if err != nil {
 		slog.Error("Failed to insert new key", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to insert new key: %v", err)
@@ -191,12 +253,13 @@ func RotateAPIKey(userID int64, newAPIKey string) error {
 	return nil
 }
 
-func UpdateLastUsedAPIKey(userID int64) error {
+func UpdateLastUsedAPIKey(scope Scope) error {
+	userID := scope.UserID
 	_, err := DB.Exec(`
 		UPDATE postman_api_keys
 		SET last_used_at = CURRENT_TIMESTAMP
-		WHERE user_id = $1 AND is_active = true
-	`, userID)
+		WHERE `+ownerCondition("", 1, 2)+` AND is_active = true
+	`, userID, scope.OrganizationID)
 	if err != nil {
 		slog.Error("Failed to update last used timestamp", "error", err, "user_id", userID)
 		return fmt.Errorf("failed to update last used timestamp: %v", err)
@@ -206,19 +269,25 @@ func UpdateLastUsedAPIKey(userID int64) error {
 	return nil
 }
 
-func CreateCollectionJob(userID int64, collectionID, name string) (*CollectionJob, error) {
+func CreateCollectionJob(scope Scope, collectionID, name string) (*CollectionJob, error) {
+	return CreateCollectionJobWithType(scope, collectionID, name, "import")
+}
+
+func CreateCollectionJobWithType(scope Scope, collectionID, name, jobType string) (*CollectionJob, error) {
 	job := &CollectionJob{
-		UserID:       userID,
-		CollectionID: collectionID,
-		Name:         name,
-		Status:       "pending",
+		UserID:         scope.UserID,
+		OrganizationID: scope.OrganizationID,
+		CollectionID:   collectionID,
+		Name:           name,
+		Status:         "pending",
+		JobType:        jobType,
 	}
 
 	err := DB.QueryRow(`
-		INSERT INTO collection_jobs (user_id, collection_id, name)
-		VALUES ($1, $2, $3)
+		INSERT INTO collection_jobs (user_id, organization_id, collection_id, name, job_type)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at, updated_at
-	`, userID, collectionID, name).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	`, scope.UserID, scope.OrganizationID, collectionID, name, jobType).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create collection job: %v", err)
@@ -231,7 +300,7 @@ func UpdateCollectionJobStatus(jobID int64, status string, errMsg *string) error
 	_, err := DB.Exec(`
 		UPDATE collection_jobs
 		SET status = $1, error = $2, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $3
+		WHERE id = $3 AND status <> 'canceled'
 	`, status, errMsg, jobID)
 	if err != nil {
 		return fmt.Errorf("failed to update collection job status: %v", err)
@@ -239,50 +308,65 @@ func UpdateCollectionJobStatus(jobID int64, status string, errMsg *string) error
 	return nil
 }
 
+func CompleteCollectionJob(jobID int64, status string, result json.RawMessage, errMsg *string) error {
+	_, err := DB.Exec(`
+		UPDATE collection_jobs
+		SET status = $1, result = $2, error = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND status <> 'canceled'
+	`, status, result, errMsg, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to complete collection job: %v", err)
+	}
+	return nil
+}
+
 func GetCollectionJob(jobID int64) (*CollectionJob, error) {
 	job := &CollectionJob{}
 	err := DB.Get(job, `
 		SELECT * FROM collection_jobs
 		WHERE id = $1
 	`, jobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCollectionJobNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get collection job: %v", err)
 	}
 	return job, nil
 }
 
-func GetUserCollectionJobs(userID int64) ([]CollectionJob, error) {
+func GetScopedCollectionJobs(scope Scope) ([]CollectionJob, error) {
 	var jobs []CollectionJob
 	err := DB.Select(&jobs, `
 		SELECT * FROM collection_jobs
-		WHERE user_id = $1
+		WHERE `+ownerCondition("", 1, 2)+`
 		ORDER BY created_at DESC
-	`, userID)
+	`, scope.UserID, scope.OrganizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user collection jobs: %v", err)
 	}
 	return jobs, nil
 }
 
-func GetAPIKeyInfo(userID int64) ([]APIKeyInfo, error) {
+func GetAPIKeyInfo(scope Scope) ([]APIKeyInfo, error) {
 	var keys []APIKeyInfo
 	err := DB.Select(&keys, `
 		SELECT id, created_at, last_used_at, last_rotated_at, expires_at, is_active, encrypted_key
 		FROM postman_api_keys
-		WHERE user_id = $1
+		WHERE `+ownerCondition("", 1, 2)+`
 		ORDER BY created_at DESC
-	`, userID)
+	`, scope.UserID, scope.OrganizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get API key info: %v", err)
 	}
 	return keys, nil
 }
 
-func DeleteAPIKey(keyID int64, userID int64) error {
+func DeleteAPIKey(keyID int64, scope Scope) error {
 	result, err := DB.Exec(`
 		DELETE FROM postman_api_keys
-		WHERE id = $1 AND user_id = $2
-	`, keyID, userID)
+		WHERE id = $1 AND `+ownerCondition("", 2, 3),
+		keyID, scope.UserID, scope.OrganizationID)
 	if err != nil {
 		return fmt.Errorf("failed to delete API key: %v", err)
 	}
@@ -293,19 +377,19 @@ func DeleteAPIKey(keyID int64, userID int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no API key found with ID %d for user %d", keyID, userID)
+		return fmt.Errorf("no API key found with ID %d for user %d", keyID, scope.UserID)
 	}
 
 	return nil
 }
 
-func GetUserCollections(userID int64) ([]Collection, error) {
+func GetScopedCollections(scope Scope) ([]Collection, error) {
 	var collections []Collection
 	err := DB.Select(&collections, `
 		SELECT * FROM collections
-		WHERE user_id = $1
+		WHERE `+ownerCondition("", 1, 2)+`
 		ORDER BY last_seen DESC
-	`, userID)
+	`, scope.UserID, scope.OrganizationID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user collections: %v", err)
 	}
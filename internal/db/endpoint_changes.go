@@ -0,0 +1,84 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type EndpointChange struct {
+	ID            int64     `db:"id" json:"id,omitempty"`
+	CollectionID  string    `db:"collection_id" json:"collection_id,omitempty"`
+	OldSnapshotID *int64    `db:"old_snapshot_id" json:"old_snapshot_id,omitempty"`
+	NewSnapshotID int64     `db:"new_snapshot_id" json:"new_snapshot_id,omitempty"`
+	EventType     string    `db:"event_type" json:"event_type"`
+	Method        string    `db:"method" json:"method"`
+	Path          string    `db:"path" json:"path"`
+	EndpointName  string    `db:"endpoint_name" json:"endpoint_name"`
+	Field         *string   `db:"field" json:"field,omitempty"`
+	OldValue      *string   `db:"old_value" json:"old_value,omitempty"`
+	NewValue      *string   `db:"new_value" json:"new_value,omitempty"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+// StoreEndpointChanges copies the endpoint changes of a snapshot in tx, so they are committed
+// together with the snapshot.
+func StoreEndpointChanges(tx *sqlx.Tx, collectionID string, oldSnapshotID *int64, newSnapshotID int64, changes []EndpointChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("endpoint_changes",
+		"collection_id", "old_snapshot_id", "new_snapshot_id",
+		"event_type", "method", "path", "endpoint_name", "field", "old_value", "new_value",
+	))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, change := range changes {
+		_, err := stmt.Exec(
+			collectionID, oldSnapshotID, newSnapshotID,
+			change.EventType, change.Method, change.Path, change.EndpointName,
+			change.Field, change.OldValue, change.NewValue,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to copy endpoint change %d: %w", i, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("failed to copy endpoint changes: %w", err)
+	}
+
+	slog.Info("Successfully stored endpoint changes",
+		"collection_id", collectionID,
+		"new_snapshot_id", newSnapshotID,
+		"change_count", len(changes))
+
+	return nil
+}
+
+func GetEndpointChanges(collectionID string, snapshotID int64, eventTypes []string) ([]EndpointChange, error) {
+	changes := []EndpointChange{}
+
+	query := `
+		SELECT * FROM endpoint_changes
+		WHERE collection_id = $1 AND new_snapshot_id = $2
+	`
+	args := []interface{}{collectionID, snapshotID}
+
+	if len(eventTypes) > 0 {
+		query += ` AND event_type = ANY($3)`
+		args = append(args, pq.StringArray(eventTypes))
+	}
+	query += ` ORDER BY path, method, id`
+
+	if err := DB.Select(&changes, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get endpoint changes: %w", err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrEnvironmentNotFound         = errors.New("environment not found")
+	ErrEnvironmentSnapshotNotFound = errors.New("environment snapshot not found")
+)
+
+type Environment struct {
+	ID             string    `db:"id" json:"id"`
+	Name           string    `db:"name" json:"name"`
+	UserID         int64     `db:"user_id" json:"user_id"`
+	OrganizationID *int64    `db:"organization_id" json:"organization_id"`
+	FirstSeen      time.Time `db:"first_seen" json:"first_seen"`
+	LastSeen       time.Time `db:"last_seen" json:"last_seen"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// EnvironmentSnapshot is a masked version of a Postman environment. Content is left out of
+// snapshot lists.
+type EnvironmentSnapshot struct {
+	ID            int64           `db:"id" json:"id"`
+	EnvironmentID string          `db:"environment_id" json:"environment_id"`
+	Content       json.RawMessage `db:"content" json:"content,omitempty"`
+	Hash          string          `db:"hash" json:"hash"`
+	VariableCount int             `db:"variable_count" json:"variable_count"`
+	CreatedAt     time.Time       `db:"created_at" json:"created_at"`
+}
+
+// EnvironmentChange is a change to one variable between two environment snapshots. Field names
+// the changed attribute of a modified variable: value, enabled or type. Values are masked.
+type EnvironmentChange struct {
+	ID            int64     `db:"id" json:"id"`
+	EnvironmentID string    `db:"environment_id" json:"environment_id"`
+	OldSnapshotID *int64    `db:"old_snapshot_id" json:"old_snapshot_id"`
+	NewSnapshotID int64     `db:"new_snapshot_id" json:"new_snapshot_id"`
+	ChangeType    string    `db:"change_type" json:"change_type"`
+	VariableKey   string    `db:"variable_key" json:"variable_key"`
+	Field         *string   `db:"field" json:"field"`
+	OldValue      *string   `db:"old_value" json:"old_value"`
+	NewValue      *string   `db:"new_value" json:"new_value"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+func StoreEnvironment(id, name string, scope Scope) error {
+	_, err := DB.Exec(`
+		INSERT INTO environments (id, name, user_id, organization_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE
+		SET name = $2,
+		    last_seen = CURRENT_TIMESTAMP,
+		    updated_at = CURRENT_TIMESTAMP
+	`, id, name, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to store environment: %v", err)
+	}
+	return nil
+}
+
+func GetEnvironments(scope Scope) ([]Environment, error) {
+	environments := []Environment{}
+	err := DB.Select(&environments, `
+		SELECT * FROM environments
+		WHERE `+ownerCondition("", 1, 2)+`
+		ORDER BY name, id
+	`, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environments: %v", err)
+	}
+	return environments, nil
+}
+
+func GetScopedEnvironment(environmentID string, scope Scope) (*Environment, error) {
+	environment := &Environment{}
+	err := DB.Get(environment, `
+		SELECT * FROM environments
+		WHERE id = $1 AND `+ownerCondition("", 2, 3),
+		environmentID, scope.UserID, scope.OrganizationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrEnvironmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment: %v", err)
+	}
+	return environment, nil
+}
+
+func IsEnvironmentOwnedOutsideScope(environmentID string, scope Scope) (bool, error) {
+	var exists bool
+	err := DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM environments
+			WHERE id = $1 AND NOT COALESCE(`+ownerCondition("", 2, 3)+`, false)
+		)
+	`, environmentID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check environment owner: %v", err)
+	}
+	return exists, nil
+}
+
+func GetLatestEnvironmentSnapshot(environmentID string) (*EnvironmentSnapshot, error) {
+	snapshot := &EnvironmentSnapshot{}
+	err := DB.Get(snapshot, `
+		SELECT * FROM environment_snapshots
+		WHERE environment_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, environmentID)
+	if err == sql.ErrNoRows {
+		return nil, ErrEnvironmentSnapshotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest environment snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// CreateEnvironmentSnapshot stores a snapshot together with its changes against the previous
+// snapshot, so a snapshot never exists without its changes.
+func CreateEnvironmentSnapshot(snapshot *EnvironmentSnapshot, oldSnapshotID *int64, changes []EnvironmentChange) (int64, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var snapshotID int64
+	err = tx.QueryRow(`
+		INSERT INTO environment_snapshots (environment_id, content, hash, variable_count)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, snapshot.EnvironmentID, string(snapshot.Content), snapshot.Hash, snapshot.VariableCount).Scan(&snapshotID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create environment snapshot: %v", err)
+	}
+
+	for _, change := range changes {
+		_, err = tx.Exec(`
+			INSERT INTO environment_changes (
+				environment_id, old_snapshot_id, new_snapshot_id, change_type,
+				variable_key, field, old_value, new_value
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, snapshot.EnvironmentID, oldSnapshotID, snapshotID, change.ChangeType,
+			change.VariableKey, change.Field, change.OldValue, change.NewValue)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store environment change: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return snapshotID, nil
+}
+
+func GetEnvironmentSnapshots(environmentID string, limit, offset int) ([]EnvironmentSnapshot, int, error) {
+	var total int
+	err := DB.Get(&total, `SELECT COUNT(*) FROM environment_snapshots WHERE environment_id = $1`, environmentID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count environment snapshots: %v", err)
+	}
+
+	snapshots := []EnvironmentSnapshot{}
+	err = DB.Select(&snapshots, `
+		SELECT id, environment_id, hash, variable_count, created_at
+		FROM environment_snapshots
+		WHERE environment_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, environmentID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get environment snapshots: %v", err)
+	}
+	return snapshots, total, nil
+}
+
+func GetEnvironmentSnapshot(environmentID string, snapshotID int64) (*EnvironmentSnapshot, error) {
+	snapshot := &EnvironmentSnapshot{}
+	err := DB.Get(snapshot, `
+		SELECT * FROM environment_snapshots
+		WHERE id = $1 AND environment_id = $2
+	`, snapshotID, environmentID)
+	if err == sql.ErrNoRows {
+		return nil, ErrEnvironmentSnapshotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment snapshot: %v", err)
+	}
+	return snapshot, nil
+}
+
+// GetEnvironmentChanges lists the changes of an environment, newest first. A snapshotID limits
+// them to the changes that produced that snapshot.
+func GetEnvironmentChanges(environmentID string, snapshotID *int64, limit, offset int) ([]EnvironmentChange, int, error) {
+	var total int
+	err := DB.Get(&total, `
+		SELECT COUNT(*) FROM environment_changes
+		WHERE environment_id = $1 AND ($2::integer IS NULL OR new_snapshot_id = $2)
+	`, environmentID, snapshotID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count environment changes: %v", err)
+	}
+
+	changes := []EnvironmentChange{}
+	err = DB.Select(&changes, `
+		SELECT * FROM environment_changes
+		WHERE environment_id = $1 AND ($2::integer IS NULL OR new_snapshot_id = $2)
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4
+	`, environmentID, snapshotID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get environment changes: %v", err)
+	}
+	return changes, total, nil
+}
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"integratorV2/internal/encryption"
+)
+
+const (
+	KMSKeyActive   = "active"
+	KMSKeyRetiring = "retiring"
+	KMSKeyRetired  = "retired"
+)
+
+var (
+	ErrNoActiveKMSKey        = errors.New("no active KMS key is recorded")
+	ErrKMSRotationStale      = errors.New("KMS key was already rotated")
+	ErrKMSRotationIncomplete = errors.New("API keys are still encrypted with an older KMS key")
+)
+
+// KMSKey is one version of the KMS key that encrypts Postman API keys. The counters track the
+// re-encryption of existing API keys that started when this version became active.
+type KMSKey struct {
+	ID              int64      `db:"id" json:"id"`
+	KeyID           string     `db:"key_id" json:"key_id"`
+	Version         int        `db:"version" json:"version"`
+	Status          string     `db:"status" json:"status"`
+	TotalKeys       int        `db:"total_keys" json:"total_keys"`
+	ReencryptedKeys int        `db:"reencrypted_keys" json:"reencrypted_keys"`
+	FailedKeys      int        `db:"failed_keys" json:"failed_keys"`
+	LastError       *string    `db:"last_error" json:"last_error"`
+	LastRotatedAt   *time.Time `db:"last_rotated_at" json:"last_rotated_at"`
+	NextRotationAt  time.Time  `db:"next_rotation_at" json:"next_rotation_at"`
+	CompletedAt     *time.Time `db:"completed_at" json:"completed_at"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+type KeyVersionCount struct {
+	KeyVersion int `db:"key_version" json:"key_version"`
+	Count      int `db:"count" json:"count"`
+}
+
+type ReencryptionBatch struct {
+	LastID      int64
+	Processed   int
+	Reencrypted int
+	Failed      int
+}
+
+func GetActiveKMSKey() (*KMSKey, error) {
+	key := &KMSKey{}
+	err := DB.Get(key, `SELECT * FROM kms_key_rotation WHERE status = $1`, KMSKeyActive)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoActiveKMSKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active KMS key: %v", err)
+	}
+	return key, nil
+}
+
+// EnsureKMSKey records keyID as the first key version if none is recorded yet and returns the
+// active key. After that the database, not the environment, decides which key is active.
+func EnsureKMSKey(keyID string, nextRotationAt time.Time) (*KMSKey, error) {
+	_, err := DB.Exec(`
+		INSERT INTO kms_key_rotation (key_id, version, status, last_rotated_at, next_rotation_at, completed_at)
+		SELECT $1, 1, $2, CURRENT_TIMESTAMP, $3, CURRENT_TIMESTAMP
+		WHERE NOT EXISTS (SELECT 1 FROM kms_key_rotation)
+		ON CONFLICT DO NOTHING
+	`, keyID, KMSKeyActive, nextRotationAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record KMS key: %v", err)
+	}
+	return GetActiveKMSKey()
+}
+
+func GetKMSKeys() ([]KMSKey, error) {
+	keys := []KMSKey{}
+	err := DB.Select(&keys, `SELECT * FROM kms_key_rotation ORDER BY version DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get KMS keys: %v", err)
+	}
+	return keys, nil
+}
+
+func CountAPIKeysByKeyVersion() ([]KeyVersionCount, error) {
+	counts := []KeyVersionCount{}
+	err := DB.Select(&counts, `
+		SELECT key_version, COUNT(*) AS count
+		FROM postman_api_keys
+		GROUP BY key_version
+		ORDER BY key_version
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count API keys by key version: %v", err)
+	}
+	return counts, nil
+}
+
+// StartKMSRotation makes a new key version active, or returns the active version when its
+// re-encryption has not finished yet so an interrupted rotation resumes. expectedKeyID guards
+// scheduled rotations: if the active key is no longer the one the task was scheduled for,
+// someone else already rotated it. createKey runs while the active row is locked, so replicas
+// never create two keys for the same rotation.
+func StartKMSRotation(expectedKeyID string, nextRotationAt time.Time, createKey func() (string, error)) (*KMSKey, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	active := &KMSKey{}
+	err = tx.Get(active, `SELECT * FROM kms_key_rotation WHERE status = $1 FOR UPDATE`, KMSKeyActive)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoActiveKMSKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock active KMS key: %v", err)
+	}
+
+	if active.CompletedAt == nil {
+		err = tx.Get(active, `
+			UPDATE kms_key_rotation
+			SET failed_keys = 0, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING *
+		`, active.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume KMS rotation: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return active, nil
+	}
+	if expectedKeyID != "" && active.KeyID != expectedKeyID {
+		return nil, ErrKMSRotationStale
+	}
+
+	newKeyID, err := createKey()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE kms_key_rotation
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`, KMSKeyRetiring, active.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire KMS key: %v", err)
+	}
+
+	key := &KMSKey{}
+	err = tx.Get(key, `
+		INSERT INTO kms_key_rotation (key_id, version, status, last_rotated_at, next_rotation_at, total_keys)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP, $4,
+			(SELECT COUNT(*) FROM postman_api_keys WHERE key_version <> $2))
+		RETURNING *
+	`, newKeyID, active.Version+1, KMSKeyActive, nextRotationAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record new KMS key: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return key, nil
+}
+
+// ReencryptAPIKeyBatch moves up to limit API keys with an id above afterID onto key. Rows another
+// worker is re-encrypting are skipped. Keys that fail to decrypt are counted and left as they
+// are so the rest of the batch still moves.
+func ReencryptAPIKeyBatch(key *KMSKey, afterID int64, limit int) (*ReencryptionBatch, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var rows []struct {
+		ID           int64  `db:"id"`
+		EncryptedKey string `db:"encrypted_key"`
+	}
+	err = tx.Select(&rows, `
+		SELECT id, encrypted_key FROM postman_api_keys
+		WHERE key_version <> $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, key.Version, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys to re-encrypt: %v", err)
+	}
+
+	batch := &ReencryptionBatch{LastID: afterID, Processed: len(rows)}
+	var lastError *string
+	for _, row := range rows {
+		batch.LastID = row.ID
+
+		ciphertext, err := reencrypt(row.EncryptedKey, key.KeyID)
+		if err != nil {
+			batch.Failed++
+			msg := fmt.Sprintf("API key %d: %v", row.ID, err)
+			lastError = &msg
+			continue
+		}
+
+		_, err = tx.Exec(`
+			UPDATE postman_api_keys SET encrypted_key = $1, key_version = $2
+			WHERE id = $3
+		`, ciphertext, key.Version, row.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store re-encrypted API key: %v", err)
+		}
+		batch.Reencrypted++
+	}
+
+	_, err = tx.Exec(`
+		UPDATE kms_key_rotation
+		SET reencrypted_keys = reencrypted_keys + $1,
+			failed_keys = failed_keys + $2,
+			last_error = COALESCE($3, last_error),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $4
+	`, batch.Reencrypted, batch.Failed, lastError, key.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record re-encryption progress: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return batch, nil
+}
+
+func reencrypt(ciphertext, keyID string) (string, error) {
+	plaintext, err := encryption.DecryptString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return encryption.EncryptStringWithKey(plaintext, keyID)
+}
+
+// CompleteKMSRotation retires the previous key versions once no API key uses them any more. It
+// returns ErrKMSRotationIncomplete with the number of keys left otherwise.
+func CompleteKMSRotation(key *KMSKey) (int, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var remaining int
+	err = tx.Get(&remaining, `SELECT COUNT(*) FROM postman_api_keys WHERE key_version <> $1`, key.Version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count API keys left to re-encrypt: %v", err)
+	}
+	if remaining > 0 {
+		return remaining, ErrKMSRotationIncomplete
+	}
+
+	_, err = tx.Exec(`
+		UPDATE kms_key_rotation SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE status = $2
+	`, KMSKeyRetired, KMSKeyRetiring)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retire old KMS keys: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE kms_key_rotation
+		SET completed_at = CURRENT_TIMESTAMP, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, key.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to complete KMS rotation: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return 0, nil
+}
+
+// encryptAPIKey encrypts under the key version the database marks active, so a rotation
+// committed by one replica applies to writes on every replica. Providers without managed
+// rotation keep every row on key version 1.
+func encryptAPIKey(apiKey string) (string, int, error) {
+	if active := encryption.Active(); active == nil || active.Provider() != encryption.ProviderAWSKMS {
+		ciphertext, err := encryption.EncryptString(apiKey)
+		return ciphertext, 1, err
+	}
+
+	key, err := GetActiveKMSKey()
+	if err != nil {
+		return "", 0, err
+	}
+	ciphertext, err := encryption.EncryptStringWithKey(apiKey, key.KeyID)
+	return ciphertext, key.Version, err
+}
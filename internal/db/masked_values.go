@@ -0,0 +1,70 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var ErrMaskingKeyNotFound = errors.New("snapshot was stored without reversible masking")
+
+// SnapshotMaskedValue is the original of a masked value, encrypted with the snapshot's masking
+// key. The masking key itself is stored sealed by the configured encryption provider.
+type SnapshotMaskedValue struct {
+	SnapshotID     int64  `db:"snapshot_id" json:"snapshot_id"`
+	Path           string `db:"path" json:"path"`
+	ValueType      string `db:"value_type" json:"value_type"`
+	EncryptedValue string `db:"encrypted_value" json:"-"`
+}
+
+// SaveSnapshotMaskedValues stores the snapshot's sealed masking key and masked originals in tx, so
+// they are committed together with the snapshot.
+func SaveSnapshotMaskedValues(tx *sqlx.Tx, snapshotID int64, encryptedKey string, values []SnapshotMaskedValue) error {
+	_, err := tx.Exec(`
+		INSERT INTO snapshot_masking_keys (snapshot_id, encrypted_key)
+		VALUES ($1, $2)
+	`, snapshotID, encryptedKey)
+	if err != nil {
+		return fmt.Errorf("failed to save masking key: %v", err)
+	}
+
+	for _, value := range values {
+		_, err = tx.Exec(`
+			INSERT INTO snapshot_masked_values (snapshot_id, path, value_type, encrypted_value)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (snapshot_id, path) DO NOTHING
+		`, snapshotID, value.Path, value.ValueType, value.EncryptedValue)
+		if err != nil {
+			return fmt.Errorf("failed to save masked value: %v", err)
+		}
+	}
+	return nil
+}
+
+func GetSnapshotMaskingKey(snapshotID int64) (string, error) {
+	var encryptedKey string
+	err := DB.Get(&encryptedKey, `SELECT encrypted_key FROM snapshot_masking_keys WHERE snapshot_id = $1`, snapshotID)
+	if err == sql.ErrNoRows {
+		return "", ErrMaskingKeyNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get masking key: %v", err)
+	}
+	return encryptedKey, nil
+}
+
+func GetSnapshotMaskedValues(snapshotID int64, paths []string) ([]SnapshotMaskedValue, error) {
+	values := []SnapshotMaskedValue{}
+	err := DB.Select(&values, `
+		SELECT * FROM snapshot_masked_values
+		WHERE snapshot_id = $1 AND path = ANY($2)
+		ORDER BY path
+	`, snapshotID, pq.StringArray(paths))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masked values: %v", err)
+	}
+	return values, nil
+}
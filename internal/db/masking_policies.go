@@ -0,0 +1,140 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrMaskingPolicyNotFound = errors.New("masking policy not found")
+
+// MaskingPolicy stores a masking configuration for one collection, or the default of a user or
+// organization when CollectionID is nil.
+type MaskingPolicy struct {
+	ID             int64           `db:"id" json:"id"`
+	UserID         int64           `db:"user_id" json:"user_id"`
+	OrganizationID *int64          `db:"organization_id" json:"organization_id"`
+	CollectionID   *string         `db:"collection_id" json:"collection_id"`
+	Config         json.RawMessage `db:"config" json:"config"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+func GetCollectionMaskingPolicy(collectionID string) (*MaskingPolicy, error) {
+	policy := &MaskingPolicy{}
+	err := DB.Get(policy, `SELECT * FROM masking_policies WHERE collection_id = $1`, collectionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMaskingPolicyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masking policy: %v", err)
+	}
+	return policy, nil
+}
+
+func SaveCollectionMaskingPolicy(userID int64, collectionID string, config json.RawMessage) (*MaskingPolicy, error) {
+	policy := &MaskingPolicy{}
+	err := DB.Get(policy, `
+		INSERT INTO masking_policies (user_id, collection_id, config)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (collection_id) WHERE collection_id IS NOT NULL
+		DO UPDATE SET user_id = EXCLUDED.user_id, config = EXCLUDED.config, updated_at = CURRENT_TIMESTAMP
+		RETURNING *
+	`, userID, collectionID, string(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save masking policy: %v", err)
+	}
+	return policy, nil
+}
+
+func DeleteCollectionMaskingPolicy(collectionID string) error {
+	result, err := DB.Exec(`DELETE FROM masking_policies WHERE collection_id = $1`, collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete masking policy: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMaskingPolicyNotFound
+	}
+	return nil
+}
+
+func GetDefaultMaskingPolicy(scope Scope) (*MaskingPolicy, error) {
+	policy := &MaskingPolicy{}
+	err := DB.Get(policy, `
+		SELECT * FROM masking_policies
+		WHERE collection_id IS NULL AND `+ownerCondition("", 1, 2),
+		scope.UserID, scope.OrganizationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMaskingPolicyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get default masking policy: %v", err)
+	}
+	return policy, nil
+}
+
+func SaveDefaultMaskingPolicy(scope Scope, config json.RawMessage) (*MaskingPolicy, error) {
+	conflictTarget := `(user_id) WHERE collection_id IS NULL AND organization_id IS NULL`
+	if scope.OrganizationID != nil {
+		conflictTarget = `(organization_id) WHERE collection_id IS NULL AND organization_id IS NOT NULL`
+	}
+
+	policy := &MaskingPolicy{}
+	err := DB.Get(policy, `
+		INSERT INTO masking_policies (user_id, organization_id, config)
+		VALUES ($1, $2, $3)
+		ON CONFLICT `+conflictTarget+`
+		DO UPDATE SET user_id = EXCLUDED.user_id, config = EXCLUDED.config, updated_at = CURRENT_TIMESTAMP
+		RETURNING *
+	`, scope.UserID, scope.OrganizationID, string(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save default masking policy: %v", err)
+	}
+	return policy, nil
+}
+
+func DeleteDefaultMaskingPolicy(scope Scope) error {
+	result, err := DB.Exec(`
+		DELETE FROM masking_policies
+		WHERE collection_id IS NULL AND `+ownerCondition("", 1, 2),
+		scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete default masking policy: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrMaskingPolicyNotFound
+	}
+	return nil
+}
+
+// GetEffectiveMaskingPolicy returns the policy that applies to a collection: its own policy, or
+// else the default of the scope it is imported into.
+func GetEffectiveMaskingPolicy(collectionID string, scope Scope) (*MaskingPolicy, error) {
+	policy := &MaskingPolicy{}
+	err := DB.Get(policy, `
+		SELECT * FROM masking_policies
+		WHERE collection_id = $1
+		OR (collection_id IS NULL AND `+ownerCondition("", 2, 3)+`)
+		ORDER BY collection_id IS NULL
+		LIMIT 1
+	`, collectionID, scope.UserID, scope.OrganizationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMaskingPolicyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masking policy: %v", err)
+	}
+	return policy, nil
+}
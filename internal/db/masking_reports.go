@@ -0,0 +1,55 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrMaskingReportNotFound = errors.New("masking report not found")
+
+// SnapshotMaskingReport records what was masked before a snapshot was stored and which rule
+// masked it. Entries never hold original values.
+type SnapshotMaskingReport struct {
+	SnapshotID     int64           `db:"snapshot_id" json:"snapshot_id"`
+	CollectionID   string          `db:"collection_id" json:"collection_id"`
+	MaskingEnabled bool            `db:"masking_enabled" json:"masking_enabled"`
+	PolicySource   string          `db:"policy_source" json:"policy_source"`
+	PolicyID       *int64          `db:"policy_id" json:"policy_id"`
+	MaskedCount    int             `db:"masked_count" json:"masked_count"`
+	CountsByType   json.RawMessage `db:"counts_by_type" json:"counts_by_type"`
+	Entries        json.RawMessage `db:"entries" json:"entries"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
+
+// SaveSnapshotMaskingReport stores the report in tx, so it is committed together with its snapshot.
+func SaveSnapshotMaskingReport(tx *sqlx.Tx, report *SnapshotMaskingReport) error {
+	_, err := tx.Exec(`
+		INSERT INTO snapshot_masking_reports (
+			snapshot_id, collection_id, masking_enabled, policy_source, policy_id,
+			masked_count, counts_by_type, entries
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, report.SnapshotID, report.CollectionID, report.MaskingEnabled, report.PolicySource, report.PolicyID,
+		report.MaskedCount, string(report.CountsByType), string(report.Entries))
+	if err != nil {
+		return fmt.Errorf("failed to save masking report: %v", err)
+	}
+	return nil
+}
+
+func GetSnapshotMaskingReport(snapshotID int64) (*SnapshotMaskingReport, error) {
+	report := &SnapshotMaskingReport{}
+	err := DB.Get(report, `SELECT * FROM snapshot_masking_reports WHERE snapshot_id = $1`, snapshotID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMaskingReportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get masking report: %v", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,293 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrMemberNotFound     = errors.New("organization member not found")
+	ErrMemberExists       = errors.New("user is already a member of the organization")
+	ErrLastOwner          = errors.New("organization must keep at least one owner")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrCollectionNotFound = errors.New("collection not found")
+)
+
+type Organization struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	CreatedBy int64     `db:"created_by" json:"created_by"`
+	Role      string    `db:"role" json:"role,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID int64     `db:"organization_id" json:"organization_id"`
+	UserID         int64     `db:"user_id" json:"user_id"`
+	Email          string    `db:"email" json:"email"`
+	Role           string    `db:"role" json:"role"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Scope identifies who owns the resources a request works with: the organization selected
+// for the request, or the user's personal workspace when no organization is active.
+type Scope struct {
+	UserID         int64
+	OrganizationID *int64
+}
+
+// Owns reports whether a row owned by userID and orgID belongs to the scope.
+func (s Scope) Owns(userID int64, orgID *int64) bool {
+	if s.OrganizationID != nil {
+		return orgID != nil && *orgID == *s.OrganizationID
+	}
+	return orgID == nil && userID == s.UserID
+}
+
+func IsValidRole(role string) bool {
+	return role == RoleOwner || role == RoleEditor || role == RoleViewer
+}
+
+// ownerCondition matches rows of the scope's owner, using positional parameters userParam and
+// orgParam bound to Scope.UserID and Scope.OrganizationID.
+func ownerCondition(alias string, userParam, orgParam int) string {
+	if alias != "" {
+		alias += "."
+	}
+	return fmt.Sprintf(
+		"(%[1]sorganization_id = $%[3]d OR ($%[3]d::integer IS NULL AND %[1]sorganization_id IS NULL AND %[1]suser_id = $%[2]d))",
+		alias, userParam, orgParam,
+	)
+}
+
+func CreateOrganization(name string, userID int64) (*Organization, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	org := &Organization{Name: name, CreatedBy: userID, Role: RoleOwner}
+	err = tx.QueryRow(`
+		INSERT INTO organizations (name, created_by)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, name, userID).Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+	`, org.ID, userID, RoleOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add organization owner: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return org, nil
+}
+
+func GetUserOrganizations(userID int64) ([]Organization, error) {
+	organizations := []Organization{}
+	err := DB.Select(&organizations, `
+		SELECT o.id, o.name, o.created_by, m.role, o.created_at, o.updated_at
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user organizations: %v", err)
+	}
+	return organizations, nil
+}
+
+func GetOrganizationMember(orgID, userID int64) (*OrganizationMember, error) {
+	member := &OrganizationMember{}
+	err := DB.Get(member, `
+		SELECT m.organization_id, m.user_id, u.email, m.role, m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 AND m.user_id = $2
+	`, orgID, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization member: %w", err)
+	}
+	return member, nil
+}
+
+func GetOrganizationMembers(orgID int64) ([]OrganizationMember, error) {
+	members := []OrganizationMember{}
+	err := DB.Select(&members, `
+		SELECT m.organization_id, m.user_id, u.email, m.role, m.created_at, m.updated_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at
+	`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization members: %v", err)
+	}
+	return members, nil
+}
+
+func AddOrganizationMember(orgID int64, email, role string) (*OrganizationMember, error) {
+	var userID int64
+	err := DB.Get(&userID, `SELECT id FROM users WHERE email = $1`, email)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user: %v", err)
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`, orgID, userID, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add organization member: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrMemberExists
+	}
+
+	return GetOrganizationMember(orgID, userID)
+}
+
+func UpdateOrganizationMemberRole(orgID, userID int64, role string) (*OrganizationMember, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOwnerChange(tx, orgID, userID, role != RoleOwner); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE organization_members
+		SET role = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE organization_id = $2 AND user_id = $3
+	`, role, orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update organization member: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return GetOrganizationMember(orgID, userID)
+}
+
+func RemoveOrganizationMember(orgID, userID int64) error {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockOwnerChange(tx, orgID, userID, true); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2
+	`, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove organization member: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// lockOwnerChange locks the organization's member rows and rejects the change when it would
+// take away the organization's last owner.
+func lockOwnerChange(tx *sqlx.Tx, orgID, userID int64, losesOwner bool) error {
+	var roles []struct {
+		UserID int64  `db:"user_id"`
+		Role   string `db:"role"`
+	}
+	err := tx.Select(&roles, `
+		SELECT user_id, role FROM organization_members
+		WHERE organization_id = $1
+		FOR UPDATE
+	`, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to lock organization members: %v", err)
+	}
+
+	owners := 0
+	found := false
+	isOwner := false
+	for _, r := range roles {
+		if r.Role == RoleOwner {
+			owners++
+		}
+		if r.UserID == userID {
+			found = true
+			isOwner = r.Role == RoleOwner
+		}
+	}
+
+	if !found {
+		return ErrMemberNotFound
+	}
+	if losesOwner && isOwner && owners == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// TransferCollection moves a collection, and with it its snapshots, changes and schedule, to
+// another owner. A nil organization makes it a personal collection of userID.
+func TransferCollection(collectionID string, from Scope, userID int64, orgID *int64) (*Collection, error) {
+	result, err := DB.Exec(`
+		UPDATE collections
+		SET user_id = $1, organization_id = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND `+ownerCondition("", 4, 5),
+		userID, orgID, collectionID, from.UserID, from.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer collection: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrCollectionNotFound
+	}
+
+	return GetScopedCollection(collectionID, Scope{UserID: userID, OrganizationID: orgID})
+}
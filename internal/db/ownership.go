@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrChangeNotFound   = errors.New("change not found")
+)
+
+func CollectionInScope(collectionID string, scope Scope) (bool, error) {
+	var exists bool
+	err := DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM collections
+			WHERE id = $1 AND `+ownerCondition("", 2, 3)+`
+		)
+	`, collectionID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check collection access: %v", err)
+	}
+	return exists, nil
+}
+
+// SnapshotInScope reports whether the snapshot belongs to a collection in the scope. When
+// collectionID is set the snapshot must also belong to that collection.
+func SnapshotInScope(snapshotID int64, collectionID string, scope Scope) (bool, error) {
+	var exists bool
+	err := DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM snapshots s
+			JOIN collections c ON c.id = s.collection_id
+			WHERE s.id = $1
+			AND ($4 = '' OR s.collection_id = $4)
+			AND `+ownerCondition("c", 2, 3)+`
+		)
+	`, snapshotID, scope.UserID, scope.OrganizationID, collectionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check snapshot access: %v", err)
+	}
+	return exists, nil
+}
+
+func ChangeInScope(changeID int64, scope Scope) (bool, error) {
+	var exists bool
+	err := DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM changes ch
+			JOIN collections c ON c.id = ch.collection_id
+			WHERE ch.id = $1 AND `+ownerCondition("c", 2, 3)+`
+		)
+	`, changeID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check change access: %v", err)
+	}
+	return exists, nil
+}
+
+// scopedCollectionIDs selects the IDs of every collection in the scope, for use in IN clauses.
+func scopedCollectionIDs(userParam, orgParam int) string {
+	return `SELECT id FROM collections WHERE ` + ownerCondition("", userParam, orgParam)
+}
+
+func EnvironmentInScope(environmentID string, scope Scope) (bool, error) {
+	var exists bool
+	err := DB.Get(&exists, `
+		SELECT EXISTS(
+			SELECT 1 FROM environments
+			WHERE id = $1 AND `+ownerCondition("", 2, 3)+`
+		)
+	`, environmentID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check environment access: %v", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+// mockDB points DB at a sqlmock connection for the test and restores it afterwards.
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	previous := DB
+	DB = sqlx.NewDb(conn, "postgres")
+	t.Cleanup(func() {
+		DB = previous
+		conn.Close()
+	})
+	return mock
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+var (
+	personalScope = Scope{UserID: 2}
+	orgScope      = Scope{UserID: 2, OrganizationID: int64Ptr(7)}
+)
+
+func TestScopeOwns(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  Scope
+		userID int64
+		orgID  *int64
+		want   bool
+	}{
+		{"own personal row", personalScope, 2, nil, true},
+		{"other user's personal row", personalScope, 3, nil, false},
+		{"organization row from personal scope", personalScope, 2, int64Ptr(7), false},
+		{"row of the scope's organization", orgScope, 3, int64Ptr(7), true},
+		{"row of another organization", orgScope, 2, int64Ptr(8), false},
+		{"own personal row from organization scope", orgScope, 2, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.Owns(tt.userID, tt.orgID); got != tt.want {
+				t.Errorf("Owns(%d, %v) = %v, want %v", tt.userID, tt.orgID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnershipChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		args  []driver.Value
+		check func() (bool, error)
+	}{
+		{
+			name:  "collection outside personal scope",
+			query: `SELECT 1 FROM collections`,
+			args:  []driver.Value{"col-1", int64(2), nil},
+			check: func() (bool, error) { return CollectionInScope("col-1", personalScope) },
+		},
+		{
+			name:  "collection outside organization scope",
+			query: `SELECT 1 FROM collections`,
+			args:  []driver.Value{"col-1", int64(2), int64(7)},
+			check: func() (bool, error) { return CollectionInScope("col-1", orgScope) },
+		},
+		{
+			name:  "snapshot without a collection in the path",
+			query: `JOIN collections c ON c.id = s.collection_id\s+WHERE s.id = \$1\s+AND \(\$4 = '' OR s.collection_id = \$4\)`,
+			args:  []driver.Value{int64(10), int64(2), nil, ""},
+			check: func() (bool, error) { return SnapshotInScope(10, "", personalScope) },
+		},
+		{
+			name:  "snapshot of another collection",
+			query: `JOIN collections c ON c.id = s.collection_id`,
+			args:  []driver.Value{int64(10), int64(2), int64(7), "col-1"},
+			check: func() (bool, error) { return SnapshotInScope(10, "col-1", orgScope) },
+		},
+		{
+			name:  "change outside personal scope",
+			query: `JOIN collections c ON c.id = ch.collection_id`,
+			args:  []driver.Value{int64(20), int64(2), nil},
+			check: func() (bool, error) { return ChangeInScope(20, personalScope) },
+		},
+		{
+			name:  "change outside organization scope",
+			query: `JOIN collections c ON c.id = ch.collection_id`,
+			args:  []driver.Value{int64(20), int64(2), int64(7)},
+			check: func() (bool, error) { return ChangeInScope(20, orgScope) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery(tt.query).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+			ok, err := tt.check()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok {
+				t.Error("resource outside the scope was reported in scope")
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOwnerCondition(t *testing.T) {
+	tests := []struct {
+		alias string
+		want  string
+	}{
+		{"", `(organization_id = $3 OR ($3::integer IS NULL AND organization_id IS NULL AND user_id = $2))`},
+		{"c", `(c.organization_id = $3 OR ($3::integer IS NULL AND c.organization_id IS NULL AND c.user_id = $2))`},
+	}
+	for _, tt := range tests {
+		if got := ownerCondition(tt.alias, 2, 3); got != tt.want {
+			t.Errorf("ownerCondition(%q, 2, 3) = %s, want %s", tt.alias, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteSnapshotScoped(t *testing.T) {
+	tests := []struct {
+		name         string
+		scope        Scope
+		rowsAffected int64
+		wantErr      error
+	}{
+		{"snapshot outside personal scope", personalScope, 0, ErrSnapshotNotFound},
+		{"snapshot outside organization scope", orgScope, 0, ErrSnapshotNotFound},
+		{"snapshot in scope", orgScope, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectExec(`DELETE FROM snapshots\s+WHERE id = \$1\s+AND collection_id IN \(SELECT id FROM collections WHERE`).
+				WithArgs(int64(10), tt.scope.UserID, scopeOrgArg(tt.scope)).
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+
+			err := DeleteSnapshot(10, tt.scope)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeleteSnapshot() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestGetChangeDetailsScoped(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   Scope
+		found   bool
+		wantErr error
+	}{
+		{"change outside personal scope", personalScope, false, ErrChangeNotFound},
+		{"change outside organization scope", orgScope, false, ErrChangeNotFound},
+		{"change in scope", personalScope, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			expect := mock.ExpectQuery(`FROM changes\s+WHERE id = \$1\s+AND collection_id IN \(SELECT id FROM collections WHERE`).
+				WithArgs(int64(20), tt.scope.UserID, scopeOrgArg(tt.scope))
+			if tt.found {
+				expect.WillReturnRows(sqlmock.NewRows([]string{
+					"id", "collection_id", "old_snapshot_id", "new_snapshot_id",
+					"change_type", "path", "modification", "created_at",
+				}).AddRow(20, "col-1", 9, 10, "modified", "item.request", nil, time.Now()))
+			} else {
+				expect.WillReturnError(sql.ErrNoRows)
+			}
+
+			change, err := GetChangeDetails(20, tt.scope)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetChangeDetails() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.found && change.ID != 20 {
+				t.Errorf("GetChangeDetails() returned change %d, want 20", change.ID)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// scopeOrgArg is the driver value the scope's organization is bound as.
+func scopeOrgArg(scope Scope) interface{} {
+	if scope.OrganizationID == nil {
+		return nil
+	}
+	return *scope.OrganizationID
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("collection schedule not found")
+
+type CollectionSchedule struct {
+	ID              int64      `db:"id" json:"id"`
+	UserID          int64      `db:"user_id" json:"user_id"`
+	OrganizationID  *int64     `db:"organization_id" json:"organization_id"`
+	CollectionID    string     `db:"collection_id" json:"collection_id"`
+	CollectionName  string     `db:"collection_name" json:"collection_name"`
+	CronExpression  *string    `db:"cron_expression" json:"cron_expression"`
+	IntervalMinutes *int       `db:"interval_minutes" json:"interval_minutes"`
+	Enabled         bool       `db:"enabled" json:"enabled"`
+	LastRunAt       *time.Time `db:"last_run_at" json:"last_run_at"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+func (s *CollectionSchedule) Cronspec() string {
+	if s.CronExpression != nil && *s.CronExpression != "" {
+		return *s.CronExpression
+	}
+	if s.IntervalMinutes != nil {
+		return fmt.Sprintf("@every %dm", *s.IntervalMinutes)
+	}
+	return ""
+}
+
+const collectionScheduleColumns = `
+	s.id, s.user_id, c.organization_id, s.collection_id, c.name AS collection_name,
+	s.cron_expression, s.interval_minutes, s.enabled, s.last_run_at,
+	s.created_at, s.updated_at
+`
+
+func CreateCollectionSchedule(userID int64, collectionID string, cronExpression *string, intervalMinutes *int, enabled bool) (*CollectionSchedule, error) {
+	_, err := DB.Exec(`
+		INSERT INTO collection_schedules (user_id, collection_id, cron_expression, interval_minutes, enabled)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, collectionID, cronExpression, intervalMinutes, enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create collection schedule: %w", err)
+	}
+	return GetCollectionSchedule(collectionID)
+}
+
+func UpdateCollectionSchedule(collectionID string, cronExpression *string, intervalMinutes *int, enabled bool) (*CollectionSchedule, error) {
+	result, err := DB.Exec(`
+		UPDATE collection_schedules
+		SET cron_expression = $1,
+		    interval_minutes = $2,
+		    enabled = $3,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE collection_id = $4
+	`, cronExpression, intervalMinutes, enabled, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update collection schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrScheduleNotFound
+	}
+
+	return GetCollectionSchedule(collectionID)
+}
+
+func GetCollectionSchedule(collectionID string) (*CollectionSchedule, error) {
+	schedule := &CollectionSchedule{}
+	err := DB.Get(schedule, `
+		SELECT `+collectionScheduleColumns+`
+		FROM collection_schedules s
+		JOIN collections c ON s.collection_id = c.id
+		WHERE s.collection_id = $1
+	`, collectionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+func DeleteCollectionSchedule(collectionID string) error {
+	result, err := DB.Exec(`
+		DELETE FROM collection_schedules
+		WHERE collection_id = $1
+	`, collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection schedule: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func GetScopedCollectionSchedules(scope Scope) ([]CollectionSchedule, error) {
+	schedules := []CollectionSchedule{}
+	err := DB.Select(&schedules, `
+		SELECT `+collectionScheduleColumns+`
+		FROM collection_schedules s
+		JOIN collections c ON s.collection_id = c.id
+		WHERE `+ownerCondition("c", 1, 2)+`
+		ORDER BY s.created_at DESC
+	`, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user collection schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+func GetEnabledCollectionSchedules() ([]CollectionSchedule, error) {
+	var schedules []CollectionSchedule
+	err := DB.Select(&schedules, `
+		SELECT `+collectionScheduleColumns+`
+		FROM collection_schedules s
+		JOIN collections c ON s.collection_id = c.id
+		WHERE s.enabled = true
+		ORDER BY s.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get enabled collection schedules: %v", err)
+	}
+	return schedules, nil
+}
+
+func MarkCollectionScheduleRun(collectionID string) error {
+	_, err := DB.Exec(`
+		UPDATE collection_schedules
+		SET last_run_at = CURRENT_TIMESTAMP
+		WHERE collection_id = $1
+	`, collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to mark collection schedule run: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+type UserSession struct {
+	ID         string     `db:"id" json:"id"`
+	UserID     int64      `db:"user_id" json:"user_id"`
+	Email      string     `db:"email" json:"-"`
+	UserAgent  *string    `db:"user_agent" json:"user_agent"`
+	IPAddress  *string    `db:"ip_address" json:"ip_address"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at"`
+	LastUsedAt time.Time  `db:"last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+func CreateUserSession(sessionID string, userID int64, userAgent, ipAddress string, expiresAt time.Time, tokenHash string) error {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO user_sessions (id, user_id, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+	`, sessionID, userID, userAgent, ipAddress, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, sessionID, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// RotateRefreshToken consumes a refresh token and stores its replacement in the same session.
+// Presenting a token that was already consumed revokes the whole session, since it means the
+// token was copied.
+func RotateRefreshToken(oldHash, newHash string) (*UserSession, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var token struct {
+		SessionID string     `db:"session_id"`
+		ExpiresAt time.Time  `db:"expires_at"`
+		UsedAt    *time.Time `db:"used_at"`
+	}
+	err = tx.Get(&token, `
+		SELECT session_id, expires_at, used_at FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`, oldHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %v", err)
+	}
+
+	if token.UsedAt != nil {
+		if _, err := tx.Exec(`
+			UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND revoked_at IS NULL
+		`, token.SessionID); err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if !token.ExpiresAt.After(time.Now()) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	session := &UserSession{}
+	err = tx.Get(session, `
+		SELECT s.id, s.user_id, u.email, s.user_agent, s.ip_address,
+		       s.expires_at, s.revoked_at, s.last_used_at, s.created_at
+		FROM user_sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+		FOR UPDATE OF s
+	`, token.SessionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %v", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1
+	`, oldHash); err != nil {
+		return nil, fmt.Errorf("failed to consume refresh token: %v", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, session.ID, newHash, session.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE user_sessions SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, session.ID); err != nil {
+		return nil, fmt.Errorf("failed to update session: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return session, nil
+}
+
+func IsUserSessionActive(sessionID string) (bool, error) {
+	var active bool
+	err := DB.Get(&active, `
+		SELECT EXISTS(
+			SELECT 1 FROM user_sessions
+			WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		)
+	`, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %v", err)
+	}
+	return active, nil
+}
+
+func RevokeUserSessionByRefreshToken(tokenHash string) error {
+	result, err := DB.Exec(`
+		UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE revoked_at IS NULL
+		AND id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1)
+	`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenInvalid
+	}
+	return nil
+}
+
+func RevokeAllUserSessions(userID int64) (int64, error) {
+	result, err := DB.Exec(`
+		UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SnapshotEndpoint is a request of a stored snapshot, extracted for endpoint search. ItemPath
+// names the folders leading to the request and the request itself. Header names are lower case.
+type SnapshotEndpoint struct {
+	ItemPath    string
+	Name        string
+	Method      string
+	Path        string
+	HeaderNames []string
+	BodyFields  []string
+}
+
+// SaveSnapshotEndpoints replaces the extracted endpoints of a snapshot.
+func SaveSnapshotEndpoints(collectionID string, snapshotID int64, endpoints []SnapshotEndpoint) error {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM snapshot_endpoints WHERE snapshot_id = $1`, snapshotID); err != nil {
+		return fmt.Errorf("failed to clear snapshot endpoints: %v", err)
+	}
+
+	for _, endpoint := range endpoints {
+		searchText := strings.ToLower(strings.Join([]string{
+			endpoint.Method,
+			endpoint.Path,
+			endpoint.Name,
+			strings.Join(endpoint.HeaderNames, " "),
+			strings.Join(endpoint.BodyFields, " "),
+		}, " "))
+
+		_, err = tx.Exec(`
+			INSERT INTO snapshot_endpoints (
+				snapshot_id, collection_id, item_path, name, method, path,
+				header_names, body_fields, search_text
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, snapshotID, collectionID, endpoint.ItemPath, endpoint.Name, endpoint.Method, endpoint.Path,
+			pq.StringArray(endpoint.HeaderNames), pq.StringArray(endpoint.BodyFields), searchText)
+		if err != nil {
+			return fmt.Errorf("failed to save snapshot endpoint: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// GetUnindexedSnapshots returns snapshots after afterID that have no extracted endpoints, in ID
+// order, for backfilling the search index.
+func GetUnindexedSnapshots(afterID int64, limit int) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+	err := DB.Select(&snapshots, `
+		SELECT s.id, s.collection_id, s.content
+		FROM snapshots s
+		WHERE s.id > $1
+		AND NOT EXISTS (SELECT 1 FROM snapshot_endpoints se WHERE se.snapshot_id = s.id)
+		ORDER BY s.id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unindexed snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+// EndpointSearchFilter selects endpoints of the collections in Scope. Query matches any part of
+// the method, path, request name, header names and body field paths; Path matches any part of
+// the path; Header and Field must equal a header name or a body field path such as customer.id.
+// Unless AllSnapshots is set only the latest snapshot of each collection is searched.
+type EndpointSearchFilter struct {
+	Scope        Scope
+	Query        string
+	Method       string
+	Path         string
+	Header       string
+	Field        string
+	AllSnapshots bool
+	Limit        int
+	Offset       int
+}
+
+// EndpointSearchResult is an endpoint as found in the newest matching snapshot, with the first
+// and last time any snapshot of the collection contained the same method and path.
+type EndpointSearchResult struct {
+	CollectionID   string         `db:"collection_id" json:"collection_id"`
+	CollectionName string         `db:"collection_name" json:"collection_name"`
+	SnapshotID     int64          `db:"snapshot_id" json:"snapshot_id"`
+	ItemPath       string         `db:"item_path" json:"item_path"`
+	Name           string         `db:"name" json:"name"`
+	Method         string         `db:"method" json:"method"`
+	Path           string         `db:"path" json:"path"`
+	HeaderNames    pq.StringArray `db:"header_names" json:"header_names"`
+	BodyFields     pq.StringArray `db:"body_fields" json:"body_fields"`
+	FirstSeen      time.Time      `db:"first_seen" json:"first_seen"`
+	LastSeen       time.Time      `db:"last_seen" json:"last_seen"`
+	SnapshotCount  int            `db:"snapshot_count" json:"snapshot_count"`
+}
+
+func SearchSnapshotEndpoints(filter EndpointSearchFilter) ([]EndpointSearchResult, int, error) {
+	args := []interface{}{filter.Scope.UserID, filter.Scope.OrganizationID}
+	conditions := []string{ownerCondition("c", 1, 2)}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Query != "" {
+		addCondition("se.search_text LIKE $%d", "%"+escapeLike(strings.ToLower(filter.Query))+"%")
+	}
+	if filter.Method != "" {
+		addCondition("se.method = $%d", strings.ToUpper(filter.Method))
+	}
+	if filter.Path != "" {
+		addCondition("se.path ILIKE $%d", "%"+escapeLike(filter.Path)+"%")
+	}
+	if filter.Header != "" {
+		addCondition("se.header_names @> ARRAY[$%d]::text[]", strings.ToLower(filter.Header))
+	}
+	if filter.Field != "" {
+		addCondition("se.body_fields @> ARRAY[$%d]::text[]", filter.Field)
+	}
+	if !filter.AllSnapshots {
+		conditions = append(conditions, `se.snapshot_id = (
+			SELECT latest.id FROM snapshots latest
+			WHERE latest.collection_id = se.collection_id
+			ORDER BY latest.created_at DESC, latest.id DESC
+			LIMIT 1
+		)`)
+	}
+
+	matches := `
+		SELECT DISTINCT ON (se.collection_id, se.method, se.path)
+			se.collection_id, c.name AS collection_name, se.snapshot_id, se.item_path, se.name,
+			se.method, se.path, se.header_names, se.body_fields
+		FROM snapshot_endpoints se
+		JOIN snapshots s ON s.id = se.snapshot_id
+		JOIN collections c ON c.id = se.collection_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY se.collection_id, se.method, se.path, s.created_at DESC, se.id`
+
+	var total int
+	if err := DB.Get(&total, "SELECT COUNT(*) FROM ("+matches+") m", args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count endpoints: %v", err)
+	}
+
+	results := []EndpointSearchResult{}
+	query := fmt.Sprintf(`
+		SELECT m.*, seen.first_seen, seen.last_seen, seen.snapshot_count
+		FROM (%s) m
+		JOIN LATERAL (
+			SELECT MIN(s.created_at) AS first_seen, MAX(s.created_at) AS last_seen,
+				COUNT(DISTINCT s.id) AS snapshot_count
+			FROM snapshot_endpoints se
+			JOIN snapshots s ON s.id = se.snapshot_id
+			WHERE se.collection_id = m.collection_id AND se.method = m.method AND se.path = m.path
+		) seen ON TRUE
+		ORDER BY m.collection_name, m.path, m.method
+		LIMIT $%d OFFSET $%d
+	`, matches, len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, filter.Offset)
+
+	if err := DB.Select(&results, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to search endpoints: %v", err)
+	}
+	return results, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a search term so it matches literally.
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
 	"encoding/json"
 	"log/slog"
@@ -89,12 +90,12 @@ func GetCollectionSnapshots(collectionID string, offset int, pageSize int, page
 	}
 	return response, nil
 }
-func GetSnapshotDetail(snapshotID string) (map[string]interface{}, error) {
+func GetSnapshotDetail(collectionID, snapshotID string) (map[string]interface{}, error) {
 	var snapshot Snapshot
 	err := DB.Get(&snapshot, `
 		SELECT * FROM snapshots
-		WHERE id = $1
-	`, snapshotID)
+		WHERE id = $1 AND collection_id = $2
+	`, snapshotID, collectionID)
 
 	if err != nil {
 		slog.Error("failed to fetch snapshot detail", "error", err)
@@ -360,14 +361,39 @@ func GetSnapshotItemsFlattened(snapshotID string, collectionID string) ([]map[st
 	return items, nil
 }
 
-func DeleteSnapshot(snapshotID int64)error {
+type SnapshotSummary struct {
+	ID             int64     `db:"id" json:"id"`
+	CollectionID   string    `db:"collection_id" json:"collection_id"`
+	CollectionName string    `db:"collection_name" json:"collection_name"`
+	SnapshotTime   time.Time `db:"snapshot_time" json:"snapshot_time"`
+}
+
+func GetSnapshotSummary(snapshotID int64) (*SnapshotSummary, error) {
+	summary := &SnapshotSummary{}
+	err := DB.Get(summary, `
+		SELECT s.id, s.collection_id, c.name AS collection_name, s.snapshot_time
+		FROM snapshots s
+		JOIN collections c ON c.id = s.collection_id
+		WHERE s.id = $1
+	`, snapshotID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %v", err)
+	}
+	return summary, nil
+}
+
+func DeleteSnapshot(snapshotID int64, scope Scope) error {
 
 	result, err := DB.Exec(
 		`
 		DELETE FROM snapshots
 		WHERE id = $1
+		AND collection_id IN (`+scopedCollectionIDs(2, 3)+`)
 	`,
-		snapshotID,
+		snapshotID, scope.UserID, scope.OrganizationID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to delete snapshot: %v", err)
@@ -379,19 +405,34 @@ func DeleteSnapshot(snapshotID int64)error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no data found for snapshot id: %d", snapshotID)
+		return ErrSnapshotNotFound
 	}
 
 	return nil
 }
 
-func DeleteSnapshotChanges(snapshotID int64) error {
+func DeleteSnapshotChanges(snapshotID int64, scope Scope) error {
 	tx, err := DB.Beginx()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %v", err)
 	}
 	defer tx.Rollback()
 
+	var owned bool
+	err = tx.Get(&owned, `
+		SELECT EXISTS(
+			SELECT 1 FROM snapshots
+			WHERE id = $1
+			AND collection_id IN (`+scopedCollectionIDs(2, 3)+`)
+		)
+	`, snapshotID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to check snapshot owner: %v", err)
+	}
+	if !owned {
+		return ErrSnapshotNotFound
+	}
+
 	changesResult, err := tx.Exec(
 		`DELETE FROM changes WHERE old_snapshot_id = $1`,
 		snapshotID,
@@ -420,7 +461,7 @@ func DeleteSnapshotChanges(snapshotID int64) error {
 	}
 
 	if snapshotRowsAffected == 0 {
-		return fmt.Errorf("no snapshot found with id: %d", snapshotID)
+		return ErrSnapshotNotFound
 	}
 
 
@@ -451,4 +492,65 @@ func GetSnapshotDiffID(collectionID string) ([]DiffSnapshotID, error) {
 	}
 
 	return snapshots, nil
-}
\ No newline at end of file
+}
+func GetLatestSnapshot(collectionID string) (*Snapshot, error) {
+	var snapshot Snapshot
+	err := DB.Get(&snapshot, `
+		SELECT * FROM snapshots
+		WHERE collection_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+func GetCollectionSnapshot(collectionID string, snapshotID int64) (*Snapshot, error) {
+	var snapshot Snapshot
+	err := DB.Get(&snapshot, `
+		SELECT * FROM snapshots
+		WHERE id = $1 AND collection_id = $2
+	`, snapshotID, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// SnapshotGap is a snapshot whose content differs from the snapshot before it but that has no
+// changes, as left by an import interrupted between storing the two.
+type SnapshotGap struct {
+	SnapshotID         int64  `db:"snapshot_id"`
+	CollectionID       string `db:"collection_id"`
+	PreviousSnapshotID int64  `db:"previous_snapshot_id"`
+	PreviousHash       string `db:"previous_hash"`
+}
+
+// GetSnapshotsMissingChanges returns the snapshot gaps after afterID in ID order, for repairing.
+func GetSnapshotsMissingChanges(afterID int64, limit int) ([]SnapshotGap, error) {
+	gaps := []SnapshotGap{}
+	err := DB.Select(&gaps, `
+		SELECT s.id AS snapshot_id, s.collection_id,
+			prev.id AS previous_snapshot_id, prev.hash AS previous_hash
+		FROM snapshots s
+		CROSS JOIN LATERAL (
+			SELECT p.id, p.hash FROM snapshots p
+			WHERE p.collection_id = s.collection_id
+			AND (p.created_at, p.id) < (s.created_at, s.id)
+			ORDER BY p.created_at DESC, p.id DESC
+			LIMIT 1
+		) prev
+		WHERE s.id > $1 AND prev.hash <> s.hash
+		AND NOT EXISTS (SELECT 1 FROM changes c WHERE c.new_snapshot_id = s.id)
+		ORDER BY s.id
+		LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshots missing changes: %v", err)
+	}
+	return gaps, nil
+}
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"fmt"
+)
+
+func IsUserAdmin(userID int64) (bool, error) {
+	var isAdmin bool
+	err := DB.Get(&isAdmin, `
+		SELECT COALESCE((SELECT is_admin FROM users WHERE id = $1), false)
+	`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check admin role: %v", err)
+	}
+	return isAdmin, nil
+}
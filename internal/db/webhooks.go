@@ -0,0 +1,248 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"integratorV2/internal/encryption"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrWebhookSecret   = errors.New("webhook secret cannot be decrypted")
+)
+
+// plaintextSecretPrefix starts the secrets of webhooks created before secrets were encrypted.
+const plaintextSecretPrefix = "whsec_"
+
+// Webhook is an endpoint receiving the events of its scope's collections. Secret holds the
+// signing secret sealed by the active encryption provider; SigningSecret decrypts it.
+type Webhook struct {
+	ID             int64          `db:"id" json:"id"`
+	UserID         int64          `db:"user_id" json:"user_id"`
+	OrganizationID *int64         `db:"organization_id" json:"organization_id"`
+	URL            string         `db:"url" json:"url"`
+	Secret         string         `db:"secret" json:"-"`
+	Events         pq.StringArray `db:"events" json:"events"`
+	IsActive       bool           `db:"is_active" json:"is_active"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at" json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `db:"id" json:"id"`
+	WebhookID      int64           `db:"webhook_id" json:"webhook_id"`
+	Event          string          `db:"event" json:"event"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	ResponseStatus *int            `db:"response_status" json:"response_status"`
+	Error          *string         `db:"error" json:"error"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// CreateWebhook stores a webhook of the scope with its signing secret encrypted.
+func CreateWebhook(scope Scope, url, secret string, events []string) (*Webhook, error) {
+	sealed, err := encryption.EncryptString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+
+	webhook := &Webhook{}
+	err = DB.Get(webhook, `
+		INSERT INTO webhooks (user_id, organization_id, url, secret, events)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	`, scope.UserID, scope.OrganizationID, url, sealed, pq.StringArray(events))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// SigningSecret decrypts the secret deliveries are signed with.
+func (w *Webhook) SigningSecret() (string, error) {
+	if strings.HasPrefix(w.Secret, plaintextSecretPrefix) {
+		return w.Secret, nil
+	}
+	secret, err := encryption.DecryptString(w.Secret)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrWebhookSecret, err)
+	}
+	return secret, nil
+}
+
+// SealPlaintextWebhookSecrets encrypts the secrets of webhooks created before secrets were
+// encrypted, and returns how many it sealed.
+func SealPlaintextWebhookSecrets() (int, error) {
+	var webhooks []Webhook
+	err := DB.Select(&webhooks, `SELECT * FROM webhooks WHERE secret LIKE $1`, plaintextSecretPrefix+"%")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get webhooks with plaintext secrets: %v", err)
+	}
+
+	sealed := 0
+	for _, webhook := range webhooks {
+		ciphertext, err := encryption.EncryptString(webhook.Secret)
+		if err != nil {
+			return sealed, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+		}
+		_, err = DB.Exec(`
+			UPDATE webhooks SET secret = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $2 AND secret = $3
+		`, ciphertext, webhook.ID, webhook.Secret)
+		if err != nil {
+			return sealed, fmt.Errorf("failed to update webhook secret: %v", err)
+		}
+		sealed++
+	}
+	if sealed > 0 {
+		slog.Info("Encrypted plaintext webhook secrets", "count", sealed)
+	}
+	return sealed, nil
+}
+
+func GetScopedWebhooks(scope Scope) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	err := DB.Select(&webhooks, `
+		SELECT * FROM webhooks
+		WHERE `+ownerCondition("", 1, 2)+`
+		ORDER BY created_at DESC
+	`, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %v", err)
+	}
+	return webhooks, nil
+}
+
+func GetScopedWebhook(webhookID int64, scope Scope) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := DB.Get(webhook, `
+		SELECT * FROM webhooks
+		WHERE id = $1 AND `+ownerCondition("", 2, 3),
+		webhookID, scope.UserID, scope.OrganizationID)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func DeleteWebhook(webhookID int64, scope Scope) error {
+	result, err := DB.Exec(`
+		DELETE FROM webhooks
+		WHERE id = $1 AND `+ownerCondition("", 2, 3),
+		webhookID, scope.UserID, scope.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// GetActiveWebhooksForEvent returns the scope's active webhooks subscribed to the event. An
+// organization's webhooks are skipped once the member who created them has left it, so a removed
+// member's endpoint stops receiving the organization's data.
+func GetActiveWebhooksForEvent(scope Scope, event string) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := DB.Select(&webhooks, `
+		SELECT w.* FROM webhooks w
+		WHERE `+ownerCondition("w", 1, 2)+`
+		AND w.is_active = true AND $3 = ANY(w.events)
+		AND (w.organization_id IS NULL OR EXISTS (
+			SELECT 1 FROM organization_members m
+			WHERE m.organization_id = w.organization_id AND m.user_id = w.user_id
+		))
+	`, scope.UserID, scope.OrganizationID, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks for event: %v", err)
+	}
+	return webhooks, nil
+}
+
+func CreateWebhookDelivery(webhookID int64, event string, payload json.RawMessage) (int64, error) {
+	var deliveryID int64
+	err := DB.QueryRow(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, webhookID, event, payload).Scan(&deliveryID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return deliveryID, nil
+}
+
+func GetWebhookDelivery(deliveryID int64) (*WebhookDelivery, *Webhook, error) {
+	delivery := &WebhookDelivery{}
+	err := DB.Get(delivery, `SELECT * FROM webhook_deliveries WHERE id = $1`, deliveryID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	webhook := &Webhook{}
+	err = DB.Get(webhook, `SELECT * FROM webhooks WHERE id = $1`, delivery.WebhookID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return delivery, webhook, nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt. errorClass is one of the
+// webhook.ErrorClass values, never the error text.
+func UpdateWebhookDelivery(deliveryID int64, status string, attempts int, responseStatus *int, errorClass *string) error {
+	_, err := DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1,
+		    attempts = $2,
+		    response_status = $3,
+		    error = $4,
+		    delivered_at = CASE WHEN $1 = 'delivered' THEN CURRENT_TIMESTAMP ELSE delivered_at END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+	`, status, attempts, responseStatus, errorClass, deliveryID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %v", err)
+	}
+	return nil
+}
+
+func GetWebhookDeliveries(webhookID int64, limit, offset int) ([]WebhookDelivery, int, error) {
+	var total int
+	err := DB.Get(&total, `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`, webhookID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %v", err)
+	}
+
+	deliveries := []WebhookDelivery{}
+	err = DB.Select(&deliveries, `
+		SELECT * FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get webhook deliveries: %v", err)
+	}
+
+	return deliveries, total, nil
+}
@@ -0,0 +1,159 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	ProviderAWSKMS = "aws-kms"
+	ProviderLocal  = "local"
+)
+
+// Encryptor encrypts secrets stored at rest. KeyVersion identifies the key new ciphertexts are
+// encrypted under, and is handed back to Decrypt so providers can pick the matching key.
+type Encryptor interface {
+	Provider() string
+	KeyVersion() string
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, ciphertext []byte, keyVersion string) ([]byte, error)
+}
+
+// KeyedEncryptor is implemented by providers that can encrypt under a key other than their
+// default one, which key rotation relies on.
+type KeyedEncryptor interface {
+	EncryptWithKey(ctx context.Context, keyVersion string, plaintext []byte) ([]byte, error)
+}
+
+var active Encryptor
+
+// Init selects the encryption provider from ENCRYPTION_PROVIDER. AWS KMS stays the default so
+// existing deployments keep working without new configuration.
+func Init(ctx context.Context) error {
+	provider := os.Getenv("ENCRYPTION_PROVIDER")
+	if provider == "" {
+		provider = ProviderAWSKMS
+	}
+
+	var (
+		encryptor Encryptor
+		err       error
+	)
+	switch provider {
+	case ProviderAWSKMS:
+		encryptor, err = NewKMSEncryptor(ctx)
+	case ProviderLocal:
+		encryptor, err = NewLocalEncryptorFromEnv()
+	default:
+		return fmt.Errorf("unsupported ENCRYPTION_PROVIDER %q, supported providers: %s, %s", provider, ProviderAWSKMS, ProviderLocal)
+	}
+	if err != nil {
+		return err
+	}
+
+	SetEncryptor(encryptor)
+	slog.Info("Initialized secret encryption", "provider", encryptor.Provider(), "key_version", encryptor.KeyVersion())
+	return nil
+}
+
+func SetEncryptor(encryptor Encryptor) {
+	active = encryptor
+}
+
+func Active() Encryptor {
+	return active
+}
+
+// Sealed is a stored ciphertext together with the provider and key version that produced it.
+type Sealed struct {
+	Provider   string
+	KeyVersion string
+	Ciphertext []byte
+}
+
+// String encodes the ciphertext as provider:keyVersion:base64.
+func (s Sealed) String() string {
+	return s.Provider + ":" + url.QueryEscape(s.KeyVersion) + ":" + base64.StdEncoding.EncodeToString(s.Ciphertext)
+}
+
+// ParseSealed decodes a stored ciphertext. Values written before ciphertexts were tagged are
+// bare base64 KMS blobs and are reported as AWS KMS with an unknown key version.
+func ParseSealed(value string) (Sealed, error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) == 1 {
+		ciphertext, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return Sealed{}, fmt.Errorf("failed to decode ciphertext: %v", err)
+		}
+		return Sealed{Provider: ProviderAWSKMS, Ciphertext: ciphertext}, nil
+	}
+	if len(parts) != 3 {
+		return Sealed{}, errors.New("malformed ciphertext")
+	}
+
+	keyVersion, err := url.QueryUnescape(parts[1])
+	if err != nil {
+		return Sealed{}, fmt.Errorf("failed to decode key version: %v", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Sealed{}, fmt.Errorf("failed to decode ciphertext: %v", err)
+	}
+	return Sealed{Provider: parts[0], KeyVersion: keyVersion, Ciphertext: ciphertext}, nil
+}
+
+// EncryptString encrypts a secret with the active provider and returns the tagged ciphertext.
+func EncryptString(plaintext string) (string, error) {
+	if active == nil {
+		return "", errors.New("encryption is not initialized")
+	}
+
+	ciphertext, err := active.Encrypt(context.TODO(), []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return Sealed{Provider: active.Provider(), KeyVersion: active.KeyVersion(), Ciphertext: ciphertext}.String(), nil
+}
+
+// EncryptStringWithKey encrypts a secret under a specific key version of the active provider.
+func EncryptStringWithKey(plaintext, keyVersion string) (string, error) {
+	if active == nil {
+		return "", errors.New("encryption is not initialized")
+	}
+	keyed, ok := active.(KeyedEncryptor)
+	if !ok {
+		return "", fmt.Errorf("provider %q cannot encrypt with a chosen key", active.Provider())
+	}
+
+	ciphertext, err := keyed.EncryptWithKey(context.TODO(), keyVersion, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return Sealed{Provider: active.Provider(), KeyVersion: keyVersion, Ciphertext: ciphertext}.String(), nil
+}
+
+func DecryptString(value string) (string, error) {
+	if active == nil {
+		return "", errors.New("encryption is not initialized")
+	}
+
+	sealed, err := ParseSealed(value)
+	if err != nil {
+		return "", err
+	}
+	if sealed.Provider != active.Provider() {
+		return "", fmt.Errorf("ciphertext was encrypted with provider %q but %q is configured", sealed.Provider, active.Provider())
+	}
+
+	plaintext, err := active.Decrypt(context.TODO(), sealed.Ciphertext, sealed.KeyVersion)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
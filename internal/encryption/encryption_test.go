@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestSealedRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		sealed Sealed
+	}{
+		{"local", Sealed{Provider: ProviderLocal, KeyVersion: "1", Ciphertext: []byte("ciphertext")}},
+		{"kms key ARN", Sealed{Provider: ProviderAWSKMS, KeyVersion: "arn:aws:kms:us-east-1:123456789012:key/abc", Ciphertext: []byte{0, 1, 2}}},
+		{"empty key version", Sealed{Provider: ProviderLocal, Ciphertext: []byte("ciphertext")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseSealed(tt.sealed.String())
+			if err != nil {
+				t.Fatalf("ParseSealed() error = %v", err)
+			}
+			if parsed.Provider != tt.sealed.Provider || parsed.KeyVersion != tt.sealed.KeyVersion || !bytes.Equal(parsed.Ciphertext, tt.sealed.Ciphertext) {
+				t.Errorf("ParseSealed() = %+v, want %+v", parsed, tt.sealed)
+			}
+		})
+	}
+}
+
+func TestParseSealed(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Sealed
+		wantErr string
+	}{
+		{
+			name:  "legacy bare base64 KMS blob",
+			value: base64.StdEncoding.EncodeToString([]byte("legacy")),
+			want:  Sealed{Provider: ProviderAWSKMS, Ciphertext: []byte("legacy")},
+		},
+		{
+			name:  "tagged value",
+			value: "local:v2:" + base64.StdEncoding.EncodeToString([]byte("tagged")),
+			want:  Sealed{Provider: ProviderLocal, KeyVersion: "v2", Ciphertext: []byte("tagged")},
+		},
+		{
+			name:    "legacy value that is not base64",
+			value:   "not base64!",
+			wantErr: "failed to decode ciphertext",
+		},
+		{
+			name:    "missing ciphertext",
+			value:   "local:v2",
+			wantErr: "malformed ciphertext",
+		},
+		{
+			name:    "tagged value that is not base64",
+			value:   "local:v2:not base64!",
+			wantErr: "failed to decode ciphertext",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSealed(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseSealed() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSealed() error = %v", err)
+			}
+			if got.Provider != tt.want.Provider || got.KeyVersion != tt.want.KeyVersion || !bytes.Equal(got.Ciphertext, tt.want.Ciphertext) {
+				t.Errorf("ParseSealed() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	previous := active
+	t.Cleanup(func() { SetEncryptor(previous) })
+
+	encryptor, err := NewLocalEncryptor("1", map[string][]byte{"1": testKey(1)})
+	if err != nil {
+		t.Fatalf("NewLocalEncryptor() error = %v", err)
+	}
+	SetEncryptor(encryptor)
+
+	value, err := EncryptString("PMAK-secret")
+	if err != nil {
+		t.Fatalf("EncryptString() error = %v", err)
+	}
+	if !strings.HasPrefix(value, "local:1:") {
+		t.Errorf("EncryptString() = %q, want a local:1: prefix", value)
+	}
+
+	plaintext, err := DecryptString(value)
+	if err != nil {
+		t.Fatalf("DecryptString() error = %v", err)
+	}
+	if plaintext != "PMAK-secret" {
+		t.Errorf("DecryptString() = %q, want %q", plaintext, "PMAK-secret")
+	}
+
+	// A legacy value is a KMS blob and cannot be opened by the local provider.
+	legacy := base64.StdEncoding.EncodeToString([]byte("legacy"))
+	if _, err := DecryptString(legacy); err == nil || !strings.Contains(err.Error(), `provider "aws-kms"`) {
+		t.Errorf("DecryptString(legacy) error = %v, want a provider mismatch", err)
+	}
+}
@@ -0,0 +1,72 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+type KMSEncryptor struct {
+	client *kms.Client
+	keyID  string
+}
+
+func NewKMSEncryptor(ctx context.Context) (*KMSEncryptor, error) {
+	keyID := os.Getenv("AWS_KMS_KEY_ID")
+	if keyID == "" {
+		return nil, fmt.Errorf("AWS_KMS_KEY_ID environment variable is required for the %s provider", ProviderAWSKMS)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load AWS SDK config: %v", err)
+	}
+
+	return &KMSEncryptor{client: kms.NewFromConfig(cfg), keyID: keyID}, nil
+}
+
+func (e *KMSEncryptor) Provider() string {
+	return ProviderAWSKMS
+}
+
+func (e *KMSEncryptor) KeyVersion() string {
+	return e.keyID
+}
+
+func (e *KMSEncryptor) Client() *kms.Client {
+	return e.client
+}
+
+func (e *KMSEncryptor) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return e.EncryptWithKey(ctx, e.keyID, plaintext)
+}
+
+func (e *KMSEncryptor) EncryptWithKey(ctx context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	result, err := e.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(keyID),
+		Plaintext: plaintext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt with KMS: %v", err)
+	}
+	return result.CiphertextBlob, nil
+}
+
+// Decrypt lets KMS find the key from the ciphertext blob when the key version is unknown, which
+// is the case for values stored before ciphertexts were tagged.
+func (e *KMSEncryptor) Decrypt(ctx context.Context, ciphertext []byte, keyVersion string) ([]byte, error) {
+	input := &kms.DecryptInput{CiphertextBlob: ciphertext}
+	if keyVersion != "" {
+		input.KeyId = aws.String(keyVersion)
+	}
+
+	result, err := e.client.Decrypt(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt with KMS: %v", err)
+	}
+	return result.Plaintext, nil
+}
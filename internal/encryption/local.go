@@ -0,0 +1,191 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	dataKeySize = 32
+	nonceSize   = 12
+	// wrappedKeySize is the data key sealed by AES-GCM, which appends a 16 byte tag.
+	wrappedKeySize = dataKeySize + 16
+)
+
+// LocalEncryptor is envelope encryption without a cloud KMS. Every value gets its own random
+// data key; the data key is sealed with the master key and stored next to the ciphertext:
+//
+//	wrap nonce | wrapped data key | data nonce | ciphertext
+type LocalEncryptor struct {
+	version    string
+	masterKeys map[string][]byte
+}
+
+// NewLocalEncryptor encrypts with the master key of version and decrypts with any key in
+// masterKeys, so old master keys can be kept around while values are re-encrypted.
+func NewLocalEncryptor(version string, masterKeys map[string][]byte) (*LocalEncryptor, error) {
+	if _, ok := masterKeys[version]; !ok {
+		return nil, fmt.Errorf("no master key for version %q", version)
+	}
+	for v, k := range masterKeys {
+		if len(k) != 32 {
+			return nil, fmt.Errorf("master key %q must be 32 bytes, got %d", v, len(k))
+		}
+	}
+	return &LocalEncryptor{version: version, masterKeys: masterKeys}, nil
+}
+
+// NewLocalEncryptorFromEnv reads a base64 encoded 32 byte master key from LOCAL_ENCRYPTION_KEY,
+// or from the file named by LOCAL_ENCRYPTION_KEY_FILE. LOCAL_ENCRYPTION_KEY_VERSION names the
+// key and defaults to "1". Previous master keys, still needed to decrypt values sealed under
+// them, are listed in LOCAL_ENCRYPTION_KEYS as comma separated version:base64 pairs.
+func NewLocalEncryptorFromEnv() (*LocalEncryptor, error) {
+	masterKeys, err := parseLocalKeys(os.Getenv("LOCAL_ENCRYPTION_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+
+	version := os.Getenv("LOCAL_ENCRYPTION_KEY_VERSION")
+	if version == "" {
+		version = "1"
+	}
+
+	encoded := os.Getenv("LOCAL_ENCRYPTION_KEY")
+	if path := os.Getenv("LOCAL_ENCRYPTION_KEY_FILE"); encoded == "" && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read local encryption key file: %v", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+	if encoded == "" {
+		if _, ok := masterKeys[version]; !ok {
+			return nil, fmt.Errorf("LOCAL_ENCRYPTION_KEY or LOCAL_ENCRYPTION_KEY_FILE is required for the %s provider", ProviderLocal)
+		}
+		return NewLocalEncryptor(version, masterKeys)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("local encryption key must be base64 encoded: %v", err)
+	}
+	if previous, ok := masterKeys[version]; ok && !bytes.Equal(previous, key) {
+		return nil, fmt.Errorf("LOCAL_ENCRYPTION_KEYS has a different key for the current version %q", version)
+	}
+	masterKeys[version] = key
+	return NewLocalEncryptor(version, masterKeys)
+}
+
+// parseLocalKeys decodes a comma separated list of version:base64 master keys.
+func parseLocalKeys(value string) (map[string][]byte, error) {
+	masterKeys := map[string][]byte{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		version, encoded, ok := strings.Cut(entry, ":")
+		if !ok || version == "" {
+			return nil, errors.New("LOCAL_ENCRYPTION_KEYS entries must look like version:base64key")
+		}
+		if _, exists := masterKeys[version]; exists {
+			return nil, fmt.Errorf("LOCAL_ENCRYPTION_KEYS lists version %q more than once", version)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("local encryption key %q must be base64 encoded: %v", version, err)
+		}
+		masterKeys[version] = key
+	}
+	return masterKeys, nil
+}
+
+func (e *LocalEncryptor) Provider() string {
+	return ProviderLocal
+}
+
+func (e *LocalEncryptor) KeyVersion() string {
+	return e.version
+}
+
+func (e *LocalEncryptor) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+
+	wrapped, err := seal(e.masterKeys[e.version], dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %v", err)
+	}
+	sealed, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %v", err)
+	}
+	return append(wrapped, sealed...), nil
+}
+
+func (e *LocalEncryptor) Decrypt(ctx context.Context, ciphertext []byte, keyVersion string) ([]byte, error) {
+	if keyVersion == "" {
+		keyVersion = e.version
+	}
+	masterKey, ok := e.masterKeys[keyVersion]
+	if !ok {
+		return nil, fmt.Errorf("no local master key for version %q", keyVersion)
+	}
+
+	wrappedLen := nonceSize + wrappedKeySize
+	if len(ciphertext) < wrappedLen+nonceSize {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	dataKey, err := open(masterKey, ciphertext[:wrappedLen])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+	plaintext, err := open(dataKey, ciphertext[wrappedLen:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+	return plaintext, nil
+}
+
+// seal returns nonce | AES-GCM ciphertext.
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < nonceSize {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return gcm.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
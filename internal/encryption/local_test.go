@@ -0,0 +1,196 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestLocalEncryptorRoundTrip(t *testing.T) {
+	encryptor, err := NewLocalEncryptor("1", map[string][]byte{"1": testKey(1)})
+	if err != nil {
+		t.Fatalf("NewLocalEncryptor() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"empty", []byte{}},
+		{"api key", []byte("PMAK-0123456789abcdef")},
+		{"binary", []byte{0, 1, 2, 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ciphertext, err := encryptor.Encrypt(context.Background(), tt.plaintext)
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+			if len(tt.plaintext) > 0 && bytes.Contains(ciphertext, tt.plaintext) {
+				t.Error("ciphertext contains the plaintext")
+			}
+
+			plaintext, err := encryptor.Decrypt(context.Background(), ciphertext, "1")
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !bytes.Equal(plaintext, tt.plaintext) {
+				t.Errorf("Decrypt() = %q, want %q", plaintext, tt.plaintext)
+			}
+		})
+	}
+}
+
+func TestLocalEncryptorDecryptsPreviousKeys(t *testing.T) {
+	old, err := NewLocalEncryptor("1", map[string][]byte{"1": testKey(1)})
+	if err != nil {
+		t.Fatalf("NewLocalEncryptor() error = %v", err)
+	}
+	ciphertext, err := old.Encrypt(context.Background(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	rotated, err := NewLocalEncryptor("2", map[string][]byte{"1": testKey(1), "2": testKey(2)})
+	if err != nil {
+		t.Fatalf("NewLocalEncryptor() error = %v", err)
+	}
+	plaintext, err := rotated.Decrypt(context.Background(), ciphertext, "1")
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(plaintext) != "secret" {
+		t.Errorf("Decrypt() = %q, want %q", plaintext, "secret")
+	}
+}
+
+func TestLocalEncryptorDecryptWrongKeyVersion(t *testing.T) {
+	encryptor, err := NewLocalEncryptor("2", map[string][]byte{"1": testKey(1), "2": testKey(2)})
+	if err != nil {
+		t.Fatalf("NewLocalEncryptor() error = %v", err)
+	}
+	ciphertext, err := encryptor.Encrypt(context.Background(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		keyVersion string
+		wantErr    string
+	}{
+		{"other known version", "1", "failed to unwrap data key"},
+		{"unknown version", "3", `no local master key for version "3"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := encryptor.Decrypt(context.Background(), ciphertext, tt.keyVersion)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewLocalEncryptorFromEnv(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString(testKey(1))
+	key2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantVersion  string
+		wantVersions []string
+		wantErr      string
+	}{
+		{
+			name:         "single key",
+			env:          map[string]string{"LOCAL_ENCRYPTION_KEY": key1},
+			wantVersion:  "1",
+			wantVersions: []string{"1"},
+		},
+		{
+			name: "current key with previous keys",
+			env: map[string]string{
+				"LOCAL_ENCRYPTION_KEY":         key2,
+				"LOCAL_ENCRYPTION_KEY_VERSION": "v2",
+				"LOCAL_ENCRYPTION_KEYS":        "v1:" + key1,
+			},
+			wantVersion:  "v2",
+			wantVersions: []string{"v1", "v2"},
+		},
+		{
+			name: "current key taken from the key list",
+			env: map[string]string{
+				"LOCAL_ENCRYPTION_KEY_VERSION": "v2",
+				"LOCAL_ENCRYPTION_KEYS":        "v1:" + key1 + ", v2:" + key2,
+			},
+			wantVersion:  "v2",
+			wantVersions: []string{"v1", "v2"},
+		},
+		{
+			name:    "no key",
+			env:     map[string]string{},
+			wantErr: "LOCAL_ENCRYPTION_KEY or LOCAL_ENCRYPTION_KEY_FILE is required",
+		},
+		{
+			name:    "malformed key list",
+			env:     map[string]string{"LOCAL_ENCRYPTION_KEY": key1, "LOCAL_ENCRYPTION_KEYS": key2},
+			wantErr: "must look like version:base64key",
+		},
+		{
+			name:    "duplicate version",
+			env:     map[string]string{"LOCAL_ENCRYPTION_KEY": key1, "LOCAL_ENCRYPTION_KEYS": "v1:" + key1 + ",v1:" + key2},
+			wantErr: `lists version "v1" more than once`,
+		},
+		{
+			name: "conflicting current key",
+			env: map[string]string{
+				"LOCAL_ENCRYPTION_KEY":         key1,
+				"LOCAL_ENCRYPTION_KEY_VERSION": "v2",
+				"LOCAL_ENCRYPTION_KEYS":        "v2:" + key2,
+			},
+			wantErr: `different key for the current version "v2"`,
+		},
+		{
+			name:    "short key",
+			env:     map[string]string{"LOCAL_ENCRYPTION_KEY": key1, "LOCAL_ENCRYPTION_KEYS": "v0:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+			wantErr: `master key "v0" must be 32 bytes`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"LOCAL_ENCRYPTION_KEY", "LOCAL_ENCRYPTION_KEY_FILE", "LOCAL_ENCRYPTION_KEY_VERSION", "LOCAL_ENCRYPTION_KEYS"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			encryptor, err := NewLocalEncryptorFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewLocalEncryptorFromEnv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewLocalEncryptorFromEnv() error = %v", err)
+			}
+			if encryptor.KeyVersion() != tt.wantVersion {
+				t.Errorf("KeyVersion() = %q, want %q", encryptor.KeyVersion(), tt.wantVersion)
+			}
+			if len(encryptor.masterKeys) != len(tt.wantVersions) {
+				t.Errorf("loaded %d master keys, want %d", len(encryptor.masterKeys), len(tt.wantVersions))
+			}
+			for _, version := range tt.wantVersions {
+				if _, ok := encryptor.masterKeys[version]; !ok {
+					t.Errorf("master key %q was not loaded", version)
+				}
+			}
+		})
+	}
+}
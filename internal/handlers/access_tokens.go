@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxAccessTokenLifetimeDays = 365
+
+type AccessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days"`
+}
+
+type AccessTokenCreatedResponse struct {
+	*db.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+func (r *AccessTokenRequest) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(r.Scopes) == 0 {
+		return fmt.Errorf("scopes is required, supported scopes: %s", strings.Join(auth.TokenScopes, ", "))
+	}
+	for _, scope := range r.Scopes {
+		if !auth.IsValidTokenScope(scope) {
+			return fmt.Errorf("unsupported scope %q, supported scopes: %s", scope, strings.Join(auth.TokenScopes, ", "))
+		}
+	}
+
+	if r.ExpiresInDays != nil && (*r.ExpiresInDays < 1 || *r.ExpiresInDays > maxAccessTokenLifetimeDays) {
+		return fmt.Errorf("expires_in_days must be between 1 and %d", maxAccessTokenLifetimeDays)
+	}
+	return nil
+}
+
+func CreateAccessToken(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	var req AccessTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	plain, token, err := auth.CreatePersonalAccessToken(userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		slog.Error("Failed to create access token", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create access token"})
+	}
+
+	slog.Info("Created access token", "user_id", userID, "token_id", token.ID, "scopes", req.Scopes)
+	return c.JSON(http.StatusCreated, AccessTokenCreatedResponse{PersonalAccessToken: token, Token: plain})
+}
+
+func GetAccessTokens(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	tokens, err := db.GetUserPersonalAccessTokens(userID)
+	if err != nil {
+		slog.Error("Failed to get access tokens", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get access tokens"})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func RevokeAccessToken(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid token ID"})
+	}
+
+	err = db.RevokePersonalAccessToken(tokenID, userID)
+	if errors.Is(err, db.ErrAccessTokenNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Access token not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to revoke access token", "error", err, "user_id", userID, "token_id", tokenID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke access token"})
+	}
+
+	slog.Info("Revoked access token", "user_id", userID, "token_id", tokenID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "Access token revoked successfully"})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
+	"integratorV2/internal/queue"
+
+	"github.com/labstack/echo/v4"
+)
+
+type KMSRotationStatusResponse struct {
+	Provider         string               `json:"provider"`
+	ActiveKey        *db.KMSKey           `json:"active_key"`
+	InProgress       bool                 `json:"in_progress"`
+	Keys             []db.KMSKey          `json:"keys"`
+	APIKeysByVersion []db.KeyVersionCount `json:"api_keys_by_version"`
+}
+
+func GetKMSRotationStatus(c echo.Context) error {
+	response := KMSRotationStatusResponse{
+		Provider: encryption.Active().Provider(),
+		Keys:     []db.KMSKey{},
+	}
+
+	counts, err := db.CountAPIKeysByKeyVersion()
+	if err != nil {
+		slog.Error("Failed to count API keys by key version", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get rotation status"})
+	}
+	response.APIKeysByVersion = counts
+
+	if response.Provider != encryption.ProviderAWSKMS {
+		return c.JSON(http.StatusOK, response)
+	}
+
+	keys, err := db.GetKMSKeys()
+	if err != nil {
+		slog.Error("Failed to get KMS keys", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get rotation status"})
+	}
+	response.Keys = keys
+
+	for i := range keys {
+		if keys[i].Status == db.KMSKeyActive {
+			response.ActiveKey = &keys[i]
+			response.InProgress = keys[i].CompletedAt == nil
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func TriggerKMSRotation(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	if encryption.Active().Provider() != encryption.ProviderAWSKMS {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Key rotation requires the aws-kms encryption provider"})
+	}
+
+	active, err := db.GetActiveKMSKey()
+	if errors.Is(err, db.ErrNoActiveKMSKey) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "No active KMS key is recorded yet"})
+	}
+	if err != nil {
+		slog.Error("Failed to get active KMS key", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start rotation"})
+	}
+
+	if err := queue.EnqueueKMSRotation(active.KeyID); err != nil {
+		slog.Error("Failed to enqueue KMS rotation", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start rotation"})
+	}
+
+	slog.Info("Queued KMS rotation", "user_id", userID, "key_id", active.KeyID, "version", active.Version)
+	return c.JSON(http.StatusAccepted, map[string]string{"message": "KMS rotation queued"})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxAuditExportRows = 10000
+
+// GetAuditEvents lists audit events of the active scope. Organization events are visible to
+// owners only, and administrators see every event unless they pick an organization.
+func GetAuditEvents(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	if !canManageOrganization(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can read the audit log"})
+	}
+
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	isAdmin, err := db.IsUserAdmin(userID)
+	if err != nil {
+		slog.Error("Failed to check admin role", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get audit events"})
+	}
+	if !isAdmin || scope.OrganizationID != nil {
+		filter.Scope = &scope
+	}
+
+	if c.QueryParam("format") == "csv" {
+		filter.Limit = maxAuditExportRows
+		filter.Offset = 0
+		return exportAuditEvents(c, filter)
+	}
+
+	events, total, err := db.GetAuditEvents(filter)
+	if err != nil {
+		slog.Error("Failed to get audit events", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get audit events"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"events": events,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+func parseAuditFilter(c echo.Context) (db.AuditFilter, error) {
+	filter := db.AuditFilter{
+		Action:       c.QueryParam("action"),
+		TargetType:   c.QueryParam("target_type"),
+		TargetID:     c.QueryParam("target_id"),
+		CollectionID: c.QueryParam("collection_id"),
+		Outcome:      c.QueryParam("outcome"),
+		Limit:        50,
+	}
+
+	if v := c.QueryParam("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id")
+		}
+		filter.UserID = &id
+	}
+	var err error
+	if filter.From, err = parseTimeParam(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeParam(c, "to"); err != nil {
+		return filter, err
+	}
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 500 {
+		filter.Limit = l
+	}
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		filter.Offset = o
+	}
+	return filter, nil
+}
+
+func parseTimeParam(c echo.Context, name string) (*time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return &t, nil
+}
+
+func exportAuditEvents(c echo.Context, filter db.AuditFilter) error {
+	events, _, err := db.GetAuditEvents(filter)
+	if err != nil {
+		slog.Error("Failed to export audit events", "error", err, "user_id", c.Get("user_id"))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to export audit events"})
+	}
+
+	c.Response().Header().Set("Content-Type", "text/csv")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit_%s.csv\"", time.Now().UTC().Format("20060102T150405Z")))
+	c.Response().WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Response().Writer)
+	w.Write([]string{
+		"ID", "Time", "User ID", "User Email", "Organization ID", "Action", "Target Type", "Target ID",
+		"Collection ID", "Outcome", "Error", "IP Address", "User Agent", "Details",
+	})
+	for _, e := range events {
+		w.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.Format(time.RFC3339),
+			formatOptionalInt(e.UserID),
+			formatOptional(e.UserEmail),
+			formatOptionalInt(e.OrganizationID),
+			e.Action,
+			e.TargetType,
+			csvSafe(formatOptional(e.TargetID)),
+			csvSafe(formatOptional(e.CollectionID)),
+			e.Outcome,
+			csvSafe(formatOptional(e.Error)),
+			formatOptional(e.IPAddress),
+			csvSafe(formatOptional(e.UserAgent)),
+			csvSafe(string(e.Details)),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvSafe stops spreadsheet applications from evaluating client-controlled values such as the
+// user agent as formulas.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func formatOptional(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func formatOptionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
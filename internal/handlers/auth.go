@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"	
@@ -81,10 +84,70 @@ func Login(c echo.Context) error {
 	}
 
 	
-	token, err := auth.GenerateToken(user)
+	tokens, err := auth.StartSession(user, c.Request().UserAgent(), c.RealIP())
 	if err != nil {
+		slog.Error("Failed to start session", "error", err, "user_id", user.ID)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate token"})
 	}
 
-	return c.JSON(http.StatusOK, auth.LoginResponse{Token: token})
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func RefreshToken(c echo.Context) error {
+	var req auth.RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Refresh token is required"})
+	}
+
+	tokens, err := auth.RefreshSession(req.RefreshToken)
+	if errors.Is(err, db.ErrRefreshTokenReused) {
+		slog.Warn("Refresh token reuse detected, session revoked", "ip", c.RealIP())
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Refresh token was already used. Please log in again."})
+	}
+	if errors.Is(err, db.ErrRefreshTokenInvalid) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid or expired refresh token"})
+	}
+	if err != nil {
+		slog.Error("Failed to refresh session", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh token"})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+func Logout(c echo.Context) error {
+	var req auth.RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Refresh token is required"})
+	}
+
+	err := auth.EndSession(req.RefreshToken)
+	if err != nil && !errors.Is(err, db.ErrRefreshTokenInvalid) {
+		slog.Error("Failed to end session", "error", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to log out"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Logged out successfully"})
+}
+
+func LogoutAll(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	revoked, err := auth.EndAllSessions(userID)
+	if err != nil {
+		slog.Error("Failed to end sessions", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to log out"})
+	}
+
+	slog.Info("Logged out all sessions", "user_id", userID, "sessions", revoked)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message":          "All sessions logged out successfully",
+		"revoked_sessions": revoked,
+	})
 }
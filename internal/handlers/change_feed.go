@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetChangeFeed lists the collection and environment changes of the active scope, newest first.
+func GetChangeFeed(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	filter := db.ChangeFeedFilter{
+		Scope:  scope,
+		Source: c.QueryParam("source"),
+		Limit:  50,
+	}
+	if filter.Source != "" && filter.Source != db.ChangeSourceCollection && filter.Source != db.ChangeSourceEnvironment {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "source must be collection or environment"})
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(c, "from"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if filter.To, err = parseTimeParam(c, "to"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		filter.Limit = l
+	}
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		filter.Offset = o
+	}
+
+	entries, total, err := db.GetChangeFeed(filter)
+	if err != nil {
+		slog.Error("Failed to get change feed", "error", err, "user_id", scope.UserID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get changes"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"changes": entries,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
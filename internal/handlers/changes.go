@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"integratorV2/internal/auth"
 	"integratorV2/internal/db"
 	"net/http"
 	"log/slog"
@@ -224,8 +226,11 @@ func GetChangeDetails(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid change ID")
 	}
 
-	change, err := db.GetChangeDetails(id)
+	change, err := db.GetChangeDetails(id, auth.ScopeFromContext(c))
 	
+	if errors.Is(err, db.ErrChangeNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Change not found")
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "get change details failed")
 	}
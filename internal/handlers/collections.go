@@ -2,13 +2,16 @@ package handlers
 
 import (
 	// "encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
-	"integratorV2/internal/config"
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
 	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
 	"integratorV2/internal/postman"
 	"integratorV2/internal/queue"
 
@@ -23,6 +26,12 @@ type RotateAPIKeyRequest struct {
 	NewAPIKey string `json:"new_api_key" validate:"required"`
 }
 
+type BulkImportJobResponse struct {
+	*db.CollectionJob
+	Progress *db.JobProgress    `json:"progress"`
+	Children []db.CollectionJob `json:"children"`
+}
+
 type StoreCollectionRequest struct {
 	CollectionID string `json:"collection_id" validate:"required"`
 	Name         string `json:"name" validate:"required"`
@@ -60,7 +69,13 @@ func getPageSize(c echo.Context) int {
 
 func StoreAPIKey(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	if !canManageOrganization(c) {
+		audit.Record(c, audit.Event{Action: audit.ActionAPIKeyStore, TargetType: audit.TargetAPIKey, Outcome: audit.OutcomeDenied})
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can manage API keys"})
+	}
 
 	var req APIKeyRequest
 	if err := c.Bind(&req); err != nil {
@@ -74,10 +89,12 @@ func StoreAPIKey(c echo.Context) error {
 	}
 
 	
-	if err := db.StorePostmanAPIKey(userID, req.APIKey); err != nil {
+	if err := db.StorePostmanAPIKey(scope, req.APIKey); err != nil {
 		slog.Error("Failed to store API key", "error", err, "user_id", userID)
+		audit.Record(c, audit.Event{Action: audit.ActionAPIKeyStore, TargetType: audit.TargetAPIKey, Outcome: audit.OutcomeFailure, Err: err})
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store API key"})
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionAPIKeyStore, TargetType: audit.TargetAPIKey})
 
 	slog.Info("Successfully stored API key", "user_id", userID)
 	return c.JSON(http.StatusOK, map[string]string{"message": "API key stored successfully"})
@@ -85,7 +102,13 @@ func StoreAPIKey(c echo.Context) error {
 
 func RotateAPIKey(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	if !canManageOrganization(c) {
+		audit.Record(c, audit.Event{Action: audit.ActionAPIKeyRotate, TargetType: audit.TargetAPIKey, Outcome: audit.OutcomeDenied})
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can manage API keys"})
+	}
 
 	var req RotateAPIKeyRequest
 	if err := c.Bind(&req); err != nil {
@@ -98,10 +121,12 @@ func RotateAPIKey(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "New API key is required"})
 	}
 
-	if err := db.RotateAPIKey(userID, req.NewAPIKey); err != nil {
+	if err := db.RotateAPIKey(scope, req.NewAPIKey); err != nil {
 		slog.Error("Failed to rotate API key", "error", err, "user_id", userID)
+		audit.Record(c, audit.Event{Action: audit.ActionAPIKeyRotate, TargetType: audit.TargetAPIKey, Outcome: audit.OutcomeFailure, Err: err})
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to rotate API key"})
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionAPIKeyRotate, TargetType: audit.TargetAPIKey})
 
 	slog.Info("Successfully rotated API key", "user_id", userID)
 	return c.JSON(http.StatusOK, map[string]string{"message": "API key rotated successfully"})
@@ -109,15 +134,16 @@ func RotateAPIKey(c echo.Context) error {
 
 func GetCollections(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
 
-	apiKey, err := db.GetPostmanAPIKey(userID)
+	apiKey, err := db.GetPostmanAPIKey(scope)
 	if err != nil {
 		slog.Warn("No active API key found", "error", err, "user_id", userID)
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No active API key found. Please store your Postman API key first."})
 	}
 
-	if err := db.UpdateLastUsedAPIKey(userID); err != nil {
+	if err := db.UpdateLastUsedAPIKey(scope); err != nil {
 		slog.Error("Failed to update API key usage", "error", err, "user_id", userID)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update API key usage"})
 	}
@@ -134,9 +160,10 @@ func GetCollections(c echo.Context) error {
 
 func SaveCollection(c echo.Context) error {
 
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
 
-	_, err := db.GetPostmanAPIKey(userID)
+	_, err := db.GetPostmanAPIKey(scope)
 	if err != nil {
 		slog.Error("No API key found", "error", err, "user_id", userID)
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No API key found. Please store your Postman API key first."})
@@ -158,26 +185,76 @@ func SaveCollection(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Collection name is required"})
 	}
 
+	ownedElsewhere, err := db.IsCollectionOwnedOutsideScope(req.CollectionID, scope)
+	if err != nil {
+		slog.Error("Failed to check collection owner", "error", err, "user_id", userID, "collection_id", req.CollectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+	if ownedElsewhere {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This collection is already tracked by another user or organization. Ask its owner to transfer it."})
+	}
+
+	job, created, err := db.CreateCollectionJobUnlessActive(scope, req.CollectionID, req.Name)
+	if err != nil {
+		slog.Error("Failed to create collection job", "error", err, "user_id", userID, "collection_id", req.CollectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+	if !created {
+		slog.Info("Collection import already in progress", "user_id", userID, "collection_id", req.CollectionID, "job_id", job.ID)
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Collection import already in progress",
+			"job_id":  job.ID,
+			"task_id": job.TaskID,
+			"status":  job.Status,
+		})
+	}
+
 	payload := queue.CollectionImportPayload{
-		UserID:       userID,
-		CollectionID: req.CollectionID,
-		Name:         req.Name,
+		JobID:          job.ID,
+		UserID:         userID,
+		OrganizationID: scope.OrganizationID,
+		CollectionID:   req.CollectionID,
+		Name:           req.Name,
 	}
 
 	taskID, err := queue.EnqueueCollectionImport(payload)
 	if err != nil {
+		errMsg := "failed to enqueue collection import"
+		if err := db.UpdateCollectionJobStatus(job.ID, db.JobStatusFailed, &errMsg); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", job.ID)
+		}
 		slog.Error("Failed to enqueue collection import", "error", err, "user_id", userID)
+		audit.Record(c, audit.Event{
+			Action:       audit.ActionCollectionImport,
+			TargetType:   audit.TargetCollection,
+			TargetID:     req.CollectionID,
+			CollectionID: req.CollectionID,
+			Outcome:      audit.OutcomeFailure,
+			Err:          err,
+		})
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
 	}
+	if err := db.SetCollectionJobTaskID(job.ID, taskID); err != nil {
+		slog.Warn("Failed to link job to task", "error", err, "job_id", job.ID, "task_id", taskID)
+	}
+	audit.Record(c, audit.Event{
+		Action:       audit.ActionCollectionImport,
+		TargetType:   audit.TargetCollection,
+		TargetID:     req.CollectionID,
+		CollectionID: req.CollectionID,
+		Details:      map[string]interface{}{"name": req.Name, "job_id": job.ID, "task_id": taskID},
+	})
 
 	slog.Info("Enqueued collection import",
 		"user_id", userID,
 		"collection_id", req.CollectionID,
 		"name", req.Name,
+		"job_id", job.ID,
 		"task_id", taskID)
 
 	return c.JSON(http.StatusAccepted, map[string]interface{}{
 		"message": "Collection import started",
+		"job_id":  job.ID,
 		"task_id": taskID,
 	})
 }
@@ -239,7 +316,8 @@ func GetCollectionChanges(c echo.Context) error {
 
 func GetJobStatus(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
 
 	
 	jobID := c.Param("id")
@@ -253,31 +331,63 @@ func GetJobStatus(c echo.Context) error {
 	}
 
 	job, err := db.GetCollectionJob(id)
+	if errors.Is(err, db.ErrCollectionJobNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
 	if err != nil {
 		slog.Error("Failed to get job status", "error", err, "user_id", userID, "job_id", id)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get job status"})
 	}
 
 	
-	if job.UserID != userID {
+	if !scope.Owns(job.UserID, job.OrganizationID) {
 		return c.JSON(http.StatusForbidden, map[string]string{"error": "Access denied"})
 	}
 
+	if job.JobType == db.JobTypeBulkImport {
+		progress, err := db.GetBulkImportProgress(job.ID)
+		if err != nil {
+			slog.Error("Failed to get bulk import progress", "error", err, "user_id", userID, "job_id", id)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get job status"})
+		}
+		children, err := db.GetChildCollectionJobs(job.ID)
+		if err != nil {
+			slog.Error("Failed to get child jobs", "error", err, "user_id", userID, "job_id", id)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get job status"})
+		}
+		return c.JSON(http.StatusOK, BulkImportJobResponse{CollectionJob: job, Progress: progress, Children: children})
+	}
+
 	return c.JSON(http.StatusOK, job)
 }
 
 func GetUserJobs(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
 
 	
-	jobs, err := db.GetUserCollectionJobs(userID)
+	jobs, err := db.GetScopedCollectionJobs(scope)
 	if err != nil {
 		slog.Error("Failed to get user jobs", "error", err, "user_id", userID)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get user jobs"})
 	}
 
-	return c.JSON(http.StatusOK, jobs)
+	scheduled, err := scheduledJobs(scope)
+	if err != nil {
+		slog.Error("Failed to get user schedules", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get user jobs"})
+	}
+
+	// Upcoming scheduled runs come first, followed by the jobs that already ran.
+	entries := make([]interface{}, 0, len(scheduled)+len(jobs))
+	for _, job := range scheduled {
+		entries = append(entries, job)
+	}
+	for _, job := range jobs {
+		entries = append(entries, job)
+	}
+	return c.JSON(http.StatusOK, entries)
 }
 
 func CompareSnapShots(c echo.Context) error {
@@ -320,10 +430,11 @@ func maskAPIKey(key string) string {
 
 func GetAPIKeys(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
 
 	
-	keys, err := db.GetAPIKeyInfo(userID)
+	keys, err := db.GetAPIKeyInfo(scope)
 	if err != nil {
 		slog.Error("Failed to get API keys", "error", err, "user_id", userID)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get API keys"})
@@ -333,7 +444,7 @@ func GetAPIKeys(c echo.Context) error {
 	var response []APIKeyResponse
 	for _, key := range keys {
 		
-		decryptedKey, err := config.DecryptAPIKey(key.EncryptedKey)
+		decryptedKey, err := encryption.DecryptString(key.EncryptedKey)
 		if err != nil {
 			slog.Error("Failed to decrypt API key", "error", err, "user_id", userID, "key_id", key.ID)
 			continue
@@ -356,7 +467,13 @@ func GetAPIKeys(c echo.Context) error {
 
 func DeleteAPIKey(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	if !canManageOrganization(c) {
+		audit.Record(c, audit.Event{Action: audit.ActionAPIKeyDelete, TargetType: audit.TargetAPIKey, TargetID: c.Param("id"), Outcome: audit.OutcomeDenied})
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can manage API keys"})
+	}
 
 	
 	keyID := c.Param("id")
@@ -369,19 +486,22 @@ func DeleteAPIKey(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid key ID"})
 	}
 
-	if err := db.DeleteAPIKey(id, userID); err != nil {
+	if err := db.DeleteAPIKey(id, scope); err != nil {
 		slog.Error("Failed to delete API key", "error", err, "user_id", userID, "key_id", id)
+		audit.Record(c, audit.Event{Action: audit.ActionAPIKeyDelete, TargetType: audit.TargetAPIKey, TargetID: keyID, Outcome: audit.OutcomeFailure, Err: err})
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete API key"})
 	}
+	audit.Record(c, audit.Event{Action: audit.ActionAPIKeyDelete, TargetType: audit.TargetAPIKey, TargetID: keyID})
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "API key deleted successfully"})
 }
 
 func GetUserCollections(c echo.Context) error {
 	
-	userID := c.Get("user_id").(int64)
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
 
-	collections, err := db.GetUserCollections(userID)
+	collections, err := db.GetScopedCollections(scope)
 	if err != nil {
 		slog.Error("Failed to get user collections", "error", err, "user_id", userID)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get collections"})
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+
+	"github.com/labstack/echo/v4"
+)
+
+func GetEndpointChanges(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("collectionId")
+
+	snapshotID, err := strconv.ParseInt(c.Param("snapshotId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+	}
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	eventTypes := c.QueryParams()["type"]
+
+	compareTo := c.QueryParam("compare_to")
+	if compareTo == "" {
+		changes, err := db.GetEndpointChanges(collectionID, snapshotID, eventTypes)
+		if err != nil {
+			slog.Error("Failed to get endpoint changes", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get endpoint changes"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"collection_id": collectionID,
+			"snapshot_id":   snapshotID,
+			"changes":       changes,
+			"total":         len(changes),
+		})
+	}
+
+	baseSnapshotID, err := strconv.ParseInt(compareTo, 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid compare_to snapshot ID"})
+	}
+
+	baseSnapshot, err := db.GetCollectionSnapshot(collectionID, baseSnapshotID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+	snapshot, err := db.GetCollectionSnapshot(collectionID, snapshotID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+
+	changes, err := postman.CompareEndpoints(baseSnapshot.Content, snapshot.Content)
+	if err != nil {
+		slog.Error("Failed to compare endpoints", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Snapshot content is not a valid Postman collection"})
+	}
+
+	if len(eventTypes) > 0 {
+		wanted := make(map[string]bool, len(eventTypes))
+		for _, t := range eventTypes {
+			wanted[t] = true
+		}
+		filtered := changes[:0]
+		for _, change := range changes {
+			if wanted[change.EventType] {
+				filtered = append(filtered, change)
+			}
+		}
+		changes = filtered
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"collection_id": collectionID,
+		"snapshot_id":   snapshotID,
+		"compared_to":   baseSnapshotID,
+		"changes":       changes,
+		"total":         len(changes),
+	})
+}
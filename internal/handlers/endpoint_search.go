@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SearchAllEndpoints searches the endpoints of every collection in the active scope by method,
+// path, header name and body field. Only the latest snapshot of each collection is searched
+// unless snapshots=all.
+func SearchAllEndpoints(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	filter := db.EndpointSearchFilter{
+		Scope:  scope,
+		Query:  c.QueryParam("q"),
+		Method: c.QueryParam("method"),
+		Path:   c.QueryParam("path"),
+		Header: c.QueryParam("header"),
+		Field:  c.QueryParam("field"),
+		Limit:  50,
+	}
+	if filter.Query == "" && filter.Method == "" && filter.Path == "" && filter.Header == "" && filter.Field == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "At least one of q, method, path, header or field is required"})
+	}
+
+	switch c.QueryParam("snapshots") {
+	case "", "latest":
+	case "all":
+		filter.AllSnapshots = true
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "snapshots must be latest or all"})
+	}
+
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		filter.Limit = l
+	}
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		filter.Offset = o
+	}
+
+	endpoints, total, err := db.SearchSnapshotEndpoints(filter)
+	if err != nil {
+		slog.Error("Failed to search endpoints", "error", err, "user_id", scope.UserID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search endpoints"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"endpoints": endpoints,
+		"total":     total,
+		"limit":     filter.Limit,
+		"offset":    filter.Offset,
+	})
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StoreEnvironmentRequest struct {
+	EnvironmentID string `json:"environment_id" validate:"required"`
+	Name          string `json:"name" validate:"required"`
+}
+
+// GetEnvironments lists the environments available through the stored Postman API key.
+func GetEnvironments(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	apiKey, err := db.GetPostmanAPIKey(scope)
+	if err != nil {
+		slog.Warn("No active API key found", "error", err, "user_id", userID)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No active API key found. Please store your Postman API key first."})
+	}
+
+	if err := db.UpdateLastUsedAPIKey(scope); err != nil {
+		slog.Error("Failed to update API key usage", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update API key usage"})
+	}
+
+	environments, err := postman.GetEnvironments(apiKey)
+	if err != nil {
+		slog.Error("Failed to fetch environments from Postman", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch environments from Postman"})
+	}
+
+	return c.JSON(http.StatusOK, environments)
+}
+
+// GetUserEnvironments lists the environments tracked in the active scope.
+func GetUserEnvironments(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	environments, err := db.GetEnvironments(scope)
+	if err != nil {
+		slog.Error("Failed to get environments", "error", err, "user_id", scope.UserID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get environments"})
+	}
+
+	return c.JSON(http.StatusOK, environments)
+}
+
+func SaveEnvironment(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	if _, err := db.GetPostmanAPIKey(scope); err != nil {
+		slog.Error("No API key found", "error", err, "user_id", userID)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No API key found. Please store your Postman API key first."})
+	}
+
+	var req StoreEnvironmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if req.EnvironmentID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Environment ID is required"})
+	}
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Environment name is required"})
+	}
+
+	ownedElsewhere, err := db.IsEnvironmentOwnedOutsideScope(req.EnvironmentID, scope)
+	if err != nil {
+		slog.Error("Failed to check environment owner", "error", err, "user_id", userID, "environment_id", req.EnvironmentID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start environment import"})
+	}
+	if ownedElsewhere {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "This environment is already tracked by another user or organization."})
+	}
+
+	taskID, err := queue.EnqueueEnvironmentImport(queue.EnvironmentImportPayload{
+		UserID:         userID,
+		OrganizationID: scope.OrganizationID,
+		EnvironmentID:  req.EnvironmentID,
+		Name:           req.Name,
+	})
+	if err != nil {
+		slog.Error("Failed to enqueue environment import", "error", err, "user_id", userID)
+		audit.Record(c, audit.Event{
+			Action:     audit.ActionEnvironmentImport,
+			TargetType: audit.TargetEnvironment,
+			TargetID:   req.EnvironmentID,
+			Outcome:    audit.OutcomeFailure,
+			Err:        err,
+		})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start environment import"})
+	}
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionEnvironmentImport,
+		TargetType: audit.TargetEnvironment,
+		TargetID:   req.EnvironmentID,
+		Details:    map[string]interface{}{"name": req.Name, "task_id": taskID},
+	})
+
+	slog.Info("Enqueued environment import", "user_id", userID, "environment_id", req.EnvironmentID, "task_id", taskID)
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "Environment import started",
+		"task_id": taskID,
+	})
+}
+
+func GetEnvironmentSnapshots(c echo.Context) error {
+	environmentID := c.Param("id")
+	page := getPage(c)
+	pageSize := getPageSize(c)
+
+	snapshots, total, err := db.GetEnvironmentSnapshots(environmentID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		slog.Error("Failed to get environment snapshots", "error", err, "environment_id", environmentID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch snapshots"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshots": snapshots,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func GetEnvironmentSnapshot(c echo.Context) error {
+	environmentID := c.Param("id")
+	snapshotID, err := strconv.ParseInt(c.Param("snapshotId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+	}
+
+	snapshot, err := db.GetEnvironmentSnapshot(environmentID, snapshotID)
+	if errors.Is(err, db.ErrEnvironmentSnapshotNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get environment snapshot", "error", err, "environment_id", environmentID, "snapshot_id", snapshotID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch snapshot"})
+	}
+
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// GetEnvironmentChanges lists variable changes, optionally only those of one snapshot.
+func GetEnvironmentChanges(c echo.Context) error {
+	environmentID := c.Param("id")
+
+	var snapshotID *int64
+	if v := c.QueryParam("snapshot"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+		}
+		snapshotID = &id
+	}
+
+	limit, offset := 50, 0
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	changes, total, err := db.GetEnvironmentChanges(environmentID, snapshotID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to get environment changes", "error", err, "environment_id", environmentID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch environment changes"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"changes": changes,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
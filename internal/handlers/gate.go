@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+
+	"github.com/labstack/echo/v4"
+)
+
+type GateRequest struct {
+	Collection json.RawMessage `json:"collection"`
+	Policy     json.RawMessage `json:"policy"`
+}
+
+func RunBreakingChangeGate(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("collectionId")
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	var req GateRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if len(req.Collection) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Candidate collection is required"})
+	}
+
+	candidate, err := postman.ParseCollectionJSON(req.Collection)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	policy, err := postman.ParseGatePolicy(req.Policy)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	masking, _, err := postman.LoadMaskingConfig(collectionID, scope)
+	if err != nil {
+		slog.Error("Failed to load masking policy", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to evaluate gate"})
+	}
+
+	result, err := postman.EvaluateGate(collectionID, candidate, policy, masking)
+	if errors.Is(err, postman.ErrNoBaselineSnapshot) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No stored snapshot to compare against. Import the collection first."})
+	}
+	if err != nil {
+		slog.Error("Failed to evaluate gate", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to evaluate gate"})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ImportAllRequest selects collections by name with patterns where * matches any text and ?
+// one character, such as "Payments*". Matching ignores case; with no include patterns every
+// collection is included.
+type ImportAllRequest struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// SkippedCollection is a matching collection left out of a bulk import. JobID is the import
+// already in flight for it, if that is why it was skipped.
+type SkippedCollection struct {
+	CollectionID string `json:"collection_id"`
+	Name         string `json:"name"`
+	Reason       string `json:"reason"`
+	JobID        *int64 `json:"job_id,omitempty"`
+}
+
+// ImportAllCollections imports every collection visible to the stored Postman API key under one
+// bulk import job, with a child import job per collection.
+func ImportAllCollections(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	var req ImportAllRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	include := namePatterns(req.Include)
+	exclude := namePatterns(req.Exclude)
+
+	apiKey, err := db.GetPostmanAPIKey(scope)
+	if err != nil {
+		slog.Warn("No active API key found", "error", err, "user_id", userID)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No active API key found. Please store your Postman API key first."})
+	}
+
+	if err := db.UpdateLastUsedAPIKey(scope); err != nil {
+		slog.Error("Failed to update API key usage", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update API key usage"})
+	}
+
+	collections, err := postman.GetCollections(apiKey)
+	if err != nil {
+		slog.Error("Failed to fetch collections from Postman", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch collections from Postman"})
+	}
+
+	var items []db.BulkImportItem
+	skipped := []SkippedCollection{}
+	for _, collection := range collections {
+		if !matchesNamePatterns(collection.Name, include, exclude) {
+			continue
+		}
+
+		ownedElsewhere, err := db.IsCollectionOwnedOutsideScope(collection.ID, scope)
+		if err != nil {
+			slog.Error("Failed to check collection owner", "error", err, "user_id", userID, "collection_id", collection.ID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+		}
+		if ownedElsewhere {
+			skipped = append(skipped, SkippedCollection{
+				CollectionID: collection.ID,
+				Name:         collection.Name,
+				Reason:       "tracked by another user or organization",
+			})
+			continue
+		}
+
+		items = append(items, db.BulkImportItem{CollectionID: collection.ID, Name: collection.Name})
+	}
+
+	if len(items) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"error":   "No collections to import match the include and exclude patterns",
+			"skipped": skipped,
+		})
+	}
+
+	parent, children, active, err := db.CreateBulkImportJob(scope, "Import all collections", items)
+	if err != nil {
+		slog.Error("Failed to create bulk import job", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+	for _, job := range active {
+		jobID := job.ID
+		skipped = append(skipped, SkippedCollection{
+			CollectionID: job.CollectionID,
+			Name:         job.Name,
+			Reason:       "import already in progress",
+			JobID:        &jobID,
+		})
+	}
+	if parent == nil {
+		slog.Info("Collection imports already in progress", "user_id", userID, "collection_count", len(active))
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Every matching collection is already being imported",
+			"skipped": skipped,
+		})
+	}
+
+	enqueueFailed := false
+	for _, child := range children {
+		taskID, err := queue.EnqueueCollectionImport(queue.CollectionImportPayload{
+			JobID:          child.ID,
+			ParentJobID:    parent.ID,
+			UserID:         userID,
+			OrganizationID: scope.OrganizationID,
+			CollectionID:   child.CollectionID,
+			Name:           child.Name,
+		})
+		if err != nil {
+			slog.Error("Failed to enqueue collection import", "error", err, "user_id", userID, "collection_id", child.CollectionID)
+			errMsg := "failed to enqueue collection import"
+			if err := db.FinishCollectionJobAttempt(child.ID, db.JobOutcome{Status: db.JobStatusFailed, Error: &errMsg}); err != nil {
+				slog.Warn("Failed to update job status", "error", err, "job_id", child.ID)
+			}
+			enqueueFailed = true
+			continue
+		}
+		if err := db.SetCollectionJobTaskID(child.ID, taskID); err != nil {
+			slog.Warn("Failed to link job to task", "error", err, "job_id", child.ID, "task_id", taskID)
+		}
+	}
+	if enqueueFailed {
+		if err := queue.EnqueueBulkImportCheck(parent.ID); err != nil {
+			slog.Warn("Failed to enqueue bulk import check", "error", err, "job_id", parent.ID)
+		}
+	}
+
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionCollectionImportAll,
+		TargetType: audit.TargetJob,
+		TargetID:   strconv.FormatInt(parent.ID, 10),
+		Details: map[string]interface{}{
+			"include":          req.Include,
+			"exclude":          req.Exclude,
+			"collection_count": len(children),
+			"skipped":          len(skipped),
+		},
+	})
+
+	slog.Info("Enqueued bulk collection import", "user_id", userID, "job_id", parent.ID, "collection_count", len(children))
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message":          "Bulk collection import started",
+		"job_id":           parent.ID,
+		"collection_count": len(children),
+		"skipped":          skipped,
+	})
+}
+
+func namePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		quoted := regexp.QuoteMeta(pattern)
+		quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+		quoted = strings.ReplaceAll(quoted, `\?`, ".")
+		compiled[i] = regexp.MustCompile("(?is)^" + quoted + "$")
+	}
+	return compiled
+}
+
+func matchesNamePatterns(name string, include, exclude []*regexp.Regexp) bool {
+	matchesAny := func(patterns []*regexp.Regexp) bool {
+		for _, pattern := range patterns {
+			if pattern.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(include) > 0 && !matchesAny(include) {
+		return false
+	}
+	return !matchesAny(exclude)
+}
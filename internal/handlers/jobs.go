@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/queue"
+
+	"github.com/labstack/echo/v4"
+)
+
+type DeadLetterRequest struct {
+	Queue   string   `json:"queue"`
+	TaskIDs []string `json:"task_ids"`
+	All     bool     `json:"all"`
+}
+
+type DeadLetterFailure struct {
+	TaskID string `json:"task_id"`
+	Error  string `json:"error"`
+}
+
+// getScopedJob loads the job in the :id parameter, writing the error response when it cannot be
+// used by the active scope.
+func getScopedJob(c echo.Context) (*db.CollectionJob, error) {
+	scope := auth.ScopeFromContext(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid job ID"})
+	}
+
+	job, err := db.GetCollectionJob(id)
+	if errors.Is(err, db.ErrCollectionJobNotFound) {
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "Job not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get job", "error", err, "user_id", scope.UserID, "job_id", id)
+		return nil, c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get job"})
+	}
+	if !scope.Owns(job.UserID, job.OrganizationID) {
+		return nil, c.JSON(http.StatusForbidden, map[string]string{"error": "Access denied"})
+	}
+	return job, nil
+}
+
+// CancelJob stops a job that has not finished. A waiting task is removed from the queue; a
+// running one is told to stop and stores nothing it had not stored yet.
+func CancelJob(c echo.Context) error {
+	job, err := getScopedJob(c)
+	if job == nil {
+		return err
+	}
+
+	canceled, err := db.CancelCollectionJob(job.ID)
+	if err != nil {
+		slog.Error("Failed to cancel job", "error", err, "job_id", job.ID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel job"})
+	}
+	if !canceled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Job has already finished"})
+	}
+
+	if err := cancelJobTasks(job); err != nil {
+		slog.Error("Failed to cancel task", "error", err, "job_id", job.ID)
+		audit.Record(c, audit.Event{
+			Action:       audit.ActionJobCancel,
+			TargetType:   audit.TargetJob,
+			TargetID:     strconv.FormatInt(job.ID, 10),
+			CollectionID: job.CollectionID,
+			Outcome:      audit.OutcomeFailure,
+			Err:          err,
+		})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel job"})
+	}
+	if job.ParentJobID != nil {
+		if err := queue.EnqueueBulkImportCheck(*job.ParentJobID); err != nil {
+			slog.Warn("Failed to enqueue bulk import check", "error", err, "job_id", *job.ParentJobID)
+		}
+	}
+	audit.Record(c, audit.Event{
+		Action:       audit.ActionJobCancel,
+		TargetType:   audit.TargetJob,
+		TargetID:     strconv.FormatInt(job.ID, 10),
+		CollectionID: job.CollectionID,
+		Details:      map[string]interface{}{"previous_status": job.Status},
+	})
+
+	slog.Info("Canceled job", "job_id", job.ID, "collection_id", job.CollectionID)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Job canceled",
+		"job_id":  job.ID,
+	})
+}
+
+// cancelJobTasks stops the task of a job, or for a bulk import the jobs and tasks of the
+// children that have not finished.
+func cancelJobTasks(job *db.CollectionJob) error {
+	if job.JobType != db.JobTypeBulkImport {
+		if job.TaskID == nil {
+			return nil
+		}
+		return queue.CancelTask(queue.JobQueue(job.JobType), *job.TaskID)
+	}
+
+	children, err := db.GetChildCollectionJobs(job.ID)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		canceled, err := db.CancelCollectionJob(child.ID)
+		if err != nil {
+			return err
+		}
+		if canceled && child.TaskID != nil {
+			if err := queue.CancelTask(queue.JobQueue(child.JobType), *child.TaskID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RetryJob runs a failed or canceled job again. Its archived task is requeued when there is one;
+// otherwise an import is enqueued afresh, which is not possible for uploads and restores.
+func RetryJob(c echo.Context) error {
+	job, err := getScopedJob(c)
+	if job == nil {
+		return err
+	}
+
+	if job.Status != db.JobStatusFailed && job.Status != db.JobStatusCanceled {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Only failed or canceled jobs can be retried"})
+	}
+
+	// Claiming the job first keeps concurrent retries from enqueueing it twice.
+	claimed, err := db.ResetCollectionJob(job.ID)
+	if err != nil {
+		slog.Error("Failed to reset job", "error", err, "job_id", job.ID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retry job"})
+	}
+	if !claimed {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Only failed or canceled jobs can be retried"})
+	}
+	reopenParentJob(job)
+
+	err = queue.ErrTaskNotArchived
+	var taskID string
+	if job.TaskID != nil {
+		taskID = *job.TaskID
+		err = queue.RequeueTask(queue.JobQueue(job.JobType), taskID)
+	}
+	if errors.Is(err, queue.ErrTaskNotArchived) {
+		if job.JobType != "import" {
+			releaseJobRetry(job)
+			return c.JSON(http.StatusConflict, map[string]string{"error": "The task of this job no longer exists. Start it again instead."})
+		}
+		var parentJobID int64
+		if job.ParentJobID != nil {
+			parentJobID = *job.ParentJobID
+		}
+		taskID, err = queue.EnqueueCollectionImport(queue.CollectionImportPayload{
+			JobID:          job.ID,
+			ParentJobID:    parentJobID,
+			UserID:         job.UserID,
+			OrganizationID: job.OrganizationID,
+			CollectionID:   job.CollectionID,
+			Name:           job.Name,
+		})
+		if err == nil {
+			if err := db.SetCollectionJobTaskID(job.ID, taskID); err != nil {
+				slog.Warn("Failed to record retry task", "error", err, "job_id", job.ID, "task_id", taskID)
+			}
+		}
+	}
+	if err != nil {
+		slog.Error("Failed to retry job", "error", err, "job_id", job.ID)
+		releaseJobRetry(job)
+		audit.Record(c, audit.Event{
+			Action:       audit.ActionJobRetry,
+			TargetType:   audit.TargetJob,
+			TargetID:     strconv.FormatInt(job.ID, 10),
+			CollectionID: job.CollectionID,
+			Outcome:      audit.OutcomeFailure,
+			Err:          err,
+		})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retry job"})
+	}
+
+	audit.Record(c, audit.Event{
+		Action:       audit.ActionJobRetry,
+		TargetType:   audit.TargetJob,
+		TargetID:     strconv.FormatInt(job.ID, 10),
+		CollectionID: job.CollectionID,
+		Details:      map[string]interface{}{"task_id": taskID, "previous_status": job.Status},
+	})
+
+	slog.Info("Retrying job", "job_id", job.ID, "task_id", taskID)
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "Job retry started",
+		"job_id":  job.ID,
+		"task_id": taskID,
+	})
+}
+
+// releaseJobRetry puts a claimed job back to the status it had when nothing was enqueued for it,
+// and has its bulk import, reopened for the retry, finished again.
+func releaseJobRetry(job *db.CollectionJob) {
+	if err := db.ReleaseCollectionJob(job); err != nil {
+		slog.Warn("Failed to restore job status", "error", err, "job_id", job.ID)
+	}
+	if job.ParentJobID != nil {
+		if err := queue.EnqueueBulkImportCheck(*job.ParentJobID); err != nil {
+			slog.Warn("Failed to enqueue bulk import check", "error", err, "job_id", *job.ParentJobID)
+		}
+	}
+}
+
+// reopenParentJob puts the bulk import of a retried child back to running, so that its summary
+// counts the child's new outcome.
+func reopenParentJob(job *db.CollectionJob) {
+	if job.ParentJobID == nil {
+		return
+	}
+	if err := db.ReopenBulkImportJob(*job.ParentJobID); err != nil {
+		slog.Warn("Failed to reopen bulk import job", "error", err, "job_id", *job.ParentJobID)
+	}
+}
+
+// GetDeadLetterTasks lists the archived tasks of a queue, collection imports by default.
+func GetDeadLetterTasks(c echo.Context) error {
+	queueName := c.QueryParam("queue")
+	if queueName == "" {
+		queueName = queue.QueueCollectionImport
+	}
+	if !queue.IsDeadLetterQueue(queueName) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown queue"})
+	}
+
+	page := getPage(c)
+	pageSize := getPageSize(c)
+
+	tasks, total, err := queue.ListDeadLetterTasks(queueName, page, pageSize)
+	if err != nil {
+		slog.Error("Failed to list dead-letter tasks", "error", err, "queue", queueName)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list dead-letter tasks"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"queue":     queueName,
+		"tasks":     tasks,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// RequeueDeadLetterTasks moves archived tasks back to pending and resets their jobs.
+func RequeueDeadLetterTasks(c echo.Context) error {
+	return applyDeadLetter(c, audit.ActionDeadLetterRequeue, func(queueName, taskID string) error {
+		if err := queue.RequeueTask(queueName, taskID); err != nil {
+			return err
+		}
+
+		job, err := db.GetCollectionJobByTaskID(taskID)
+		if errors.Is(err, db.ErrCollectionJobNotFound) {
+			return nil
+		}
+		if err == nil {
+			var reset bool
+			if reset, err = db.ResetCollectionJob(job.ID); err == nil && reset {
+				reopenParentJob(job)
+			}
+		}
+		if err != nil {
+			slog.Warn("Failed to reset job of requeued task", "error", err, "task_id", taskID)
+		}
+		return nil
+	})
+}
+
+// DeleteDeadLetterTasks deletes archived tasks. Their jobs keep their failed status.
+func DeleteDeadLetterTasks(c echo.Context) error {
+	return applyDeadLetter(c, audit.ActionDeadLetterDelete, queue.DeleteDeadLetterTask)
+}
+
+// applyDeadLetter runs apply on the archived tasks named in the request, or on every archived
+// task of the queue when all is set, and reports the tasks it failed on.
+func applyDeadLetter(c echo.Context, action string, apply func(queueName, taskID string) error) error {
+	var req DeadLetterRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if req.Queue == "" {
+		req.Queue = queue.QueueCollectionImport
+	}
+	if !queue.IsDeadLetterQueue(req.Queue) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown queue"})
+	}
+	if len(req.TaskIDs) == 0 && !req.All {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provide task_ids or set all"})
+	}
+
+	taskIDs := req.TaskIDs
+	if req.All {
+		var err error
+		if taskIDs, err = queue.DeadLetterTaskIDs(req.Queue); err != nil {
+			slog.Error("Failed to list dead-letter tasks", "error", err, "queue", req.Queue)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list dead-letter tasks"})
+		}
+	}
+
+	processed := []string{}
+	failed := []DeadLetterFailure{}
+	for _, taskID := range taskIDs {
+		if err := apply(req.Queue, taskID); err != nil {
+			failed = append(failed, DeadLetterFailure{TaskID: taskID, Error: err.Error()})
+			continue
+		}
+		processed = append(processed, taskID)
+	}
+
+	audit.Record(c, audit.Event{
+		Action:     action,
+		TargetType: audit.TargetTask,
+		Details: map[string]interface{}{
+			"queue":     req.Queue,
+			"all":       req.All,
+			"processed": processed,
+			"failed":    len(failed),
+		},
+	})
+
+	slog.Info("Processed dead-letter tasks", "action", action, "queue", req.Queue, "processed", len(processed), "failed", len(failed))
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"queue":     req.Queue,
+		"processed": processed,
+		"failed":    failed,
+	})
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+
+	"github.com/labstack/echo/v4"
+)
+
+type MaskingPolicyRequest struct {
+	Config json.RawMessage `json:"config"`
+}
+
+// normalize validates the submitted policy and returns it with every option spelled out, so the
+// stored policy reads the same as what the worker applies.
+func (r *MaskingPolicyRequest) normalize() (json.RawMessage, error) {
+	if len(r.Config) == 0 {
+		return nil, errors.New("config is required")
+	}
+	config, err := postman.ParseMaskingConfig(r.Config)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(config)
+}
+
+func GetCollectionMaskingPolicy(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("id")
+
+	policy, err := db.GetCollectionMaskingPolicy(collectionID)
+	if errors.Is(err, db.ErrMaskingPolicyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Masking policy not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get masking policy", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get masking policy"})
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+func SaveCollectionMaskingPolicy(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("id")
+
+	var req MaskingPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	config, err := req.normalize()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	policy, err := db.SaveCollectionMaskingPolicy(scope.UserID, collectionID, config)
+	if err != nil {
+		slog.Error("Failed to save masking policy", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicySave, collectionID, audit.OutcomeFailure, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save masking policy"})
+	}
+	audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicySave, collectionID, audit.OutcomeSuccess, nil))
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+func DeleteCollectionMaskingPolicy(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("id")
+
+	err := db.DeleteCollectionMaskingPolicy(collectionID)
+	if errors.Is(err, db.ErrMaskingPolicyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Masking policy not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to delete masking policy", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicyDelete, collectionID, audit.OutcomeFailure, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete masking policy"})
+	}
+	audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicyDelete, collectionID, audit.OutcomeSuccess, nil))
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Masking policy deleted"})
+}
+
+func GetDefaultMaskingPolicy(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	policy, err := db.GetDefaultMaskingPolicy(scope)
+	if errors.Is(err, db.ErrMaskingPolicyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Default masking policy not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get default masking policy", "error", err, "user_id", scope.UserID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get default masking policy"})
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+func SaveDefaultMaskingPolicy(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	if !canManageOrganization(c) {
+		audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicySave, "", audit.OutcomeDenied, nil))
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can change the default masking policy"})
+	}
+
+	var req MaskingPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	config, err := req.normalize()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	policy, err := db.SaveDefaultMaskingPolicy(scope, config)
+	if err != nil {
+		slog.Error("Failed to save default masking policy", "error", err, "user_id", scope.UserID)
+		audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicySave, "", audit.OutcomeFailure, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save default masking policy"})
+	}
+	audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicySave, "", audit.OutcomeSuccess, nil))
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+func DeleteDefaultMaskingPolicy(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	if !canManageOrganization(c) {
+		audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicyDelete, "", audit.OutcomeDenied, nil))
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can change the default masking policy"})
+	}
+
+	err := db.DeleteDefaultMaskingPolicy(scope)
+	if errors.Is(err, db.ErrMaskingPolicyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Default masking policy not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to delete default masking policy", "error", err, "user_id", scope.UserID)
+		audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicyDelete, "", audit.OutcomeFailure, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete default masking policy"})
+	}
+	audit.Record(c, maskingPolicyAuditEvent(audit.ActionMaskingPolicyDelete, "", audit.OutcomeSuccess, nil))
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Default masking policy deleted"})
+}
+
+// PreviewCollectionMasking masks the live collection from Postman and lists what would be
+// masked and why. A config in the request is previewed instead of the stored policy, so a
+// policy can be tried before it is saved.
+func PreviewCollectionMasking(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("id")
+
+	var req MaskingPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	var (
+		config *postman.MaskingConfig
+		source postman.MaskingPolicySource
+		err    error
+	)
+	if len(req.Config) > 0 {
+		source.Source = postman.MaskingSourceRequest
+		config, err = postman.ParseMaskingConfig(req.Config)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	} else {
+		config, source, err = postman.LoadMaskingConfig(collectionID, scope)
+		if err != nil {
+			slog.Error("Failed to load masking policy", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to preview masking"})
+		}
+	}
+
+	apiKey, err := db.GetPostmanAPIKey(scope)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No API key found. Please store your Postman API key first."})
+	}
+
+	collection, err := postman.GetCollection(apiKey, collectionID)
+	if err != nil {
+		slog.Error("Failed to fetch collection from Postman", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to fetch collection from Postman"})
+	}
+
+	preview, err := postman.PreviewMasking(collection, config, source)
+	if err != nil {
+		slog.Error("Failed to preview masking", "error", err, "user_id", scope.UserID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to preview masking"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"collection_id": collectionID,
+		"preview":       preview,
+	})
+}
+
+func maskingPolicyAuditEvent(action, collectionID, outcome string, err error) audit.Event {
+	targetID := "default"
+	if collectionID != "" {
+		targetID = collectionID
+	}
+	return audit.Event{
+		Action:       action,
+		TargetType:   audit.TargetMaskingPolicy,
+		TargetID:     targetID,
+		CollectionID: collectionID,
+		Outcome:      outcome,
+		Err:          err,
+	}
+}
+
+func GetSnapshotMaskingReport(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	snapshotID, err := strconv.ParseInt(c.Param("snapshotId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+	}
+
+	report, err := db.GetSnapshotMaskingReport(snapshotID)
+	if errors.Is(err, db.ErrMaskingReportNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No masking report was recorded for this snapshot"})
+	}
+	if err != nil {
+		slog.Error("Failed to get masking report", "error", err, "user_id", scope.UserID, "snapshot_id", snapshotID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get masking report"})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+const maxUnmaskPaths = 100
+
+type UnmaskRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// UnmaskSnapshotValues returns the originals of specific masked values of a snapshot stored with
+// reversible masking. Only owners may unmask, and every attempt is audited with the paths
+// requested but never the values.
+func UnmaskSnapshotValues(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("id")
+
+	snapshotID, err := strconv.ParseInt(c.Param("snapshotId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+	}
+
+	var req UnmaskRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if len(req.Paths) == 0 || len(req.Paths) > maxUnmaskPaths {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Provide between 1 and 100 paths to unmask"})
+	}
+
+	event := audit.Event{
+		Action:       audit.ActionSnapshotUnmask,
+		TargetType:   audit.TargetSnapshot,
+		TargetID:     strconv.FormatInt(snapshotID, 10),
+		CollectionID: collectionID,
+		Details:      map[string]interface{}{"paths": req.Paths},
+	}
+
+	if !canManageOrganization(c) {
+		event.Outcome = audit.OutcomeDenied
+		audit.Record(c, event)
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can unmask values"})
+	}
+
+	sealedKey, err := db.GetSnapshotMaskingKey(snapshotID)
+	if errors.Is(err, db.ErrMaskingKeyNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot was stored without reversible masking"})
+	}
+	if err != nil {
+		slog.Error("Failed to get masking key", "error", err, "user_id", scope.UserID, "snapshot_id", snapshotID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to unmask values"})
+	}
+
+	values, err := db.GetSnapshotMaskedValues(snapshotID, req.Paths)
+	if err != nil {
+		return unmaskFailed(c, event, err)
+	}
+	originals, err := postman.UnmaskValues(sealedKey, values)
+	if err != nil {
+		return unmaskFailed(c, event, err)
+	}
+
+	missing := make([]string, 0)
+	for _, path := range req.Paths {
+		if _, ok := originals[path]; !ok {
+			missing = append(missing, path)
+		}
+	}
+	event.Details["missing"] = missing
+	audit.Record(c, event)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshot_id": snapshotID,
+		"values":      originals,
+		"missing":     missing,
+	})
+}
+
+func unmaskFailed(c echo.Context, event audit.Event, err error) error {
+	slog.Error("Failed to unmask values", "error", err, "user_id", c.Get("user_id"), "snapshot_id", event.TargetID)
+	event.Outcome = audit.OutcomeFailure
+	event.Err = err
+	audit.Record(c, event)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to unmask values"})
+}
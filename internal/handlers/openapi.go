@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+
+	"github.com/labstack/echo/v4"
+)
+
+func GetSnapshotOpenAPI(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	collectionID := c.Param("id")
+
+	snapshotID, err := strconv.ParseInt(c.Param("snapshotId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+	}
+
+	format := strings.ToLower(c.QueryParam("format"))
+	if format == "" || format == "yml" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "json" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "format must be yaml or json"})
+	}
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	snapshot, err := db.GetCollectionSnapshot(collectionID, snapshotID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+
+	collection, err := postman.ParseCollectionJSON(snapshot.Content)
+	if err != nil {
+		slog.Error("Failed to parse snapshot content", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Snapshot content is not a valid Postman collection"})
+	}
+
+	doc := postman.ConvertToOpenAPI(collection, fmt.Sprintf("snapshot-%d", snapshotID))
+	if len(doc.Warnings) > 0 {
+		slog.Warn("OpenAPI conversion did not map every request", "collection_id", collectionID, "snapshot_id", snapshotID, "warnings", doc.Warnings)
+	}
+
+	if format == "json" {
+		data, err := doc.JSON()
+		if err != nil {
+			slog.Error("Failed to encode OpenAPI document", "error", err, "snapshot_id", snapshotID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate OpenAPI document"})
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, data)
+	}
+
+	data, err := doc.YAML()
+	if err != nil {
+		slog.Error("Failed to encode OpenAPI document", "error", err, "snapshot_id", snapshotID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate OpenAPI document"})
+	}
+	return c.Blob(http.StatusOK, "application/yaml", data)
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+
+	"github.com/labstack/echo/v4"
+)
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type TransferCollectionRequest struct {
+	OrganizationID *int64 `json:"organization_id"`
+}
+
+// canManageOrganization reports whether the caller may change organization-wide settings such as
+// API keys. Personal requests are always allowed.
+func canManageOrganization(c echo.Context) bool {
+	role, ok := c.Get("organization_role").(string)
+	return !ok || role == db.RoleOwner
+}
+
+func parseOrganizationID(c echo.Context) (int64, error) {
+	return strconv.ParseInt(c.Param("orgId"), 10, 64)
+}
+
+func CreateOrganization(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	var req OrganizationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Organization name is required"})
+	}
+
+	org, err := db.CreateOrganization(name, userID)
+	if err != nil {
+		slog.Error("Failed to create organization", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create organization"})
+	}
+
+	slog.Info("Created organization", "user_id", userID, "organization_id", org.ID)
+	return c.JSON(http.StatusCreated, org)
+}
+
+func GetOrganizations(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	organizations, err := db.GetUserOrganizations(userID)
+	if err != nil {
+		slog.Error("Failed to get organizations", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get organizations"})
+	}
+
+	return c.JSON(http.StatusOK, organizations)
+}
+
+func GetOrganizationMembers(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	orgID, err := parseOrganizationID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid organization ID"})
+	}
+
+	if _, err := db.GetOrganizationMember(orgID, userID); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+
+	members, err := db.GetOrganizationMembers(orgID)
+	if err != nil {
+		slog.Error("Failed to get organization members", "error", err, "organization_id", orgID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get organization members"})
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+func AddOrganizationMember(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	orgID, err := parseOrganizationID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid organization ID"})
+	}
+
+	caller, err := db.GetOrganizationMember(orgID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	if caller.Role != db.RoleOwner {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can invite members"})
+	}
+
+	var req OrganizationMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if err := auth.ValidateEmail(email); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Role == "" {
+		req.Role = db.RoleViewer
+	}
+	if !db.IsValidRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be owner, editor or viewer"})
+	}
+
+	member, err := db.AddOrganizationMember(orgID, email, req.Role)
+	if errors.Is(err, db.ErrUserNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No user is registered with this email"})
+	}
+	if errors.Is(err, db.ErrMemberExists) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "User is already a member of this organization"})
+	}
+	if err != nil {
+		slog.Error("Failed to add organization member", "error", err, "user_id", userID, "organization_id", orgID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add organization member"})
+	}
+
+	slog.Info("Added organization member", "user_id", userID, "organization_id", orgID, "member_id", member.UserID, "role", member.Role)
+	return c.JSON(http.StatusCreated, member)
+}
+
+func UpdateOrganizationMember(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	orgID, err := parseOrganizationID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid organization ID"})
+	}
+	memberID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	caller, err := db.GetOrganizationMember(orgID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	if caller.Role != db.RoleOwner {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can change roles"})
+	}
+
+	var req OrganizationMemberRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if !db.IsValidRole(req.Role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be owner, editor or viewer"})
+	}
+
+	member, err := db.UpdateOrganizationMemberRole(orgID, memberID, req.Role)
+	if errors.Is(err, db.ErrMemberNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization member not found"})
+	}
+	if errors.Is(err, db.ErrLastOwner) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The organization must keep at least one owner"})
+	}
+	if err != nil {
+		slog.Error("Failed to update organization member", "error", err, "user_id", userID, "organization_id", orgID, "member_id", memberID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update organization member"})
+	}
+
+	slog.Info("Updated organization member role", "user_id", userID, "organization_id", orgID, "member_id", memberID, "role", member.Role)
+	return c.JSON(http.StatusOK, member)
+}
+
+func RemoveOrganizationMember(c echo.Context) error {
+	userID := c.Get("user_id").(int64)
+
+	orgID, err := parseOrganizationID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid organization ID"})
+	}
+	memberID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	caller, err := db.GetOrganizationMember(orgID, userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+	if caller.Role != db.RoleOwner && memberID != userID {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can remove other members"})
+	}
+
+	err = db.RemoveOrganizationMember(orgID, memberID)
+	if errors.Is(err, db.ErrMemberNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization member not found"})
+	}
+	if errors.Is(err, db.ErrLastOwner) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "The organization must keep at least one owner"})
+	}
+	if err != nil {
+		slog.Error("Failed to remove organization member", "error", err, "user_id", userID, "organization_id", orgID, "member_id", memberID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove organization member"})
+	}
+
+	slog.Info("Removed organization member", "user_id", userID, "organization_id", orgID, "member_id", memberID)
+	return c.JSON(http.StatusOK, map[string]string{"message": "Organization member removed successfully"})
+}
+
+// TransferCollection moves a collection from the active scope into an organization, or back to
+// the caller's personal collections when organization_id is null.
+func TransferCollection(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+	collectionID := c.Param("id")
+
+	if !canManageOrganization(c) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "Only organization owners can transfer collections"})
+	}
+
+	var req TransferCollectionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if req.OrganizationID != nil {
+		member, err := db.GetOrganizationMember(*req.OrganizationID, userID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+		}
+		if member.Role == db.RoleViewer {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "Viewers cannot transfer collections into an organization"})
+		}
+	}
+
+	collection, err := db.TransferCollection(collectionID, scope, userID, req.OrganizationID)
+	if errors.Is(err, db.ErrCollectionNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to transfer collection", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to transfer collection"})
+	}
+
+	slog.Info("Transferred collection", "user_id", userID, "collection_id", collectionID)
+	return c.JSON(http.StatusOK, collection)
+}
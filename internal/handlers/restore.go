@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RestoreSnapshotRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+func RestoreSnapshot(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+	collectionID := c.Param("id")
+
+	snapshotID, err := strconv.ParseInt(c.Param("snapshotId"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshot ID"})
+	}
+
+	var req RestoreSnapshotRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+	if dryRun, err := strconv.ParseBool(c.QueryParam("dry_run")); err == nil {
+		req.DryRun = dryRun
+	}
+
+	collection, err := db.GetScopedCollection(collectionID, scope)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	snapshot, err := db.GetCollectionSnapshot(collectionID, snapshotID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+
+	if _, err := db.GetPostmanAPIKey(scope); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "No API key found. Please store your Postman API key first."})
+	}
+
+	if !req.DryRun {
+		maskedPaths, err := postman.MaskedSnapshotPaths(snapshot.ID)
+		if errors.Is(err, postman.ErrMaskingRecordMissing) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "Snapshot was stored without a masking report, so it cannot be pushed to Postman safely. Run a dry run to review it."})
+		}
+		if err != nil {
+			slog.Error("Failed to get masked paths", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start restore"})
+		}
+		_, unrestorable, err := postman.MaskedSnapshotOriginals(snapshot.ID, maskedPaths)
+		if err != nil {
+			slog.Error("Failed to get masked originals", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start restore"})
+		}
+		if len(unrestorable) > 0 {
+			return c.JSON(http.StatusConflict, map[string]interface{}{
+				"error":              "Snapshot contains masked values without stored originals and cannot be pushed to Postman. Run a dry run to review them.",
+				"unrestorable_paths": unrestorable,
+			})
+		}
+	}
+
+	job, err := db.CreateCollectionJobWithType(scope, collectionID, collection.Name, "restore")
+	if err != nil {
+		slog.Error("Failed to create restore job", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start restore"})
+	}
+
+	taskID, err := queue.EnqueueCollectionRestore(queue.CollectionRestorePayload{
+		JobID:          job.ID,
+		UserID:         userID,
+		OrganizationID: scope.OrganizationID,
+		CollectionID:   collectionID,
+		Name:           collection.Name,
+		SnapshotID:     snapshotID,
+		DryRun:         req.DryRun,
+	})
+	if err != nil {
+		errMsg := "failed to enqueue restore"
+		if err := db.UpdateCollectionJobStatus(job.ID, "failed", &errMsg); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", job.ID)
+		}
+		slog.Error("Failed to enqueue collection restore", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start restore"})
+	}
+	if err := db.SetCollectionJobTaskID(job.ID, taskID); err != nil {
+		slog.Warn("Failed to link job to task", "error", err, "job_id", job.ID, "task_id", taskID)
+	}
+
+	slog.Info("Enqueued collection restore",
+		"user_id", userID,
+		"collection_id", collectionID,
+		"snapshot_id", snapshotID,
+		"dry_run", req.DryRun,
+		"job_id", job.ID,
+		"task_id", taskID)
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message": "Collection restore started",
+		"job_id":  job.ID,
+		"task_id": taskID,
+		"dry_run": req.DryRun,
+	})
+}
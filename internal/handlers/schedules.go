@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/queue"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JobTypeScheduledImport marks the upcoming run of a collection schedule in a job list.
+const JobTypeScheduledImport = "scheduled_import"
+
+// ScheduledJob is the next run of a collection schedule, listed among the scope's jobs.
+type ScheduledJob struct {
+	JobType      string     `json:"job_type"`
+	Status       string     `json:"status"`
+	CollectionID string     `json:"collection_id"`
+	Name         string     `json:"name"`
+	ScheduleID   int64      `json:"schedule_id"`
+	Schedule     string     `json:"schedule"`
+	LastRunAt    *time.Time `json:"last_run_at"`
+	NextRunAt    *time.Time `json:"next_run_at"`
+}
+
+// scheduledJobs lists the next run of every schedule in the scope. Disabled schedules are listed
+// without a next run.
+func scheduledJobs(scope db.Scope) ([]ScheduledJob, error) {
+	schedules, err := db.GetScopedCollectionSchedules(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	jobs := make([]ScheduledJob, 0, len(schedules))
+	for _, schedule := range schedules {
+		job := ScheduledJob{
+			JobType:      JobTypeScheduledImport,
+			Status:       "scheduled",
+			CollectionID: schedule.CollectionID,
+			Name:         schedule.CollectionName,
+			ScheduleID:   schedule.ID,
+			Schedule:     schedule.Cronspec(),
+			LastRunAt:    schedule.LastRunAt,
+		}
+		if !schedule.Enabled {
+			job.Status = "disabled"
+		} else if next, err := queue.NextScheduledRun(job.Schedule, schedule.LastRunAt, now); err == nil {
+			job.NextRunAt = &next
+		} else {
+			slog.Warn("Failed to compute next scheduled run", "error", err, "schedule_id", schedule.ID)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+type CollectionScheduleRequest struct {
+	CronExpression  string `json:"cron_expression"`
+	IntervalMinutes int    `json:"interval_minutes"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+func (r *CollectionScheduleRequest) validate() (*string, *int, error) {
+	cronExpression := strings.TrimSpace(r.CronExpression)
+
+	if cronExpression == "" && r.IntervalMinutes == 0 {
+		return nil, nil, errors.New("Either cron_expression or interval_minutes is required")
+	}
+	if cronExpression != "" && r.IntervalMinutes != 0 {
+		return nil, nil, errors.New("Provide either cron_expression or interval_minutes, not both")
+	}
+
+	if cronExpression != "" {
+		if err := queue.ValidateCronExpression(cronExpression); err != nil {
+			return nil, nil, err
+		}
+		return &cronExpression, nil, nil
+	}
+
+	if r.IntervalMinutes < queue.MinScheduleIntervalMinutes {
+		return nil, nil, errors.New("interval_minutes must be at least 5")
+	}
+	interval := r.IntervalMinutes
+	return nil, &interval, nil
+}
+
+func (r *CollectionScheduleRequest) enabled() bool {
+	if r.Enabled == nil {
+		return true
+	}
+	return *r.Enabled
+}
+
+func CreateCollectionSchedule(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+	collectionID := c.Param("id")
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found. Import it before scheduling."})
+	}
+
+	var req CollectionScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	cronExpression, intervalMinutes, err := req.validate()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if _, err := db.GetCollectionSchedule(collectionID); err == nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "Collection already has a schedule"})
+	}
+
+	schedule, err := db.CreateCollectionSchedule(userID, collectionID, cronExpression, intervalMinutes, req.enabled())
+	if err != nil {
+		slog.Error("Failed to create collection schedule", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create collection schedule"})
+	}
+
+	slog.Info("Created collection schedule", "user_id", userID, "collection_id", collectionID, "cronspec", schedule.Cronspec())
+	return c.JSON(http.StatusCreated, schedule)
+}
+
+// GetCollectionSchedules lists the schedules of every collection in the active scope.
+func GetCollectionSchedules(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+
+	schedules, err := db.GetScopedCollectionSchedules(scope)
+	if err != nil {
+		slog.Error("Failed to get collection schedules", "error", err, "user_id", scope.UserID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get collection schedules"})
+	}
+
+	return c.JSON(http.StatusOK, schedules)
+}
+
+func GetCollectionSchedule(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+	collectionID := c.Param("id")
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	schedule, err := db.GetCollectionSchedule(collectionID)
+	if errors.Is(err, db.ErrScheduleNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection schedule not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get collection schedule", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get collection schedule"})
+	}
+
+	return c.JSON(http.StatusOK, schedule)
+}
+
+func UpdateCollectionSchedule(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+	collectionID := c.Param("id")
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	var req CollectionScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	cronExpression, intervalMinutes, err := req.validate()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	schedule, err := db.UpdateCollectionSchedule(collectionID, cronExpression, intervalMinutes, req.enabled())
+	if errors.Is(err, db.ErrScheduleNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection schedule not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to update collection schedule", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update collection schedule"})
+	}
+
+	return c.JSON(http.StatusOK, schedule)
+}
+
+func DeleteCollectionSchedule(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+	collectionID := c.Param("id")
+
+	if _, err := db.GetScopedCollection(collectionID, scope); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection not found"})
+	}
+
+	err := db.DeleteCollectionSchedule(collectionID)
+	if errors.Is(err, db.ErrScheduleNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Collection schedule not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to delete collection schedule", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete collection schedule"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Collection schedule deleted successfully"})
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
 	"integratorV2/internal/db"
 	"log/slog"
 	"net/http"
@@ -26,8 +29,9 @@ func GetSnapshotID(c echo.Context) error {
 
 func GetSnapshotDetail(c echo.Context) error {
 	snapshotID := c.Param("snapshotId")
+	collectionID := c.Param("id")
 
-	snapshotDetails, err := db.GetSnapshotDetail(snapshotID)
+	snapshotDetails, err := db.GetSnapshotDetail(collectionID, snapshotID)
 
 	if err != nil {
 		return c.JSON(http.StatusNotFound,
@@ -83,14 +87,30 @@ func GetSnapshotItemTree(c echo.Context) error {
 }
 
 func DeleteSnapshot(c echo.Context) error {
-	snapshotID := c.Param("id")
+	snapshotID := c.Param("snapshotId")
 	id, err := strconv.ParseInt(snapshotID, 10, 64)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshotID"})
 	}
 
-	if err := db.DeleteSnapshot(id); err != nil {
+	summary, err := db.GetSnapshotSummary(id)
+	if errors.Is(err, db.ErrSnapshotNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get snapshot", "error", err, "snapshot_id", id)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete snapshot"})
+	}
+	event := snapshotAuditEvent(audit.ActionSnapshotDelete, summary)
+
+	if err := db.DeleteSnapshot(id, auth.ScopeFromContext(c)); err != nil {
+		if errors.Is(err, db.ErrSnapshotNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+		}
 		slog.Error("Failed to delete snapshot", "error", err)
+		event.Outcome = audit.OutcomeFailure
+		event.Err = err
+		audit.Record(c, event)
 		
 		
 		if pqErr, ok := err.(*pq.Error); ok {
@@ -115,18 +135,50 @@ func DeleteSnapshot(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete snapshot"})
 	}
 
+	audit.Record(c, event)
 	return c.JSON(http.StatusOK, map[string]string{"message": "snapshot deleted successfully"})
 }
 
 func DeleteSnapshotChanges(c echo.Context) error {
-		snapshotID := c.Param("id")
+		snapshotID := c.Param("snapshotId")
 	id, err := strconv.ParseInt(snapshotID, 10, 64)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid snapshotID"})
 	}
 
-	if err := db.DeleteSnapshotChanges(id); err != nil {
+	summary, err := db.GetSnapshotSummary(id)
+	if errors.Is(err, db.ErrSnapshotNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to get snapshot", "error", err, "snapshot_id", id)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete snapshot"})
+	}
+	event := snapshotAuditEvent(audit.ActionSnapshotChangesDelete, summary)
+
+	if err := db.DeleteSnapshotChanges(id, auth.ScopeFromContext(c)); err != nil {
+		if errors.Is(err, db.ErrSnapshotNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Snapshot not found"})
+		}
 		slog.Error("Failed to delete snapshot", "error", err)
+		event.Outcome = audit.OutcomeFailure
+		event.Err = err
+		audit.Record(c, event)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete snapshot"})
 	}
+	audit.Record(c, event)
 return c.JSON(http.StatusOK, map[string]string{"message": "snapshot deleted successfully"})
 }
+
+func snapshotAuditEvent(action string, snapshot *db.SnapshotSummary) audit.Event {
+	return audit.Event{
+		Action:       action,
+		TargetType:   audit.TargetSnapshot,
+		TargetID:     strconv.FormatInt(snapshot.ID, 10),
+		CollectionID: snapshot.CollectionID,
+		Details: map[string]interface{}{
+			"collection_name": snapshot.CollectionName,
+			"snapshot_time":   snapshot.SnapshotTime,
+		},
+	}
+}
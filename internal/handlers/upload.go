@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"integratorV2/internal/audit"
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const maxCollectionUploadSize = 10 << 20
+
+func UploadCollection(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A collection export file is required in the 'file' field"})
+	}
+
+	if fileHeader.Size > maxCollectionUploadSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Collection file exceeds the 10MB limit"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		slog.Error("Failed to open uploaded collection", "error", err, "user_id", userID)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read uploaded file"})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxCollectionUploadSize+1))
+	if err != nil {
+		slog.Error("Failed to read uploaded collection", "error", err, "user_id", userID)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read uploaded file"})
+	}
+	if len(data) > maxCollectionUploadSize {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": "Collection file exceeds the 10MB limit"})
+	}
+
+	collection, err := postman.ParseCollectionExport(data)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	collectionID := strings.TrimSpace(c.FormValue("collection_id"))
+	if collectionID == "" {
+		collectionID = collection.Info.PostmanID
+	}
+	if collectionID == "" {
+		collectionID = uuid.New().String()
+	}
+
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" {
+		name = collection.Info.Name
+	}
+
+	ownedByOther, err := db.IsCollectionOwnedOutsideScope(collectionID, scope)
+	if err != nil {
+		slog.Error("Failed to check collection owner", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+	if ownedByOther {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A collection with this ID already exists. Provide a different collection_id."})
+	}
+
+	job, err := db.CreateCollectionJobWithType(scope, collectionID, name, "upload")
+	if err != nil {
+		slog.Error("Failed to create collection job", "error", err, "user_id", userID, "collection_id", collectionID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+
+	sealedContent, err := encryption.EncryptString(string(data))
+	if err == nil {
+		err = db.SaveCollectionUpload(job.ID, sealedContent)
+	}
+	if err != nil {
+		errMsg := "failed to store collection upload"
+		if err := db.UpdateCollectionJobStatus(job.ID, "failed", &errMsg); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", job.ID)
+		}
+		slog.Error("Failed to store collection upload", "error", err, "user_id", userID, "job_id", job.ID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+
+	taskID, err := queue.EnqueueCollectionUpload(queue.CollectionUploadPayload{
+		CollectionImportPayload: queue.CollectionImportPayload{
+			JobID:          job.ID,
+			UserID:         userID,
+			OrganizationID: scope.OrganizationID,
+			CollectionID:   collectionID,
+			Name:           name,
+		},
+	})
+	if err != nil {
+		if err := db.DeleteCollectionUpload(job.ID); err != nil {
+			slog.Warn("Failed to delete collection upload", "error", err, "job_id", job.ID)
+		}
+		errMsg := "failed to enqueue collection upload"
+		if err := db.UpdateCollectionJobStatus(job.ID, "failed", &errMsg); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", job.ID)
+		}
+		slog.Error("Failed to enqueue collection upload", "error", err, "user_id", userID)
+		audit.Record(c, audit.Event{
+			Action:       audit.ActionCollectionUpload,
+			TargetType:   audit.TargetCollection,
+			TargetID:     collectionID,
+			CollectionID: collectionID,
+			Outcome:      audit.OutcomeFailure,
+			Err:          err,
+		})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start collection import"})
+	}
+	if err := db.SetCollectionJobTaskID(job.ID, taskID); err != nil {
+		slog.Warn("Failed to link job to task", "error", err, "job_id", job.ID, "task_id", taskID)
+	}
+	audit.Record(c, audit.Event{
+		Action:       audit.ActionCollectionUpload,
+		TargetType:   audit.TargetCollection,
+		TargetID:     collectionID,
+		CollectionID: collectionID,
+		Details:      map[string]interface{}{"name": name, "job_id": job.ID, "file_name": fileHeader.Filename},
+	})
+
+	slog.Info("Enqueued collection upload",
+		"user_id", userID,
+		"collection_id", collectionID,
+		"name", name,
+		"job_id", job.ID,
+		"task_id", taskID)
+
+	return c.JSON(http.StatusAccepted, map[string]interface{}{
+		"message":       "Collection import started",
+		"job_id":        job.ID,
+		"task_id":       taskID,
+		"collection_id": collectionID,
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+	"integratorV2/internal/webhook"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type WebhookCreatedResponse struct {
+	*db.Webhook
+	Secret string `json:"secret"`
+}
+
+func (r *WebhookRequest) validate() error {
+	parsed, err := url.Parse(strings.TrimSpace(r.URL))
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	// Deliveries check every address they connect to; this only turns away obvious cases early.
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); strings.EqualFold(host, "localhost") || (err == nil && webhook.IsBlockedAddress(addr)) {
+		return errors.New("url must not point to a loopback, private or link-local address")
+	}
+
+	if len(r.Events) == 0 {
+		return fmt.Errorf("events is required, supported events: %s", strings.Join(webhook.Events, ", "))
+	}
+	for _, event := range r.Events {
+		if !webhook.IsValidEvent(event) {
+			return fmt.Errorf("unsupported event %q, supported events: %s", event, strings.Join(webhook.Events, ", "))
+		}
+	}
+	return nil
+}
+
+func CreateWebhook(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	var req WebhookRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	if err := req.validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		slog.Error("Failed to generate webhook secret", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create webhook"})
+	}
+
+	hook, err := db.CreateWebhook(scope, strings.TrimSpace(req.URL), secret, req.Events)
+	if err != nil {
+		slog.Error("Failed to create webhook", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create webhook"})
+	}
+
+	slog.Info("Created webhook", "user_id", userID, "webhook_id", hook.ID, "events", req.Events)
+	return c.JSON(http.StatusCreated, WebhookCreatedResponse{Webhook: hook, Secret: secret})
+}
+
+func GetWebhooks(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	webhooks, err := db.GetScopedWebhooks(scope)
+	if err != nil {
+		slog.Error("Failed to get webhooks", "error", err, "user_id", userID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get webhooks"})
+	}
+
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+func DeleteWebhook(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid webhook ID"})
+	}
+
+	err = db.DeleteWebhook(webhookID, scope)
+	if errors.Is(err, db.ErrWebhookNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Webhook not found"})
+	}
+	if err != nil {
+		slog.Error("Failed to delete webhook", "error", err, "user_id", userID, "webhook_id", webhookID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete webhook"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+}
+
+func GetWebhookDeliveries(c echo.Context) error {
+	scope := auth.ScopeFromContext(c)
+	userID := scope.UserID
+
+	webhookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid webhook ID"})
+	}
+
+	if _, err := db.GetScopedWebhook(webhookID, scope); err != nil {
+		if errors.Is(err, db.ErrWebhookNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Webhook not found"})
+		}
+		slog.Error("Failed to get webhook", "error", err, "user_id", userID, "webhook_id", webhookID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get webhook deliveries"})
+	}
+
+	limit := 50
+	offset := 0
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	deliveries, total, err := db.GetWebhookDeliveries(webhookID, limit, offset)
+	if err != nil {
+		slog.Error("Failed to get webhook deliveries", "error", err, "webhook_id", webhookID)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get webhook deliveries"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
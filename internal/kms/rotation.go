@@ -4,12 +4,15 @@ import (
 	"errors"
 	"log/slog"
 	"os"
+	"time"
 
 	"integratorV2/internal/db"
 	"integratorV2/internal/queue"
 )
 
 
+// InitRotation records AWS_KMS_KEY_ID as the first key version on a fresh database and schedules
+// the next rotation of the active key. A rotation that was interrupted is resumed.
 func InitRotation() error {
 	
 	keyID := os.Getenv("AWS_KMS_KEY_ID")
@@ -19,36 +22,31 @@ func InitRotation() error {
 	}
 
 	
-	var exists bool
-	err := db.DB.QueryRow(`
-		SELECT EXISTS (
-			SELECT 1 FROM kms_key_rotation WHERE key_id = $1
-		)
-	`, keyID).Scan(&exists)
+	active, err := db.EnsureKMSKey(keyID, time.Now().Add(queue.KMSRotationInterval))
 	if err != nil {
-		slog.Error("failed to check rotation record", "error", err)
-		return errors.New("failed to check rotation record")
+		slog.Error("failed to load active KMS key", "error", err)
+		return errors.New("failed to load active KMS key")
+	}
+	if active.KeyID != keyID {
+		slog.Info("Using the KMS key recorded by the last rotation instead of AWS_KMS_KEY_ID",
+			"active_key_id", active.KeyID, "version", active.Version)
 	}
 
-	if !exists {
-		
-		_, err = db.DB.Exec(`
-			INSERT INTO kms_key_rotation (key_id, last_rotated_at, next_rotation_at)
-			VALUES ($1, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + INTERVAL '3 months')
-		`, keyID)
-		if err != nil {
-			slog.Error("failed to create rotation record", "error", err)
-			return errors.New("failed to create rotation record")
+	
+	if active.CompletedAt == nil {
+		if err := queue.EnqueueKMSRotation(active.KeyID); err != nil {
+			slog.Error("failed to resume KMS rotation", "error", err)
+			return errors.New("failed to resume KMS rotation")
 		}
-		slog.Info("Created new KMS rotation record")
+		slog.Info("Resuming unfinished KMS rotation", "key_id", active.KeyID, "version", active.Version)
 	}
 
 	
-	if err := queue.ScheduleKMSRotation(keyID); err != nil {
+	if err := queue.ScheduleKMSRotation(active.KeyID, active.NextRotationAt); err != nil {
 		slog.Error("failed to schedule KMS rotation", "error", err)
 		return errors.New("failed to schedule KMS rotation")
 	}
 
-	slog.Info("KMS rotation initialized successfully")
+	slog.Info("KMS rotation initialized successfully", "key_id", active.KeyID, "version", active.Version)
 	return nil
 }
@@ -0,0 +1,87 @@
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"integratorV2/internal/db"
+)
+
+const reindexBatchSize = 100
+
+// ExtractSnapshotEndpoints lists the requests of a stored collection for endpoint search, in
+// item tree order. Folder and request names are joined with " / " to form the item path.
+func ExtractSnapshotEndpoints(content json.RawMessage) ([]db.SnapshotEndpoint, error) {
+	collection, err := ParseCollectionJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []db.SnapshotEndpoint
+	var walk func(items []CollectionItem, parents []string)
+	walk = func(items []CollectionItem, parents []string) {
+		for _, item := range items {
+			path := append(append([]string{}, parents...), item.Name)
+			if item.Request == nil {
+				walk(item.Item, path)
+				continue
+			}
+			endpoint := newSemanticEndpoint(item)
+			endpoints = append(endpoints, db.SnapshotEndpoint{
+				ItemPath:    strings.Join(path, " / "),
+				Name:        endpoint.name,
+				Method:      endpoint.method,
+				Path:        endpoint.path,
+				HeaderNames: sortedKeys(endpoint.headers),
+				BodyFields:  sortedKeys(endpoint.bodyFields),
+			})
+		}
+	}
+	walk(collection.Item, nil)
+	return endpoints, nil
+}
+
+// IndexSnapshotEndpoints extracts the endpoints of a snapshot and replaces its search index rows.
+func IndexSnapshotEndpoints(collectionID string, snapshotID int64, content json.RawMessage) error {
+	endpoints, err := ExtractSnapshotEndpoints(content)
+	if err != nil {
+		return fmt.Errorf("failed to extract endpoints of snapshot %d: %w", snapshotID, err)
+	}
+	return db.SaveSnapshotEndpoints(collectionID, snapshotID, endpoints)
+}
+
+// indexNewSnapshot indexes a snapshot that was just stored. The search index can be rebuilt with
+// ReindexSnapshotEndpoints, so a failure is logged instead of failing the import.
+func indexNewSnapshot(collectionID string, snapshotID int64, content json.RawMessage) {
+	if err := IndexSnapshotEndpoints(collectionID, snapshotID, content); err != nil {
+		slog.Error("Failed to index snapshot endpoints", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+	}
+}
+
+// ReindexSnapshotEndpoints indexes every snapshot that has no endpoints in the search index yet,
+// such as those stored before endpoint search existed, and returns how many were indexed.
+// Snapshots that cannot be parsed are logged and skipped.
+func ReindexSnapshotEndpoints() (int, error) {
+	indexed := 0
+	var afterID int64
+	for {
+		snapshots, err := db.GetUnindexedSnapshots(afterID, reindexBatchSize)
+		if err != nil {
+			return indexed, err
+		}
+		if len(snapshots) == 0 {
+			return indexed, nil
+		}
+
+		for _, snapshot := range snapshots {
+			afterID = snapshot.ID
+			if err := IndexSnapshotEndpoints(snapshot.CollectionID, snapshot.ID, snapshot.Content); err != nil {
+				slog.Warn("Skipping snapshot", "error", err, "collection_id", snapshot.CollectionID, "snapshot_id", snapshot.ID)
+				continue
+			}
+			indexed++
+		}
+	}
+}
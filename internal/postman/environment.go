@@ -0,0 +1,259 @@
+package postman
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"integratorV2/internal/db"
+)
+
+// PostmanEnvironment is an environment as listed by the Postman API.
+type PostmanEnvironment struct {
+	ID        string `json:"id"`
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	Owner     string `json:"owner,omitempty"`
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+type PostmanEnvironmentsResponse struct {
+	Environments []PostmanEnvironment `json:"environments"`
+}
+
+// Environment is the versioned part of a Postman environment. Metadata such as the owner and the
+// timestamps is dropped so it cannot create new versions.
+type Environment struct {
+	ID     string             `json:"id"`
+	Name   string             `json:"name"`
+	Values []EnvironmentValue `json:"values"`
+}
+
+// EnvironmentValue is an environment variable. Postman treats a value without an enabled flag
+// as enabled.
+type EnvironmentValue struct {
+	Key     string      `json:"key"`
+	Value   interface{} `json:"value"`
+	Type    string      `json:"type,omitempty"`
+	Enabled *bool       `json:"enabled,omitempty"`
+}
+
+func (v EnvironmentValue) IsEnabled() bool {
+	return v.Enabled == nil || *v.Enabled
+}
+
+func GetEnvironments(apiKey string) ([]PostmanEnvironment, error) {
+	req, err := http.NewRequest("GET", PostmanAPIBaseURL+"/environments", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode}
+	}
+
+	var result PostmanEnvironmentsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+
+	return result.Environments, nil
+}
+
+func GetEnvironment(apiKey, environmentID string) (*Environment, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/environments/%s", PostmanAPIBaseURL, environmentID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode}
+	}
+
+	var wrapper struct {
+		Environment Environment `json:"environment"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+
+	return &wrapper.Environment, nil
+}
+
+// MaskEnvironment masks environment values with the field name and value detectors of config.
+// Environments usually hold credentials, so they are masked even when config disables masking,
+// and values of type secret are always masked.
+func MaskEnvironment(environment *Environment, config *MaskingConfig) (*Environment, []MaskedValue, error) {
+	masker, err := NewCollectionMasker(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := &maskingContext{masker: masker}
+	masked := *environment
+	masked.Values = make([]EnvironmentValue, len(environment.Values))
+	for i, value := range environment.Values {
+		masked.Values[i] = value
+		masked.Values[i].Value = masker.maskVariableValue(ctx, fmt.Sprintf("values[%d].value", i), value.Key, value.Type, value.Value)
+	}
+	return &masked, ctx.masked, nil
+}
+
+// StoreEnvironmentSnapshot masks environment with the scope's default masking policy and stores
+// it as a new snapshot, with its changes against the previous one, unless nothing changed.
+func StoreEnvironmentSnapshot(environmentID, name string, environment *Environment, scope db.Scope) (*SnapshotResult, error) {
+	if err := db.StoreEnvironment(environmentID, name, scope); err != nil {
+		return nil, err
+	}
+
+	config, _, err := LoadDefaultMaskingConfig(scope)
+	if err != nil {
+		return nil, err
+	}
+	masked, _, err := MaskEnvironment(environment, config)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(masked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode environment: %w", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse environment: %w", err)
+	}
+	hash := semanticHash(document)
+
+	previous, err := db.GetLatestEnvironmentSnapshot(environmentID)
+	if err != nil && !errors.Is(err, db.ErrEnvironmentSnapshotNotFound) {
+		return nil, err
+	}
+
+	result := &SnapshotResult{}
+	var changes []db.EnvironmentChange
+	if previous != nil {
+		if previous.Hash == hash {
+			return &SnapshotResult{Identical: true}, nil
+		}
+
+		var previousEnvironment Environment
+		if err := json.Unmarshal(previous.Content, &previousEnvironment); err != nil {
+			return nil, fmt.Errorf("failed to parse environment snapshot %d: %w", previous.ID, err)
+		}
+		changes = CompareEnvironments(&previousEnvironment, masked)
+		result.PreviousSnapshotID = &previous.ID
+	}
+
+	snapshotID, err := db.CreateEnvironmentSnapshot(&db.EnvironmentSnapshot{
+		EnvironmentID: environmentID,
+		Content:       content,
+		Hash:          hash,
+		VariableCount: len(masked.Values),
+	}, result.PreviousSnapshotID, changes)
+	if err != nil {
+		return nil, err
+	}
+	result.SnapshotID = snapshotID
+	result.ChangeCount = len(changes)
+
+	slog.Info("Created environment snapshot",
+		"environment_id", environmentID,
+		"snapshot_id", snapshotID,
+		"change_count", len(changes))
+	return result, nil
+}
+
+// CompareEnvironments lists the variable changes between two versions of an environment.
+// Variables are matched by key; when a key repeats, its first occurrence is compared.
+func CompareEnvironments(old, new *Environment) []db.EnvironmentChange {
+	oldKeys, oldValues := environmentValuesByKey(old)
+	newKeys, newValues := environmentValuesByKey(new)
+
+	var changes []db.EnvironmentChange
+	for _, key := range newKeys {
+		newValue := newValues[key]
+		oldValue, ok := oldValues[key]
+		if !ok {
+			value := environmentValueString(newValue.Value)
+			changes = append(changes, db.EnvironmentChange{ChangeType: "added", VariableKey: key, NewValue: &value})
+			continue
+		}
+
+		if before, after := environmentValueString(oldValue.Value), environmentValueString(newValue.Value); before != after {
+			changes = append(changes, environmentFieldChange(key, "value", before, after))
+		}
+		if before, after := oldValue.IsEnabled(), newValue.IsEnabled(); before != after {
+			changes = append(changes, environmentFieldChange(key, "enabled", strconv.FormatBool(before), strconv.FormatBool(after)))
+		}
+		if oldValue.Type != newValue.Type {
+			changes = append(changes, environmentFieldChange(key, "type", oldValue.Type, newValue.Type))
+		}
+	}
+
+	for _, key := range oldKeys {
+		if _, ok := newValues[key]; !ok {
+			value := environmentValueString(oldValues[key].Value)
+			changes = append(changes, db.EnvironmentChange{ChangeType: "deleted", VariableKey: key, OldValue: &value})
+		}
+	}
+	return changes
+}
+
+func environmentValuesByKey(environment *Environment) ([]string, map[string]EnvironmentValue) {
+	var keys []string
+	values := make(map[string]EnvironmentValue, len(environment.Values))
+	for _, value := range environment.Values {
+		if _, ok := values[value.Key]; ok {
+			continue
+		}
+		keys = append(keys, value.Key)
+		values[value.Key] = value
+	}
+	return keys, values
+}
+
+func environmentFieldChange(key, field, before, after string) db.EnvironmentChange {
+	return db.EnvironmentChange{
+		ChangeType:  "modified",
+		VariableKey: key,
+		Field:       &field,
+		OldValue:    &before,
+		NewValue:    &after,
+	}
+}
+
+func environmentValueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,147 @@
+package postman
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"integratorV2/internal/db"
+)
+
+var ErrNoBaselineSnapshot = errors.New("no stored snapshot to compare against")
+
+type GatePolicy struct {
+	FailOnBreaking bool     `json:"fail_on_breaking"`
+	FailOnSecurity bool     `json:"fail_on_security"`
+	MaxRiskScore   *float64 `json:"max_risk_score,omitempty"`
+}
+
+type GateResult struct {
+	Passed             bool               `json:"passed"`
+	CollectionID       string             `json:"collection_id"`
+	BaselineSnapshotID int64              `json:"baseline_snapshot_id"`
+	Policy             GatePolicy         `json:"policy"`
+	Reasons            []string           `json:"reasons"`
+	TotalChanges       int                `json:"total_changes"`
+	BreakingChanges    []*db.ImpactDetail `json:"breaking_changes"`
+	SecurityChanges    []*db.ImpactDetail `json:"security_changes"`
+	Summary            db.ImpactSummary   `json:"summary"`
+}
+
+func DefaultGatePolicy() GatePolicy {
+	return GatePolicy{
+		FailOnBreaking: true,
+	}
+}
+
+// ParseGatePolicy reads a policy supplied by a caller on top of DefaultGatePolicy, so fields the
+// caller leaves out keep their defaults instead of turning checks off.
+func ParseGatePolicy(data []byte) (GatePolicy, error) {
+	policy := DefaultGatePolicy()
+	if len(data) == 0 {
+		return policy, nil
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return GatePolicy{}, fmt.Errorf("invalid gate policy: %v", err)
+	}
+	return policy, nil
+}
+
+func ParseCollectionJSON(data []byte) (*PostmanCollectionStructure, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid collection JSON: %v", err)
+	}
+
+	if wrapped, ok := probe["collection"]; ok {
+		data = wrapped
+	}
+
+	var collection PostmanCollectionStructure
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("invalid collection JSON: %v", err)
+	}
+
+	if collection.Info.Name == "" {
+		return nil, fmt.Errorf("collection info.name is required")
+	}
+
+	return &collection, nil
+}
+
+func EvaluateGate(collectionID string, candidate *PostmanCollectionStructure, policy GatePolicy, masking *MaskingConfig) (*GateResult, error) {
+	baseline, err := db.GetLatestSnapshot(collectionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoBaselineSnapshot
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	masked, err := MaskCollectionWithConfig(candidate, masking)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mask candidate collection: %w", err)
+	}
+
+	candidateContent, err := json.Marshal(masked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process candidate collection: %w", err)
+	}
+
+	changes, err := ComparePostmanSnapshots(baseline.Content, candidateContent, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare candidate collection: %w", err)
+	}
+
+	details := make([]*db.ChangeDetail, 0, len(changes))
+	for _, change := range changes {
+		detail := &db.ChangeDetail{
+			CollectionID:  collectionID,
+			OldSnapshotID: &baseline.ID,
+			ChangeType:    change.Type,
+			Path:          change.Path,
+			Modification:  change.Modification,
+		}
+		db.EnhanceChangeDetail(detail)
+		details = append(details, detail)
+	}
+
+	analysis := db.AnalyzeChanges(collectionID, baseline.ID, details)
+
+	result := &GateResult{
+		Passed:             true,
+		CollectionID:       collectionID,
+		BaselineSnapshotID: baseline.ID,
+		Policy:             policy,
+		Reasons:            make([]string, 0),
+		TotalChanges:       len(details),
+		BreakingChanges:    analysis.BreakingChanges,
+		SecurityChanges:    analysis.SecurityChanges,
+		Summary:            analysis.Summary,
+	}
+
+	if policy.FailOnBreaking && analysis.Summary.TotalBreaking > 0 {
+		result.Passed = false
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%d breaking change(s) detected", analysis.Summary.TotalBreaking))
+	}
+
+	if policy.FailOnSecurity && analysis.Summary.TotalSecurity > 0 {
+		result.Passed = false
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%d security change(s) detected", analysis.Summary.TotalSecurity))
+	}
+
+	if policy.MaxRiskScore != nil && analysis.Summary.RiskScore >= *policy.MaxRiskScore {
+		result.Passed = false
+		result.Reasons = append(result.Reasons, fmt.Sprintf("risk score %.1f is at or above threshold %.1f", analysis.Summary.RiskScore, *policy.MaxRiskScore))
+	}
+
+	slog.Info("Evaluated breaking-change gate",
+		"collection_id", collectionID,
+		"baseline_snapshot_id", baseline.ID,
+		"change_count", len(details),
+		"passed", result.Passed)
+
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package postman
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGatePolicy(t *testing.T) {
+	score := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name    string
+		data    string
+		want    GatePolicy
+		wantErr string
+	}{
+		{
+			name: "no policy",
+			data: "",
+			want: GatePolicy{FailOnBreaking: true},
+		},
+		{
+			name: "null policy",
+			data: "null",
+			want: GatePolicy{FailOnBreaking: true},
+		},
+		{
+			name: "partial policy keeps fail_on_breaking",
+			data: `{"max_risk_score": 60}`,
+			want: GatePolicy{FailOnBreaking: true, MaxRiskScore: score(60)},
+		},
+		{
+			name: "partial policy adds security check",
+			data: `{"fail_on_security": true}`,
+			want: GatePolicy{FailOnBreaking: true, FailOnSecurity: true},
+		},
+		{
+			name: "explicitly disabled breaking check",
+			data: `{"fail_on_breaking": false, "max_risk_score": 80}`,
+			want: GatePolicy{FailOnBreaking: false, MaxRiskScore: score(80)},
+		},
+		{
+			name:    "malformed policy",
+			data:    `{"max_risk_score": "high"}`,
+			wantErr: "invalid gate policy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGatePolicy([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseGatePolicy() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGatePolicy() error = %v", err)
+			}
+			if got.FailOnBreaking != tt.want.FailOnBreaking || got.FailOnSecurity != tt.want.FailOnSecurity {
+				t.Errorf("ParseGatePolicy() = %+v, want %+v", got, tt.want)
+			}
+			if (got.MaxRiskScore == nil) != (tt.want.MaxRiskScore == nil) ||
+				(got.MaxRiskScore != nil && *got.MaxRiskScore != *tt.want.MaxRiskScore) {
+				t.Errorf("MaxRiskScore = %v, want %v", got.MaxRiskScore, tt.want.MaxRiskScore)
+			}
+		})
+	}
+}
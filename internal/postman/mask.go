@@ -11,6 +11,7 @@ import (
 	"io"
 	"log/slog"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,8 +23,9 @@ import (
 type PostmanCollectionStructure struct {
 	Info  CollectionInfo   `json:"info"`
 	Item  []CollectionItem `json:"item"`
-	Event []interface{}    `json:"event,omitempty"`
-	Variable []interface{} `json:"variable,omitempty"`
+	Event []Event          `json:"event,omitempty"`
+	Variable []Variable    `json:"variable,omitempty"`
+	Auth  *Auth            `json:"auth,omitempty"`
 }
 
 
@@ -40,6 +42,9 @@ type CollectionItem struct {
 	Request  *Request         `json:"request,omitempty"`
 	Response []Response       `json:"response,omitempty"`
 	Item     []CollectionItem `json:"item,omitempty"` 
+	Event    []Event          `json:"event,omitempty"`
+	Variable []Variable       `json:"variable,omitempty"`
+	Auth     *Auth            `json:"auth,omitempty"`
 }
 
 
@@ -47,7 +52,8 @@ type Request struct {
 	Method string      `json:"method"`
 	Header []Header    `json:"header"`
 	Body   *Body       `json:"body,omitempty"`
-	URL    interface{} `json:"url"` 
+	URL    *RequestURL `json:"url"` 
+	Auth   *Auth       `json:"auth,omitempty"`
 }
 
 
@@ -75,10 +81,172 @@ type Response struct {
 }
 
 
+// RequestURL is a request URL, which collections store either as a plain string or as an object
+// with its parts. A plain string is marshaled back as a string.
+type RequestURL struct {
+	Raw      string       `json:"raw,omitempty"`
+	Protocol string       `json:"protocol,omitempty"`
+	Host     interface{}  `json:"host,omitempty"`
+	Port     string       `json:"port,omitempty"`
+	Path     interface{}  `json:"path,omitempty"`
+	Query    []QueryParam `json:"query,omitempty"`
+	Hash     string       `json:"hash,omitempty"`
+	Variable []Variable   `json:"variable,omitempty"`
+	plain    bool
+}
+
+type requestURLObject RequestURL
+
+func (u *RequestURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = RequestURL{Raw: raw, plain: true}
+		return nil
+	}
+
+	var object requestURLObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*u = RequestURL(object)
+	return nil
+}
+
+func (u RequestURL) MarshalJSON() ([]byte, error) {
+	if u.plain {
+		return json.Marshal(u.Raw)
+	}
+	return json.Marshal(requestURLObject(u))
+}
+
+
+type QueryParam struct {
+	Key         string      `json:"key"`
+	Value       string      `json:"value"`
+	Disabled    bool        `json:"disabled,omitempty"`
+	Description interface{} `json:"description,omitempty"`
+}
+
+
+// Variable is a collection, folder or URL path variable. Values are usually strings but the
+// schema allows any JSON value.
+type Variable struct {
+	ID          string      `json:"id,omitempty"`
+	Key         string      `json:"key"`
+	Value       interface{} `json:"value,omitempty"`
+	Type        string      `json:"type,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Description interface{} `json:"description,omitempty"`
+	Disabled    bool        `json:"disabled,omitempty"`
+}
+
+
+// Event is a pre-request or test script.
+type Event struct {
+	ID       string  `json:"id,omitempty"`
+	Listen   string  `json:"listen"`
+	Script   *Script `json:"script,omitempty"`
+	Disabled bool    `json:"disabled,omitempty"`
+}
+
+
+type Script struct {
+	ID   string      `json:"id,omitempty"`
+	Type string      `json:"type,omitempty"`
+	Exec *ScriptExec `json:"exec,omitempty"`
+	Src  interface{} `json:"src,omitempty"`
+	Name string      `json:"name,omitempty"`
+}
+
+
+// ScriptExec holds script source, which collections store as a list of lines or as one string.
+type ScriptExec struct {
+	Lines  []string
+	single bool
+}
+
+func (e *ScriptExec) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*e = ScriptExec{Lines: []string{single}, single: true}
+		return nil
+	}
+	return json.Unmarshal(data, &e.Lines)
+}
+
+func (e ScriptExec) MarshalJSON() ([]byte, error) {
+	if e.single && len(e.Lines) == 1 {
+		return json.Marshal(e.Lines[0])
+	}
+	return json.Marshal(e.Lines)
+}
+
+
+// Auth is a collection, folder or request auth block. Postman stores the parameters of each auth
+// type as a list of attributes under the type's name, for example "bearer": [{"key": "token"}].
+type Auth struct {
+	Type       string
+	Attributes map[string][]AuthAttribute
+	other      map[string]json.RawMessage
+}
+
+type AuthAttribute struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value,omitempty"`
+	Type  string      `json:"type,omitempty"`
+}
+
+func (a *Auth) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*a = Auth{Attributes: make(map[string][]AuthAttribute)}
+	for name, value := range fields {
+		if name == "type" {
+			if err := json.Unmarshal(value, &a.Type); err != nil {
+				return fmt.Errorf("invalid auth type: %w", err)
+			}
+			continue
+		}
+
+		var attributes []AuthAttribute
+		if err := json.Unmarshal(value, &attributes); err != nil {
+			if a.other == nil {
+				a.other = make(map[string]json.RawMessage)
+			}
+			a.other[name] = value
+			continue
+		}
+		a.Attributes[name] = attributes
+	}
+	return nil
+}
+
+func (a Auth) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(a.Attributes)+len(a.other)+1)
+	for name, value := range a.other {
+		fields[name] = value
+	}
+	for name, attributes := range a.Attributes {
+		fields[name] = attributes
+	}
+	fields["type"] = a.Type
+	return json.Marshal(fields)
+}
+
+
+// MaskingConfig controls what CollectionMasker masks. CustomPatterns match field names and
+// CustomValuePatterns match values. Values matching PreservePatterns, such as example emails, and
+// values under SkipPaths are never masked. Skip paths use the masking path syntax, for example
+// item[*].request.body.raw.user.email, where * matches within one segment and ** across segments.
 type MaskingConfig struct {
 	Enabled              bool                      `json:"enabled"`
 	SkipPaths            []string                  `json:"skip_paths"`
 	CustomPatterns       map[string]string         `json:"custom_patterns"`
+	CustomValuePatterns  map[string]string         `json:"custom_value_patterns"`
+	PreservePatterns     []string                  `json:"preserve_patterns"`
 	PreserveMaskedValues bool                      `json:"preserve_masked_values"`
 	MaskingKey           string                    `json:"-"`
 	AllowPartialMasking  bool                      `json:"allow_partial_masking"`
@@ -87,6 +255,9 @@ type MaskingConfig struct {
 	MaskResponseBodies   bool                      `json:"mask_response_bodies"`
 	MaskHeaders          bool                      `json:"mask_headers"`
 	customRegexps        map[string]*regexp.Regexp
+	customValueRegexps   map[string]*regexp.Regexp
+	preserveRegexps      []*regexp.Regexp
+	skipPathRegexps      []*regexp.Regexp
 }
 
 
@@ -94,6 +265,9 @@ func DefaultMaskingConfig() *MaskingConfig {
 	return &MaskingConfig{
 		Enabled:              true,
 		SkipPaths:            []string{},
+		CustomPatterns:       map[string]string{},
+		CustomValuePatterns:  map[string]string{},
+		PreservePatterns:     []string{},
 		PreserveMaskedValues: false,
 		AllowPartialMasking:  true,
 		LogMasking:           false,
@@ -104,20 +278,79 @@ func DefaultMaskingConfig() *MaskingConfig {
 	}
 }
 
+// compile validates the configured patterns and skip paths.
+func (c *MaskingConfig) compile() error {
+	c.customRegexps = make(map[string]*regexp.Regexp)
+	for name, pattern := range c.CustomPatterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid custom pattern %s: %w", name, err)
+		}
+		c.customRegexps[name] = regex
+	}
 
+	c.customValueRegexps = make(map[string]*regexp.Regexp)
+	for name, pattern := range c.CustomValuePatterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid custom value pattern %s: %w", name, err)
+		}
+		c.customValueRegexps[name] = regex
+	}
+
+	c.preserveRegexps = nil
+	for _, pattern := range c.PreservePatterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid preserve pattern %q: %w", pattern, err)
+		}
+		c.preserveRegexps = append(c.preserveRegexps, regex)
+	}
+
+	c.skipPathRegexps = nil
+	for _, path := range c.SkipPaths {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("skip paths cannot be empty")
+		}
+		c.skipPathRegexps = append(c.skipPathRegexps, compileSkipPath(path))
+	}
+	return nil
+}
+
+// compileSkipPath matches the path itself and everything below it.
+func compileSkipPath(path string) *regexp.Regexp {
+	pattern := regexp.QuoteMeta(path)
+	pattern = strings.ReplaceAll(pattern, `\*\*`, `.*`)
+	pattern = strings.ReplaceAll(pattern, `\*`, `[^.\[\]]*`)
+	return regexp.MustCompile(`^` + pattern + `(?:$|[.\[])`)
+}
+
+
+// MaskedValue describes one masked value. Reason is field_name when the field name marked the
+// value as sensitive, value when the value itself matched and secret_variable for variables of
+// type secret; Pattern names the pattern that matched, prefixed with custom: for policy patterns.
+// Values found inside a script line or a raw URL are recorded once for the whole string.
 type MaskedValue struct {
 	Masked    string  `json:"masked"`
 	Encrypted *string `json:"encrypted,omitempty"`
 	Type      string  `json:"type"`
 	Path      string  `json:"path"`
+	Field     string  `json:"field,omitempty"`
+	Reason    string  `json:"reason"`
+	Pattern   string  `json:"pattern"`
 }
 
+const (
+	MaskReasonFieldName = "field_name"
+	MaskReasonValue     = "value"
+	MaskReasonSecretVariable = "secret_variable"
+)
 
 
-
-
+// MaskedValues is kept out of the JSON so the stored snapshot holds only the collection.
 type MaskingResult struct {
-	Collection *PostmanCollectionStructure `json:"collection"`
+	Collection   *PostmanCollectionStructure `json:"collection"`
+	MaskedValues []MaskedValue               `json:"-"`
 }
 
 
@@ -127,6 +360,13 @@ type PatternRegistry struct {
 	mu            sync.RWMutex
 }
 
+// Patterns are tried in a fixed order so a value matching several of them is always masked and
+// reported the same way. More specific value patterns come first.
+var (
+	fieldPatternOrder = []string{"password", "secret", "api_key", "access_key", "oauth", "auth_token", "session", "credential", "email"}
+	valuePatternOrder = []string{"jwt_token", "bearer_token", "email", "credit_card", "ssn", "phone", "ip_address", "api_key_like", "base64_long"}
+)
+
 var patternRegistry *PatternRegistry
 var registryOnce sync.Once
 
@@ -188,19 +428,12 @@ func NewCollectionMasker(config *MaskingConfig) (*CollectionMasker, error) {
 	}
 	
 	initPatternRegistry()
-	
-	
-	if config.CustomPatterns != nil {
-		config.customRegexps = make(map[string]*regexp.Regexp)
-		for name, pattern := range config.CustomPatterns {
-			regex, err := regexp.Compile(pattern)
-			if err != nil {
-				return nil, fmt.Errorf("invalid custom pattern %s: %w", name, err)
-			}
-			config.customRegexps[name] = regex
-		}
+
+
+	if err := config.compile(); err != nil {
+		return nil, err
 	}
-	
+
 	masker := &CollectionMasker{
 		config:    config,
 		validator: validator.New(),
@@ -252,6 +485,9 @@ func (m *CollectionMasker) MaskCollection(collection *PostmanCollectionStructure
 	
 	
 	maskedCollection.Item = m.maskItems(ctx, collection.Item, "item")
+	maskedCollection.Variable = m.maskVariables(ctx, collection.Variable, "variable")
+	maskedCollection.Event = m.maskEvents(ctx, collection.Event, "event")
+	maskedCollection.Auth = m.maskAuth(ctx, collection.Auth, "auth")
 	
 
 	
@@ -261,15 +497,15 @@ func (m *CollectionMasker) MaskCollection(collection *PostmanCollectionStructure
 	}
 	
 	return &MaskingResult{
-		Collection: maskedCollection,
-
+		Collection:   maskedCollection,
+		MaskedValues: ctx.masked,
 	}, nil
 }
 
 
 type maskingContext struct {
 	masker *CollectionMasker
-
+	masked []MaskedValue
 }
 
 
@@ -302,6 +538,10 @@ func (m *CollectionMasker) maskItem(ctx *maskingContext, item CollectionItem, pa
 	if len(item.Item) > 0 {
 		maskedItem.Item = m.maskItems(ctx, item.Item, path+".item")
 	}
+
+	maskedItem.Variable = m.maskVariables(ctx, item.Variable, path+".variable")
+	maskedItem.Event = m.maskEvents(ctx, item.Event, path+".event")
+	maskedItem.Auth = m.maskAuth(ctx, item.Auth, path+".auth")
 	
 	return maskedItem
 }
@@ -325,6 +565,9 @@ func (m *CollectionMasker) maskRequest(ctx *maskingContext, request *Request, pa
 			Options: request.Body.Options,
 		}
 	}
+
+	maskedRequest.URL = m.maskURL(ctx, request.URL, path+".url")
+	maskedRequest.Auth = m.maskAuth(ctx, request.Auth, path+".auth")
 	
 	return maskedRequest
 }
@@ -381,17 +624,13 @@ func (m *CollectionMasker) maskHeaders(ctx *maskingContext, headers []Header, pa
 
 func (m *CollectionMasker) maskHeaderValue(ctx *maskingContext, key, value, path string) string {
 	
-	if m.isSensitiveField(key) {
-		masked := m.applyMaskingStrategy("header", value)
-		m.recordMaskedValue(ctx, path, key, value, masked, "header")
-		return masked
+	if pattern, isSensitive := m.sensitiveFieldPattern(key); isSensitive {
+		return m.maskValue(ctx, path, key, value, "header", MaskReasonFieldName, pattern)
 	}
 	
 	
-	if dataType, isSensitive := m.detectSensitiveValue(value); isSensitive {
-		masked := m.applyMaskingStrategy(dataType, value)
-		m.recordMaskedValue(ctx, path, key, value, masked, dataType)
-		return masked
+	if dataType, pattern, isSensitive := m.detectSensitiveValue(value); isSensitive {
+		return m.maskValue(ctx, path, key, value, dataType, MaskReasonValue, pattern)
 	}
 	
 	return value
@@ -409,10 +648,8 @@ func (m *CollectionMasker) maskRawBody(ctx *maskingContext, body, path string) s
 	}
 	
 	
-	if dataType, isSensitive := m.detectSensitiveValue(body); isSensitive {
-		masked := m.applyMaskingStrategy(dataType, body)
-		m.recordMaskedValue(ctx, path, "raw", body, masked, dataType)
-		return masked
+	if dataType, pattern, isSensitive := m.detectSensitiveValue(body); isSensitive {
+		return m.maskValue(ctx, path, "raw", body, dataType, MaskReasonValue, pattern)
 	}
 	
 	return body
@@ -425,14 +662,15 @@ func (m *CollectionMasker) maskJSON(ctx *maskingContext, data interface{}, path
 		masked := make(map[string]interface{})
 		for key, value := range v {
 			keyPath := path + "." + key
-			maskedValue := m.maskJSON(ctx, value, keyPath)
 			
 			
-			if strVal, ok := value.(string); ok && m.isSensitiveField(key) {
-				masked[key] = m.maskSensitiveValue(ctx, key, strVal, keyPath)
-			} else {
-				masked[key] = maskedValue
+			if strVal, ok := value.(string); ok {
+				if pattern, isSensitive := m.sensitiveFieldPattern(key); isSensitive {
+					masked[key] = m.maskSensitiveValue(ctx, key, strVal, keyPath, pattern)
+					continue
+				}
 			}
+			masked[key] = m.maskJSON(ctx, value, keyPath)
 		}
 		return masked
 		
@@ -446,10 +684,8 @@ func (m *CollectionMasker) maskJSON(ctx *maskingContext, data interface{}, path
 		
 	case string:
 		
-		if dataType, isSensitive := m.detectSensitiveValue(v); isSensitive {
-			masked := m.applyMaskingStrategy(dataType, v)
-			m.recordMaskedValue(ctx, path, "value", v, masked, dataType)
-			return masked
+		if dataType, pattern, isSensitive := m.detectSensitiveValue(v); isSensitive {
+			return m.maskValue(ctx, path, "", v, dataType, MaskReasonValue, pattern)
 		}
 		return v
 		
@@ -459,19 +695,316 @@ func (m *CollectionMasker) maskJSON(ctx *maskingContext, data interface{}, path
 }
 
 
-func (m *CollectionMasker) maskSensitiveValue(ctx *maskingContext, fieldName, value, path string) string {
-	dataType := m.getFieldType(fieldName)
+// variableReferencePattern matches values that only reference a Postman variable, such as
+// {{token}}. They hold no secret themselves.
+var variableReferencePattern = regexp.MustCompile(`^\s*\{\{[^{}]+\}\}\s*$`)
+
+func isVariableReference(value string) bool {
+	return variableReferencePattern.MatchString(value)
+}
+
+
+// classify applies the field name detectors and then the value detectors.
+func (m *CollectionMasker) classify(fieldName, value string) (dataType, reason, pattern string, ok bool) {
+	if fieldName != "" {
+		if pattern, ok := m.sensitiveFieldPattern(fieldName); ok {
+			return m.getFieldType(fieldName), MaskReasonFieldName, pattern, true
+		}
+	}
+	if dataType, pattern, ok := m.detectSensitiveValue(value); ok {
+		return dataType, MaskReasonValue, pattern, true
+	}
+	return "", "", "", false
+}
+
+
+func (m *CollectionMasker) maskField(ctx *maskingContext, path, fieldName, value string) string {
+	if value == "" || isVariableReference(value) {
+		return value
+	}
+	dataType, reason, pattern, ok := m.classify(fieldName, value)
+	if !ok {
+		return value
+	}
+	return m.maskValue(ctx, path, fieldName, value, dataType, reason, pattern)
+}
+
+
+// maskVariables masks collection, folder and URL variables. Variables of type secret are always
+// masked; other variables go through the field name and value detectors.
+func (m *CollectionMasker) maskVariables(ctx *maskingContext, variables []Variable, path string) []Variable {
+	if len(variables) == 0 {
+		return variables
+	}
+
+	masked := make([]Variable, len(variables))
+	for i, variable := range variables {
+		masked[i] = variable
+		masked[i].Value = m.maskVariableValue(ctx, fmt.Sprintf("%s[%d].value", path, i), variable.Key, variable.Type, variable.Value)
+	}
+	return masked
+}
+
+
+func (m *CollectionMasker) maskVariableValue(ctx *maskingContext, path, key, variableType string, value interface{}) interface{} {
+	str, ok := value.(string)
+	if !ok || str == "" || isVariableReference(str) {
+		return value
+	}
+	if variableType == "secret" {
+		return m.maskValue(ctx, path, key, str, "secret", MaskReasonSecretVariable, "secret")
+	}
+	return m.maskField(ctx, path, key, str)
+}
+
+
+// authSecretAttributes are the auth attributes that hold credentials, such as the bearer token or
+// the value of an API key. Other attributes are only masked when their value looks sensitive.
+var authSecretAttributes = map[string]bool{
+	"token":          true,
+	"password":       true,
+	"value":          true,
+	"accessToken":    true,
+	"refreshToken":   true,
+	"clientSecret":   true,
+	"clientToken":    true,
+	"secret":         true,
+	"secretKey":      true,
+	"sessionToken":   true,
+	"privateKey":     true,
+	"consumerSecret": true,
+	"tokenSecret":    true,
+	"authKey":        true,
+}
+
+func (m *CollectionMasker) maskAuth(ctx *maskingContext, auth *Auth, path string) *Auth {
+	if auth == nil {
+		return nil
+	}
+
+	masked := &Auth{
+		Type:       auth.Type,
+		Attributes: make(map[string][]AuthAttribute, len(auth.Attributes)),
+		other:      auth.other,
+	}
+	for name, attributes := range auth.Attributes {
+		maskedAttributes := make([]AuthAttribute, len(attributes))
+		for i, attribute := range attributes {
+			maskedAttributes[i] = attribute
+			value, ok := attribute.Value.(string)
+			if !ok || value == "" || isVariableReference(value) {
+				continue
+			}
+
+			valuePath := fmt.Sprintf("%s.%s[%d].value", path, name, i)
+			if authSecretAttributes[attribute.Key] {
+				maskedAttributes[i].Value = m.maskValue(ctx, valuePath, attribute.Key, value, m.getFieldType(attribute.Key), MaskReasonFieldName, "auth:"+name)
+				continue
+			}
+			if dataType, pattern, ok := m.detectSensitiveValue(value); ok {
+				maskedAttributes[i].Value = m.maskValue(ctx, valuePath, attribute.Key, value, dataType, MaskReasonValue, pattern)
+			}
+		}
+		masked.Attributes[name] = maskedAttributes
+	}
+	return masked
+}
+
+
+// maskURL masks query parameter values, both in the structured query and in the raw URL, and URL
+// path variables.
+func (m *CollectionMasker) maskURL(ctx *maskingContext, u *RequestURL, path string) *RequestURL {
+	if u == nil {
+		return nil
+	}
+
+	masked := *u
+	rawPath := path + ".raw"
+	if u.plain {
+		rawPath = path
+	}
+	masked.Raw = m.maskEmbeddedValues(ctx, rawPath, u.Raw, rawQueryValues(u.Raw))
+
+	if len(u.Query) > 0 {
+		masked.Query = make([]QueryParam, len(u.Query))
+		for i, param := range u.Query {
+			masked.Query[i] = param
+			masked.Query[i].Value = m.maskField(ctx, fmt.Sprintf("%s.query[%d].value", path, i), param.Key, param.Value)
+		}
+	}
+
+	masked.Variable = m.maskVariables(ctx, u.Variable, path+".variable")
+	return &masked
+}
+
+
+// maskEvents masks string literals in pre-request and test scripts, such as the value in
+// pm.environment.set("token", "...").
+func (m *CollectionMasker) maskEvents(ctx *maskingContext, events []Event, path string) []Event {
+	if len(events) == 0 {
+		return events
+	}
+
+	masked := make([]Event, len(events))
+	for i, event := range events {
+		masked[i] = event
+		if event.Script == nil || event.Script.Exec == nil {
+			continue
+		}
+
+		script := *event.Script
+		exec := ScriptExec{Lines: make([]string, len(script.Exec.Lines)), single: script.Exec.single}
+		for j, line := range script.Exec.Lines {
+			linePath := fmt.Sprintf("%s[%d].script.exec[%d]", path, i, j)
+			exec.Lines[j] = m.maskEmbeddedValues(ctx, linePath, line, scriptValues(line))
+		}
+		script.Exec = &exec
+		masked[i].Script = &script
+	}
+	return masked
+}
+
+
+// embeddedValue is a value inside a larger string, with the name of the field or variable it is
+// assigned to when known.
+type embeddedValue struct {
+	start, end int
+	fieldName  string
+}
+
+var (
+	scriptLiteralPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'`)
+	scriptKeyPattern     = regexp.MustCompile(`(?:["']([\w.\-]+)["']\s*[,:]|([A-Za-z_$][\w$]*)\s*[:=])\s*$`)
+)
+
+// scriptValues returns the string literals of a script line. A literal following "key", or
+// key: or key = is named after that key.
+func scriptValues(line string) []embeddedValue {
+	var values []embeddedValue
+	for _, match := range scriptLiteralPattern.FindAllStringSubmatchIndex(line, -1) {
+		start, end := match[2], match[3]
+		if start < 0 {
+			start, end = match[4], match[5]
+		}
+		if start == end {
+			continue
+		}
+
+		value := embeddedValue{start: start, end: end}
+		if key := scriptKeyPattern.FindStringSubmatch(line[:match[0]]); key != nil {
+			value.fieldName = key[1] + key[2]
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// rawQueryValues returns the query parameter values of a raw URL.
+func rawQueryValues(raw string) []embeddedValue {
+	queryStart := strings.Index(raw, "?")
+	if queryStart < 0 {
+		return nil
+	}
+	end := len(raw)
+	if i := strings.Index(raw[queryStart:], "#"); i >= 0 {
+		end = queryStart + i
+	}
+
+	var values []embeddedValue
+	offset := queryStart + 1
+	for _, pair := range strings.Split(raw[offset:end], "&") {
+		if key, value, ok := strings.Cut(pair, "="); ok && value != "" {
+			start := offset + len(key) + 1
+			values = append(values, embeddedValue{start: start, end: start + len(value), fieldName: key})
+		}
+		offset += len(pair) + 1
+	}
+	return values
+}
+
+// maskEmbeddedValues masks the sensitive values inside text and records text as one masked value,
+// with the type and pattern of the first match, so it can be unmasked as a whole.
+func (m *CollectionMasker) maskEmbeddedValues(ctx *maskingContext, path, text string, values []embeddedValue) string {
+	if len(values) == 0 || m.isSkippedPath(path) {
+		return text
+	}
+
+	var sb strings.Builder
+	var fieldName, dataType, reason, pattern string
+	last := 0
+	for _, v := range values {
+		value := text[v.start:v.end]
+		if isVariableReference(value) || m.isPreservedValue(value) {
+			continue
+		}
+		valueType, valueReason, valuePattern, ok := m.classify(v.fieldName, value)
+		if !ok {
+			continue
+		}
+
+		sb.WriteString(text[last:v.start])
+		sb.WriteString(m.applyMaskingStrategy(valueType, value))
+		last = v.end
+		if dataType == "" {
+			fieldName, dataType, reason, pattern = v.fieldName, valueType, valueReason, valuePattern
+		}
+	}
+	if dataType == "" {
+		return text
+	}
+
+	sb.WriteString(text[last:])
+	masked := sb.String()
+	m.recordMaskedValue(ctx, path, fieldName, text, masked, dataType, reason, pattern)
+	return masked
+}
+
+
+func (m *CollectionMasker) maskSensitiveValue(ctx *maskingContext, fieldName, value, path, pattern string) string {
+	return m.maskValue(ctx, path, fieldName, value, m.getFieldType(fieldName), MaskReasonFieldName, pattern)
+}
+
+
+// maskValue masks a value found sensitive unless the policy skips its path or preserves it.
+func (m *CollectionMasker) maskValue(ctx *maskingContext, path, fieldName, value, dataType, reason, pattern string) string {
+	if m.isSkippedPath(path) || m.isPreservedValue(value) {
+		return value
+	}
+
 	masked := m.applyMaskingStrategy(dataType, value)
-	m.recordMaskedValue(ctx, path, fieldName, value, masked, dataType)
+	m.recordMaskedValue(ctx, path, fieldName, value, masked, dataType, reason, pattern)
 	return masked
 }
 
 
-func (m *CollectionMasker) recordMaskedValue(ctx *maskingContext, path, fieldName, original, masked, dataType string) {
-	maskedValue := &MaskedValue{
-		Masked: masked,
-		Type:   dataType,
-		Path:   path,
+func (m *CollectionMasker) isSkippedPath(path string) bool {
+	for _, regex := range m.config.skipPathRegexps {
+		if regex.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+
+func (m *CollectionMasker) isPreservedValue(value string) bool {
+	for _, regex := range m.config.preserveRegexps {
+		if regex.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+
+func (m *CollectionMasker) recordMaskedValue(ctx *maskingContext, path, fieldName, original, masked, dataType, reason, pattern string) {
+	maskedValue := MaskedValue{
+		Masked:  masked,
+		Type:    dataType,
+		Path:    path,
+		Field:   fieldName,
+		Reason:  reason,
+		Pattern: pattern,
 	}
 	
 	
@@ -479,10 +1012,15 @@ func (m *CollectionMasker) recordMaskedValue(ctx *maskingContext, path, fieldNam
 		encrypted, err := m.encryptValue(original)
 		if err == nil {
 			maskedValue.Encrypted = &encrypted
+		} else {
+			slog.Warn("Failed to encrypt masked value, it cannot be unmasked", "error", err, "path", path)
 		}
 	}
 
 	
+	ctx.masked = append(ctx.masked, maskedValue)
+
+	
 	if m.config.LogMasking {
 		slog.Debug("Masked sensitive data",
 			"path", path,
@@ -492,45 +1030,64 @@ func (m *CollectionMasker) recordMaskedValue(ctx *maskingContext, path, fieldNam
 }
 
 
-func (m *CollectionMasker) isSensitiveField(fieldName string) bool {
+// sensitiveFieldPattern returns the name of the pattern that marks fieldName as sensitive.
+func (m *CollectionMasker) sensitiveFieldPattern(fieldName string) (string, bool) {
 	fieldLower := strings.ToLower(fieldName)
 	
 	
 	patternRegistry.mu.RLock()
-	for _, pattern := range patternRegistry.fieldPatterns {
-		if pattern.MatchString(fieldLower) {
+	for _, name := range fieldPatternOrder {
+		if patternRegistry.fieldPatterns[name].MatchString(fieldLower) {
 			patternRegistry.mu.RUnlock()
-			return true
+			return name, true
 		}
 	}
 	patternRegistry.mu.RUnlock()
 	
 	
-	for _, pattern := range m.config.customRegexps {
-		if pattern.MatchString(fieldName) {
-			return true
+	for _, name := range sortedPatternNames(m.config.customRegexps) {
+		if m.config.customRegexps[name].MatchString(fieldName) {
+			return "custom:" + name, true
 		}
 	}
 	
-	return false
+	return "", false
 }
 
 
-func (m *CollectionMasker) detectSensitiveValue(value string) (string, bool) {
+// detectSensitiveValue returns the data type and pattern name of a sensitive value. Custom value
+// patterns are tried first and use their name as the data type.
+func (m *CollectionMasker) detectSensitiveValue(value string) (string, string, bool) {
 	if len(value) < 3 { 
-		return "", false
+		return "", "", false
+	}
+	
+	for _, name := range sortedPatternNames(m.config.customValueRegexps) {
+		if m.config.customValueRegexps[name].MatchString(value) {
+			return name, "custom:" + name, true
+		}
 	}
 	
 	patternRegistry.mu.RLock()
 	defer patternRegistry.mu.RUnlock()
 	
-	for dataType, pattern := range patternRegistry.valuePatterns {
-		if pattern.MatchString(value) {
-			return dataType, true
+	for _, dataType := range valuePatternOrder {
+		if patternRegistry.valuePatterns[dataType].MatchString(value) {
+			return dataType, dataType, true
 		}
 	}
 	
-	return "", false
+	return "", "", false
+}
+
+
+func sortedPatternNames(patterns map[string]*regexp.Regexp) []string {
+	names := make([]string, 0, len(patterns))
+	for name := range patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 
@@ -0,0 +1,147 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+
+	"integratorV2/internal/db"
+)
+
+// ParseMaskingConfig reads a stored or submitted masking policy. Fields the policy leaves out
+// keep their default values.
+func ParseMaskingConfig(data json.RawMessage) (*MaskingConfig, error) {
+	config := DefaultMaskingConfig()
+	if len(data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("invalid masking policy: %v", err)
+		}
+	}
+
+	if err := config.compile(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+const (
+	MaskingSourceCollection = "collection"
+	MaskingSourceDefault    = "default"
+	MaskingSourceBuiltIn    = "built_in"
+	MaskingSourceRequest    = "request"
+)
+
+// MaskingPolicySource names the policy a masking configuration was loaded from.
+type MaskingPolicySource struct {
+	Source   string `json:"source"`
+	PolicyID *int64 `json:"policy_id,omitempty"`
+}
+
+// LoadMaskingConfig returns the masking configuration of the collection's policy, the scope's
+// default policy, or the built-in defaults, in that order.
+func LoadMaskingConfig(collectionID string, scope db.Scope) (*MaskingConfig, MaskingPolicySource, error) {
+	return maskingConfigFromPolicy(db.GetEffectiveMaskingPolicy(collectionID, scope))
+}
+
+// LoadDefaultMaskingConfig returns the masking configuration of the scope's default policy, or
+// the built-in defaults.
+func LoadDefaultMaskingConfig(scope db.Scope) (*MaskingConfig, MaskingPolicySource, error) {
+	return maskingConfigFromPolicy(db.GetDefaultMaskingPolicy(scope))
+}
+
+func maskingConfigFromPolicy(policy *db.MaskingPolicy, err error) (*MaskingConfig, MaskingPolicySource, error) {
+	if errors.Is(err, db.ErrMaskingPolicyNotFound) {
+		return DefaultMaskingConfig(), MaskingPolicySource{Source: MaskingSourceBuiltIn}, nil
+	}
+	if err != nil {
+		return nil, MaskingPolicySource{}, err
+	}
+
+	source := MaskingPolicySource{Source: MaskingSourceDefault, PolicyID: &policy.ID}
+	if policy.CollectionID != nil {
+		source.Source = MaskingSourceCollection
+	}
+
+	config, err := ParseMaskingConfig(policy.Config)
+	if err != nil {
+		return nil, source, fmt.Errorf("masking policy %d: %w", policy.ID, err)
+	}
+	return config, source, nil
+}
+
+func MaskCollectionWithConfig(collection *PostmanCollectionStructure, config *MaskingConfig) (*MaskingResult, error) {
+	masker, err := NewCollectionMasker(config)
+	if err != nil {
+		return nil, err
+	}
+	return masker.MaskCollection(collection)
+}
+
+// MaskingReport lists every masked path, the rule that masked it and the counts per data type.
+// It never includes original values.
+type MaskingReport struct {
+	Enabled     bool                `json:"enabled"`
+	Policy      MaskingPolicySource `json:"policy"`
+	MaskedCount int                 `json:"masked_count"`
+	ByType      map[string]int      `json:"by_type"`
+	Masked      []MaskedValue       `json:"masked"`
+}
+
+func NewMaskingReport(result *MaskingResult, config *MaskingConfig, source MaskingPolicySource) *MaskingReport {
+	report := &MaskingReport{
+		Enabled: config.Enabled,
+		Policy:  source,
+		ByType:  make(map[string]int),
+		Masked:  make([]MaskedValue, 0, len(result.MaskedValues)),
+	}
+	for _, value := range result.MaskedValues {
+		value.Encrypted = nil
+		report.Masked = append(report.Masked, value)
+		report.ByType[value.Type]++
+	}
+	report.MaskedCount = len(report.Masked)
+
+	sort.Slice(report.Masked, func(i, j int) bool {
+		return report.Masked[i].Path < report.Masked[j].Path
+	})
+	return report
+}
+
+// PreviewMasking masks collection with config and reports what would be masked, without storing
+// anything.
+func PreviewMasking(collection *PostmanCollectionStructure, config *MaskingConfig, source MaskingPolicySource) (*MaskingReport, error) {
+	result, err := MaskCollectionWithConfig(collection, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewMaskingReport(result, config, source), nil
+}
+
+// StoreMaskingReport records the report of a snapshot being stored in tx.
+func StoreMaskingReport(tx *sqlx.Tx, collectionID string, snapshotID int64, report *MaskingReport) error {
+	byType, err := json.Marshal(report.ByType)
+	if err != nil {
+		return fmt.Errorf("failed to encode masking report: %w", err)
+	}
+	entries, err := json.Marshal(report.Masked)
+	if err != nil {
+		return fmt.Errorf("failed to encode masking report: %w", err)
+	}
+
+	return db.SaveSnapshotMaskingReport(tx, &db.SnapshotMaskingReport{
+		SnapshotID:     snapshotID,
+		CollectionID:   collectionID,
+		MaskingEnabled: report.Enabled,
+		PolicySource:   report.Policy.Source,
+		PolicyID:       report.Policy.PolicyID,
+		MaskedCount:    report.MaskedCount,
+		CountsByType:   byType,
+		Entries:        entries,
+	})
+}
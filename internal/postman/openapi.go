@@ -0,0 +1,692 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIDocument is an OpenAPI 3 document converted from a collection. Warnings lists the
+// requests the conversion could not map one to one, such as two requests with the same method and
+// path, which are merged into one operation.
+type OpenAPIDocument struct {
+	OpenAPI  string                      `json:"openapi" yaml:"openapi"`
+	Info     OpenAPIInfo                 `json:"info" yaml:"info"`
+	Servers  []OpenAPIServer             `json:"servers,omitempty" yaml:"servers,omitempty"`
+	Tags     []OpenAPITag                `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Paths    map[string]*OpenAPIPathItem `json:"paths" yaml:"paths"`
+	Warnings []string                    `json:"x-conversion-warnings,omitempty" yaml:"x-conversion-warnings,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+}
+
+type OpenAPIServer struct {
+	URL       string                           `json:"url" yaml:"url"`
+	Variables map[string]OpenAPIServerVariable `json:"variables,omitempty" yaml:"variables,omitempty"`
+}
+
+type OpenAPIServerVariable struct {
+	Default string `json:"default" yaml:"default"`
+}
+
+type OpenAPITag struct {
+	Name string `json:"name" yaml:"name"`
+}
+
+type OpenAPIPathItem struct {
+	Get     *OpenAPIOperation `json:"get,omitempty" yaml:"get,omitempty"`
+	Put     *OpenAPIOperation `json:"put,omitempty" yaml:"put,omitempty"`
+	Post    *OpenAPIOperation `json:"post,omitempty" yaml:"post,omitempty"`
+	Delete  *OpenAPIOperation `json:"delete,omitempty" yaml:"delete,omitempty"`
+	Options *OpenAPIOperation `json:"options,omitempty" yaml:"options,omitempty"`
+	Head    *OpenAPIOperation `json:"head,omitempty" yaml:"head,omitempty"`
+	Patch   *OpenAPIOperation `json:"patch,omitempty" yaml:"patch,omitempty"`
+	Trace   *OpenAPIOperation `json:"trace,omitempty" yaml:"trace,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty" yaml:"summary,omitempty"`
+	OperationID string                      `json:"operationId,omitempty" yaml:"operationId,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses" yaml:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name" yaml:"name"`
+	In       string         `json:"in" yaml:"in"`
+	Required bool           `json:"required,omitempty" yaml:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example  interface{}    `json:"example,omitempty" yaml:"example,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content" yaml:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description" yaml:"description"`
+	Headers     map[string]OpenAPIHeader    `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty" yaml:"content,omitempty"`
+}
+
+type OpenAPIHeader struct {
+	Schema  *OpenAPISchema `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example interface{}    `json:"example,omitempty" yaml:"example,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema  *OpenAPISchema `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Example interface{}    `json:"example,omitempty" yaml:"example,omitempty"`
+}
+
+type OpenAPISchema struct {
+	Type       string                    `json:"type,omitempty" yaml:"type,omitempty"`
+	Format     string                    `json:"format,omitempty" yaml:"format,omitempty"`
+	Nullable   bool                      `json:"nullable,omitempty" yaml:"nullable,omitempty"`
+	Properties map[string]*OpenAPISchema `json:"properties,omitempty" yaml:"properties,omitempty"`
+	Items      *OpenAPISchema            `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+type openAPIQueryParam struct {
+	key   string
+	value string
+}
+
+type openAPIRequestURL struct {
+	server      string
+	path        string
+	pathParams  []string
+	queryParams []openAPIQueryParam
+}
+
+type openAPIBuilder struct {
+	doc          *OpenAPIDocument
+	variables    map[string]string
+	serverIndex  map[string]bool
+	tagIndex     map[string]bool
+	operationIDs map[string]int
+}
+
+var (
+	postmanVariablePattern  = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+	openAPIPathParamPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+)
+
+const openAPIDefaultResponse = "Default response"
+
+// headers OpenAPI describes through other constructs and ignores as header parameters
+var openAPIReservedHeaders = map[string]bool{
+	"accept":        true,
+	"content-type":  true,
+	"authorization": true,
+}
+
+func ConvertToOpenAPI(collection *PostmanCollectionStructure, version string) *OpenAPIDocument {
+	b := &openAPIBuilder{
+		doc: &OpenAPIDocument{
+			OpenAPI: "3.0.3",
+			Info: OpenAPIInfo{
+				Title:   collection.Info.Name,
+				Version: version,
+			},
+			Paths: make(map[string]*OpenAPIPathItem),
+		},
+		variables:    collectionVariables(collection.Variable),
+		serverIndex:  make(map[string]bool),
+		tagIndex:     make(map[string]bool),
+		operationIDs: make(map[string]int),
+	}
+
+	if b.doc.Info.Title == "" {
+		b.doc.Info.Title = "Untitled collection"
+	}
+
+	b.addItems(collection.Item, "")
+	return b.doc
+}
+
+func (d *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func (d *OpenAPIDocument) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(d); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *openAPIBuilder) addItems(items []CollectionItem, tag string) {
+	for _, item := range items {
+		if item.Request == nil {
+			folderTag := tag
+			if folderTag == "" {
+				folderTag = item.Name
+			}
+			b.addItems(item.Item, folderTag)
+			continue
+		}
+		b.addOperation(item, tag)
+	}
+}
+
+func (b *openAPIBuilder) addOperation(item CollectionItem, tag string) {
+	requestURL := parseOpenAPIRequestURL(item.Request.URL)
+
+	pathItem, ok := b.doc.Paths[requestURL.path]
+	if !ok {
+		pathItem = &OpenAPIPathItem{}
+	}
+
+	slot := pathItem.operation(item.Request.Method)
+	if slot == nil {
+		b.warn("%q was left out: method %s is not supported by OpenAPI", item.Name, item.Request.Method)
+		return
+	}
+	b.doc.Paths[requestURL.path] = pathItem
+
+	if requestURL.server != "" && !b.serverIndex[requestURL.server] {
+		b.serverIndex[requestURL.server] = true
+		b.doc.Servers = append(b.doc.Servers, b.server(requestURL.server))
+	}
+
+	if *slot != nil {
+		b.warn("%q was merged into %q: both are %s %s", item.Name, (*slot).Summary, openAPIMethod(item.Request.Method), requestURL.path)
+		b.mergeOperation(*slot, b.newOperation(item, tag, requestURL, ""))
+		return
+	}
+	*slot = b.newOperation(item, tag, requestURL, b.operationID(item.Name, item.Request.Method, requestURL.path))
+}
+
+func (b *openAPIBuilder) newOperation(item CollectionItem, tag string, requestURL openAPIRequestURL, operationID string) *OpenAPIOperation {
+	operation := &OpenAPIOperation{
+		Summary:     item.Name,
+		OperationID: operationID,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	if tag != "" {
+		operation.Tags = []string{tag}
+		b.addTag(tag)
+	}
+
+	for _, name := range requestURL.pathParams {
+		operation.Parameters = append(operation.Parameters, OpenAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &OpenAPISchema{Type: "string"},
+		})
+	}
+
+	for _, query := range requestURL.queryParams {
+		param := OpenAPIParameter{
+			Name:   query.key,
+			In:     "query",
+			Schema: &OpenAPISchema{Type: "string"},
+		}
+		if query.value != "" {
+			param.Example = query.value
+		}
+		operation.Parameters = append(operation.Parameters, param)
+	}
+
+	for _, header := range item.Request.Header {
+		if header.Key == "" || openAPIReservedHeaders[strings.ToLower(header.Key)] {
+			continue
+		}
+		param := OpenAPIParameter{
+			Name:   header.Key,
+			In:     "header",
+			Schema: &OpenAPISchema{Type: "string"},
+		}
+		if header.Value != "" {
+			param.Example = header.Value
+		}
+		operation.Parameters = append(operation.Parameters, param)
+	}
+
+	operation.RequestBody = openAPIRequestBody(item.Request.Body)
+
+	for _, response := range item.Response {
+		code := "default"
+		if response.Code > 0 {
+			code = strconv.Itoa(response.Code)
+		}
+		if _, exists := operation.Responses[code]; exists {
+			continue
+		}
+		operation.Responses[code] = openAPIResponse(response)
+	}
+
+	if len(operation.Responses) == 0 {
+		operation.Responses["default"] = &OpenAPIResponse{Description: openAPIDefaultResponse}
+	}
+
+	return operation
+}
+
+// mergeOperation adds to an operation what another request with the same method and path
+// describes beyond it: tags, parameters, body media types and responses. What both describe
+// keeps the first request's version.
+func (b *openAPIBuilder) mergeOperation(operation, other *OpenAPIOperation) {
+	for _, tag := range other.Tags {
+		if !containsString(operation.Tags, tag) {
+			operation.Tags = append(operation.Tags, tag)
+		}
+	}
+
+	for _, param := range other.Parameters {
+		exists := false
+		for _, existing := range operation.Parameters {
+			if existing.In == param.In && strings.EqualFold(existing.Name, param.Name) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			operation.Parameters = append(operation.Parameters, param)
+		}
+	}
+
+	if other.RequestBody != nil {
+		if operation.RequestBody == nil {
+			operation.RequestBody = &OpenAPIRequestBody{Content: make(map[string]OpenAPIMediaType)}
+		}
+		for contentType, media := range other.RequestBody.Content {
+			if _, exists := operation.RequestBody.Content[contentType]; !exists {
+				operation.RequestBody.Content[contentType] = media
+			}
+		}
+	}
+
+	if onlyDefaultResponse(other.Responses) {
+		return
+	}
+	if onlyDefaultResponse(operation.Responses) {
+		operation.Responses = other.Responses
+		return
+	}
+	for code, response := range other.Responses {
+		if _, exists := operation.Responses[code]; !exists {
+			operation.Responses[code] = response
+		}
+	}
+}
+
+// onlyDefaultResponse reports whether responses only holds the placeholder given to requests
+// without saved responses.
+func onlyDefaultResponse(responses map[string]*OpenAPIResponse) bool {
+	response, ok := responses["default"]
+	return ok && len(responses) == 1 && response.Description == openAPIDefaultResponse
+}
+
+func (b *openAPIBuilder) addTag(tag string) {
+	if !b.tagIndex[tag] {
+		b.tagIndex[tag] = true
+		b.doc.Tags = append(b.doc.Tags, OpenAPITag{Name: tag})
+	}
+}
+
+func (b *openAPIBuilder) warn(format string, args ...interface{}) {
+	b.doc.Warnings = append(b.doc.Warnings, fmt.Sprintf(format, args...))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func openAPIMethod(method string) string {
+	if method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(method)
+}
+
+func (p *OpenAPIPathItem) operation(method string) **OpenAPIOperation {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet:
+		return &p.Get
+	case http.MethodPut:
+		return &p.Put
+	case http.MethodPost:
+		return &p.Post
+	case http.MethodDelete:
+		return &p.Delete
+	case http.MethodOptions:
+		return &p.Options
+	case http.MethodHead:
+		return &p.Head
+	case http.MethodPatch:
+		return &p.Patch
+	case http.MethodTrace:
+		return &p.Trace
+	}
+	return nil
+}
+
+func (b *openAPIBuilder) server(raw string) OpenAPIServer {
+	server := OpenAPIServer{}
+	server.URL = postmanVariablePattern.ReplaceAllStringFunc(raw, func(match string) string {
+		name := strings.TrimSpace(match[2 : len(match)-2])
+		if server.Variables == nil {
+			server.Variables = make(map[string]OpenAPIServerVariable)
+		}
+		server.Variables[name] = OpenAPIServerVariable{Default: b.variables[name]}
+		return "{" + name + "}"
+	})
+	return server
+}
+
+func (b *openAPIBuilder) operationID(name, method, path string) string {
+	base := toOperationID(name)
+	if base == "" {
+		base = toOperationID(strings.ToLower(method) + " " + path)
+	}
+
+	b.operationIDs[base]++
+	if count := b.operationIDs[base]; count > 1 {
+		return fmt.Sprintf("%s%d", base, count)
+	}
+	return base
+}
+
+func toOperationID(s string) string {
+	var sb strings.Builder
+	upperNext := false
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upperNext = sb.Len() > 0
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteRune(unicode.ToLower(r))
+		} else if upperNext {
+			sb.WriteRune(unicode.ToUpper(r))
+		} else {
+			sb.WriteRune(r)
+		}
+		upperNext = false
+	}
+	return sb.String()
+}
+
+func collectionVariables(variables []Variable) map[string]string {
+	values := make(map[string]string)
+	for _, variable := range variables {
+		if variable.Key == "" {
+			continue
+		}
+		if value, ok := variable.Value.(string); ok {
+			values[variable.Key] = value
+		}
+	}
+	return values
+}
+
+func parseOpenAPIRequestURL(u *RequestURL) openAPIRequestURL {
+	raw := ""
+	var structuredQuery []openAPIQueryParam
+	hasStructuredQuery := false
+
+	if u != nil {
+		raw = u.Raw
+		if raw == "" && !u.plain {
+			raw = joinURLParts(u.Host, ".") + "/" + joinURLParts(u.Path, "/")
+		}
+		if u.Query != nil {
+			hasStructuredQuery = true
+			for _, param := range u.Query {
+				if param.Disabled || param.Key == "" {
+					continue
+				}
+				structuredQuery = append(structuredQuery, openAPIQueryParam{key: param.Key, value: param.Value})
+			}
+		}
+	}
+
+	result := openAPIRequestURL{}
+
+	raw = strings.TrimSpace(raw)
+	if i := strings.Index(raw, "#"); i >= 0 {
+		raw = raw[:i]
+	}
+
+	rawQuery := ""
+	if i := strings.Index(raw, "?"); i >= 0 {
+		raw, rawQuery = raw[:i], raw[i+1:]
+	}
+
+	path := raw
+	if i := strings.Index(raw, "://"); i >= 0 {
+		rest := raw[i+3:]
+		hostEnd := strings.Index(rest, "/")
+		if hostEnd < 0 {
+			hostEnd = len(rest)
+		}
+		result.server = raw[:i+3] + rest[:hostEnd]
+		path = rest[hostEnd:]
+	} else if segment, rest, _ := strings.Cut(raw, "/"); isHostSegment(segment) {
+		result.server = segment
+		path = "/" + rest
+	}
+
+	result.path, result.pathParams = normalizeOpenAPIPath(path)
+
+	if hasStructuredQuery {
+		result.queryParams = structuredQuery
+	} else if rawQuery != "" {
+		for _, pair := range strings.Split(rawQuery, "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			if key != "" {
+				result.queryParams = append(result.queryParams, openAPIQueryParam{key: key, value: value})
+			}
+		}
+	}
+
+	return result
+}
+
+func joinURLParts(parts interface{}, sep string) string {
+	switch v := parts.(type) {
+	case string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return strings.Join(values, sep)
+	}
+	return ""
+}
+
+func isHostSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if strings.HasPrefix(segment, "{{") && strings.HasSuffix(segment, "}}") {
+		return true
+	}
+	return segment == "localhost" || strings.Contains(segment, ".") || strings.Contains(segment, ":")
+}
+
+func normalizeOpenAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	normalized := make([]string, 0, len(segments))
+	var params []string
+	seen := make(map[string]bool)
+
+	addParam := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			params = append(params, name)
+		}
+	}
+
+	for _, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+			segment = "{" + segment[1:] + "}"
+		} else {
+			segment = postmanVariablePattern.ReplaceAllString(segment, "{$1}")
+		}
+		for _, match := range openAPIPathParamPattern.FindAllStringSubmatch(segment, -1) {
+			addParam(match[1])
+		}
+		normalized = append(normalized, segment)
+	}
+
+	return "/" + strings.Join(normalized, "/"), params
+}
+
+func openAPIRequestBody(body *Body) *OpenAPIRequestBody {
+	if body == nil || body.Mode != "raw" || strings.TrimSpace(body.Raw) == "" {
+		return nil
+	}
+
+	language := ""
+	if len(body.Options) > 0 {
+		var options struct {
+			Raw struct {
+				Language string `json:"language"`
+			} `json:"raw"`
+		}
+		if err := json.Unmarshal(body.Options, &options); err == nil {
+			language = options.Raw.Language
+		}
+	}
+
+	contentType := "text/plain"
+	switch language {
+	case "xml":
+		contentType = "application/xml"
+	case "html":
+		contentType = "text/html"
+	case "javascript":
+		contentType = "application/javascript"
+	}
+
+	if language == "" || language == "json" {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(body.Raw), &decoded); err == nil {
+			return &OpenAPIRequestBody{
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {Schema: inferOpenAPISchema(decoded), Example: decoded},
+				},
+			}
+		}
+	}
+
+	return &OpenAPIRequestBody{
+		Content: map[string]OpenAPIMediaType{
+			contentType: {Schema: &OpenAPISchema{Type: "string"}, Example: body.Raw},
+		},
+	}
+}
+
+func openAPIResponse(response Response) *OpenAPIResponse {
+	result := &OpenAPIResponse{Description: response.Name}
+	if result.Description == "" {
+		result.Description = response.Status
+	}
+	if result.Description == "" {
+		result.Description = http.StatusText(response.Code)
+	}
+	if result.Description == "" {
+		result.Description = "Response"
+	}
+
+	contentType := ""
+	for _, header := range response.Header {
+		if strings.EqualFold(header.Key, "Content-Type") {
+			contentType = strings.TrimSpace(strings.Split(header.Value, ";")[0])
+			continue
+		}
+		if header.Key == "" {
+			continue
+		}
+		if result.Headers == nil {
+			result.Headers = make(map[string]OpenAPIHeader)
+		}
+		result.Headers[header.Key] = OpenAPIHeader{Schema: &OpenAPISchema{Type: "string"}, Example: header.Value}
+	}
+
+	if strings.TrimSpace(response.Body) == "" {
+		return result
+	}
+
+	var decoded interface{}
+	isJSON := json.Unmarshal([]byte(response.Body), &decoded) == nil
+	if contentType == "" {
+		contentType = "text/plain"
+		if isJSON {
+			contentType = "application/json"
+		}
+	}
+
+	media := OpenAPIMediaType{Schema: &OpenAPISchema{Type: "string"}, Example: response.Body}
+	if isJSON && strings.Contains(contentType, "json") {
+		media = OpenAPIMediaType{Schema: inferOpenAPISchema(decoded), Example: decoded}
+	}
+
+	result.Content = map[string]OpenAPIMediaType{contentType: media}
+	return result
+}
+
+func inferOpenAPISchema(value interface{}) *OpenAPISchema {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema, len(v))}
+		for key, child := range v {
+			schema.Properties[key] = inferOpenAPISchema(child)
+		}
+		return schema
+	case []interface{}:
+		schema := &OpenAPISchema{Type: "array", Items: &OpenAPISchema{}}
+		if len(v) > 0 {
+			schema.Items = inferOpenAPISchema(v[0])
+		}
+		return schema
+	case float64:
+		if v == float64(int64(v)) {
+			return &OpenAPISchema{Type: "integer"}
+		}
+		return &OpenAPISchema{Type: "number"}
+	case bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case string:
+		return &OpenAPISchema{Type: "string"}
+	case nil:
+		return &OpenAPISchema{Nullable: true}
+	}
+	return &OpenAPISchema{}
+}
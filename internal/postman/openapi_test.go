@@ -0,0 +1,343 @@
+package postman
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// convertItems converts a collection holding the given item JSON array.
+func convertItems(t *testing.T, items string) *OpenAPIDocument {
+	t.Helper()
+	collection, err := ParseCollectionJSON([]byte(`{"info": {"name": "Test"}, "item": ` + items + `}`))
+	if err != nil {
+		t.Fatalf("ParseCollectionJSON() error = %v", err)
+	}
+	return ConvertToOpenAPI(collection, "1")
+}
+
+func parameterNames(operation *OpenAPIOperation, in string) []string {
+	names := []string{}
+	for _, param := range operation.Parameters {
+		if param.In == in {
+			names = append(names, param.Name)
+		}
+	}
+	return names
+}
+
+func responseCodes(operation *OpenAPIOperation) []string {
+	codes := []string{}
+	for code := range operation.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func TestConvertToOpenAPIPathParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		wantPath   string
+		wantParams []string
+		wantServer string
+	}{
+		{
+			name:       "colon parameter",
+			url:        `"https://api.example.com/users/:id"`,
+			wantPath:   "/users/{id}",
+			wantParams: []string{"id"},
+			wantServer: "https://api.example.com",
+		},
+		{
+			name:       "variables in host and path",
+			url:        `"{{baseUrl}}/users/{{userId}}/orders/:orderId?expand=items"`,
+			wantPath:   "/users/{userId}/orders/{orderId}",
+			wantParams: []string{"userId", "orderId"},
+			wantServer: "{baseUrl}",
+		},
+		{
+			name:       "structured url",
+			url:        `{"host": ["api", "example", "com"], "path": ["projects", ":projectId", "tasks"]}`,
+			wantPath:   "/projects/{projectId}/tasks",
+			wantParams: []string{"projectId"},
+			wantServer: "api.example.com",
+		},
+		{
+			name:       "repeated parameter",
+			url:        `"/users/{{id}}/friends/{{id}}"`,
+			wantPath:   "/users/{id}/friends/{id}",
+			wantParams: []string{"id"},
+		},
+		{
+			name:       "no parameters",
+			url:        `"https://api.example.com/health"`,
+			wantPath:   "/health",
+			wantParams: []string{},
+			wantServer: "https://api.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := convertItems(t, `[{"name": "Request", "request": {"method": "GET", "url": `+tt.url+`}}]`)
+
+			pathItem, ok := doc.Paths[tt.wantPath]
+			if !ok || pathItem.Get == nil {
+				t.Fatalf("no GET operation at %s, paths = %v", tt.wantPath, doc.Paths)
+			}
+			if got := parameterNames(pathItem.Get, "path"); !reflect.DeepEqual(got, tt.wantParams) {
+				t.Errorf("path parameters = %v, want %v", got, tt.wantParams)
+			}
+			for _, param := range pathItem.Get.Parameters {
+				if param.In == "path" && !param.Required {
+					t.Errorf("path parameter %q is not required", param.Name)
+				}
+			}
+
+			var servers []string
+			for _, server := range doc.Servers {
+				servers = append(servers, server.URL)
+			}
+			if tt.wantServer == "" && len(servers) > 0 || tt.wantServer != "" && !reflect.DeepEqual(servers, []string{tt.wantServer}) {
+				t.Errorf("servers = %v, want %q", servers, tt.wantServer)
+			}
+		})
+	}
+}
+
+func TestConvertToOpenAPIRequestBody(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		wantContentType string
+		wantSchemaType  string
+	}{
+		{
+			name:            "json",
+			body:            `{"mode": "raw", "raw": "{\"name\": \"Ada\", \"age\": 36}", "options": {"raw": {"language": "json"}}}`,
+			wantContentType: "application/json",
+			wantSchemaType:  "object",
+		},
+		{
+			name:            "json without options",
+			body:            `{"mode": "raw", "raw": "[1, 2]"}`,
+			wantContentType: "application/json",
+			wantSchemaType:  "array",
+		},
+		{
+			name:            "xml",
+			body:            `{"mode": "raw", "raw": "<user/>", "options": {"raw": {"language": "xml"}}}`,
+			wantContentType: "application/xml",
+			wantSchemaType:  "string",
+		},
+		{
+			name:            "text that is not json",
+			body:            `{"mode": "raw", "raw": "hello"}`,
+			wantContentType: "text/plain",
+			wantSchemaType:  "string",
+		},
+		{
+			name: "empty raw body",
+			body: `{"mode": "raw", "raw": "  "}`,
+		},
+		{
+			name: "form data",
+			body: `{"mode": "formdata", "formdata": [{"key": "file", "type": "file"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := convertItems(t, `[{"name": "Create", "request": {"method": "POST", "url": "/users", "body": `+tt.body+`}}]`)
+
+			operation := doc.Paths["/users"].Post
+			if operation == nil {
+				t.Fatal("no POST operation at /users")
+			}
+			if tt.wantContentType == "" {
+				if operation.RequestBody != nil {
+					t.Errorf("RequestBody = %+v, want none", operation.RequestBody)
+				}
+				return
+			}
+			if operation.RequestBody == nil {
+				t.Fatal("RequestBody is missing")
+			}
+			media, ok := operation.RequestBody.Content[tt.wantContentType]
+			if !ok || len(operation.RequestBody.Content) != 1 {
+				t.Fatalf("RequestBody content = %v, want only %s", operation.RequestBody.Content, tt.wantContentType)
+			}
+			if media.Schema == nil || media.Schema.Type != tt.wantSchemaType {
+				t.Errorf("schema = %+v, want type %s", media.Schema, tt.wantSchemaType)
+			}
+		})
+	}
+}
+
+func TestConvertToOpenAPIFolderTags(t *testing.T) {
+	doc := convertItems(t, `[
+		{"name": "Users", "item": [
+			{"name": "List users", "request": {"method": "GET", "url": "/users"}},
+			{"name": "Admin", "item": [
+				{"name": "Ban user", "request": {"method": "POST", "url": "/users/:id/ban"}}
+			]}
+		]},
+		{"name": "Orders", "item": [
+			{"name": "List orders", "request": {"method": "GET", "url": "/orders"}}
+		]},
+		{"name": "Health", "request": {"method": "GET", "url": "/health"}}
+	]`)
+
+	tests := []struct {
+		name      string
+		operation *OpenAPIOperation
+		wantTags  []string
+	}{
+		{"request in a folder", doc.Paths["/users"].Get, []string{"Users"}},
+		{"request in a nested folder", doc.Paths["/users/{id}/ban"].Post, []string{"Users"}},
+		{"request in another folder", doc.Paths["/orders"].Get, []string{"Orders"}},
+		{"request outside folders", doc.Paths["/health"].Get, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.operation == nil {
+				t.Fatal("operation is missing")
+			}
+			if !reflect.DeepEqual(tt.operation.Tags, tt.wantTags) {
+				t.Errorf("Tags = %v, want %v", tt.operation.Tags, tt.wantTags)
+			}
+		})
+	}
+
+	want := []OpenAPITag{{Name: "Users"}, {Name: "Orders"}}
+	if !reflect.DeepEqual(doc.Tags, want) {
+		t.Errorf("document tags = %v, want %v", doc.Tags, want)
+	}
+}
+
+func TestConvertToOpenAPIDuplicateOperations(t *testing.T) {
+	tests := []struct {
+		name           string
+		items          string
+		wantSummary    string
+		wantQuery      []string
+		wantHeaders    []string
+		wantResponses  []string
+		wantTags       []string
+		wantBodyTypes  []string
+		wantWarning    string
+		wantOperations int
+	}{
+		{
+			name: "query parameters and responses are merged",
+			items: `[
+				{"name": "List users", "request": {"method": "GET", "url": "/users?page=1"},
+				 "response": [{"name": "OK", "code": 200, "body": "[]"}]},
+				{"name": "Search", "item": [
+					{"name": "Search users", "request": {"method": "GET", "url": "/users?q=ada&page=2",
+					 "header": [{"key": "X-Trace", "value": "1"}]},
+					 "response": [{"name": "OK", "code": 200, "body": "{}"}, {"name": "Bad query", "code": 400}]}
+				]}
+			]`,
+			wantSummary:    "List users",
+			wantQuery:      []string{"page", "q"},
+			wantHeaders:    []string{"X-Trace"},
+			wantResponses:  []string{"200", "400"},
+			wantTags:       []string{"Search"},
+			wantWarning:    `"Search users" was merged into "List users": both are GET /users`,
+			wantOperations: 1,
+		},
+		{
+			name: "saved responses replace the placeholder",
+			items: `[
+				{"name": "Get user", "request": {"method": "GET", "url": "/users/:id"}},
+				{"name": "Get user by id", "request": {"method": "GET", "url": "/users/{{id}}"},
+				 "response": [{"name": "OK", "code": 200}]}
+			]`,
+			wantSummary:    "Get user",
+			wantQuery:      []string{},
+			wantHeaders:    []string{},
+			wantResponses:  []string{"200"},
+			wantWarning:    `"Get user by id" was merged into "Get user": both are GET /users/{id}`,
+			wantOperations: 1,
+		},
+		{
+			name: "body media types are merged",
+			items: `[
+				{"name": "Create user", "request": {"method": "post", "url": "/users",
+				 "body": {"mode": "raw", "raw": "{\"name\": \"Ada\"}"}}},
+				{"name": "Create user from XML", "request": {"method": "POST", "url": "/users",
+				 "body": {"mode": "raw", "raw": "<user/>", "options": {"raw": {"language": "xml"}}}}}
+			]`,
+			wantSummary:    "Create user",
+			wantQuery:      []string{},
+			wantHeaders:    []string{},
+			wantResponses:  []string{"default"},
+			wantBodyTypes:  []string{"application/json", "application/xml"},
+			wantWarning:    `"Create user from XML" was merged into "Create user": both are POST /users`,
+			wantOperations: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := convertItems(t, tt.items)
+
+			if len(doc.Paths) != tt.wantOperations {
+				t.Fatalf("converted %d paths, want %d", len(doc.Paths), tt.wantOperations)
+			}
+			var operation *OpenAPIOperation
+			for _, pathItem := range doc.Paths {
+				operation = pathItem.Get
+				if operation == nil {
+					operation = pathItem.Post
+				}
+			}
+			if operation == nil {
+				t.Fatal("operation is missing")
+			}
+
+			if operation.Summary != tt.wantSummary {
+				t.Errorf("Summary = %q, want %q", operation.Summary, tt.wantSummary)
+			}
+			if got := parameterNames(operation, "query"); !reflect.DeepEqual(got, tt.wantQuery) {
+				t.Errorf("query parameters = %v, want %v", got, tt.wantQuery)
+			}
+			if got := parameterNames(operation, "header"); !reflect.DeepEqual(got, tt.wantHeaders) {
+				t.Errorf("header parameters = %v, want %v", got, tt.wantHeaders)
+			}
+			if got := responseCodes(operation); !reflect.DeepEqual(got, tt.wantResponses) {
+				t.Errorf("responses = %v, want %v", got, tt.wantResponses)
+			}
+			if !reflect.DeepEqual(operation.Tags, tt.wantTags) {
+				t.Errorf("Tags = %v, want %v", operation.Tags, tt.wantTags)
+			}
+			if tt.wantBodyTypes != nil {
+				var types []string
+				if operation.RequestBody != nil {
+					for contentType := range operation.RequestBody.Content {
+						types = append(types, contentType)
+					}
+				}
+				sort.Strings(types)
+				if !reflect.DeepEqual(types, tt.wantBodyTypes) {
+					t.Errorf("body media types = %v, want %v", types, tt.wantBodyTypes)
+				}
+			}
+			if len(doc.Warnings) != 1 || doc.Warnings[0] != tt.wantWarning {
+				t.Errorf("Warnings = %q, want [%q]", doc.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestConvertToOpenAPIUnsupportedMethod(t *testing.T) {
+	doc := convertItems(t, `[{"name": "Copy file", "request": {"method": "COPY", "url": "/files/:id"}}]`)
+
+	if len(doc.Paths) != 0 {
+		t.Errorf("Paths = %v, want none", doc.Paths)
+	}
+	if len(doc.Warnings) != 1 || !strings.Contains(doc.Warnings[0], "method COPY is not supported") {
+		t.Errorf("Warnings = %q, want one about the COPY method", doc.Warnings)
+	}
+}
@@ -1,6 +1,7 @@
 package postman
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -32,6 +33,15 @@ type PostmanCollectionResponse struct {
 	} `json:"collection" validate:"required"`
 }
 
+// APIError is a non-200 response from the Postman API.
+type APIError struct {
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("postman API returned status: %d", e.StatusCode)
+}
+
 type Change struct {
 	Type     string
 	Path     string
@@ -71,7 +81,7 @@ func GetCollections(apiKey string) ([]PostmanCollection, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("postman API returned status: %d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	var result PostmanCollectionsResponse
@@ -83,6 +93,21 @@ func GetCollections(apiKey string) ([]PostmanCollection, error) {
 }
 
 func GetCollection(apiKey, collectionID string) (*PostmanCollectionStructure, error) {
+	document, err := GetCollectionDocument(apiKey, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var collection PostmanCollectionStructure
+	if err := json.Unmarshal(document, &collection); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+	return &collection, nil
+}
+
+// GetCollectionDocument returns a collection as Postman stores it, including the fields
+// PostmanCollectionStructure does not model.
+func GetCollectionDocument(apiKey, collectionID string) (json.RawMessage, error) {
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/collections/%s", PostmanAPIBaseURL, collectionID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
@@ -99,22 +124,53 @@ func GetCollection(apiKey, collectionID string) (*PostmanCollectionStructure, er
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("postman API returned status: %d", resp.StatusCode)
+		return nil, &APIError{StatusCode: resp.StatusCode}
 	}
 
 	var wrapper struct {
-		Collection PostmanCollectionStructure `json:"collection"`
+		Collection json.RawMessage `json:"collection"`
 	}
-	
 	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
 		return nil, fmt.Errorf("error decoding response: %v", err)
 	}
+	if len(wrapper.Collection) == 0 {
+		return nil, fmt.Errorf("error decoding response: no collection in response")
+	}
 
-	return &wrapper.Collection, nil
+	return wrapper.Collection, nil
 }
 
 
 
+func UpdateCollection(apiKey, collectionID string, collection json.RawMessage) error {
+	body, err := json.Marshal(map[string]json.RawMessage{"collection": collection})
+	if err != nil {
+		return fmt.Errorf("error encoding collection: %v", err)
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/collections/%s", PostmanAPIBaseURL, collectionID), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	req.Header.Set("X-Api-Key", apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
+
 func DefaultPostmanOptions() *CompareOptions {
 	return &CompareOptions{
 		MaxDepth:      0, 
@@ -0,0 +1,450 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"integratorV2/internal/db"
+)
+
+var (
+	ErrMaskedValuesPresent  = errors.New("snapshot contains masked values without stored originals, so they cannot be restored")
+	ErrMaskingRecordMissing = errors.New("snapshot was stored without a masking report, so its masked values are unknown")
+	ErrUnmodelledFields     = errors.New("live collection has fields snapshots do not keep, which a restore would delete")
+)
+
+type RestoreChange struct {
+	ChangeType   string  `json:"change_type"`
+	Path         string  `json:"path"`
+	Modification *string `json:"modification,omitempty"`
+}
+
+// RestorePlan describes what restoring a snapshot changes in Postman. MaskedPaths are restored
+// from their stored originals; UnrestorablePaths are masked paths without one. DroppedFields are
+// fields of the live collection that snapshots do not keep, so pushing the snapshot would delete
+// them. Either blocks the restore.
+type RestorePlan struct {
+	CollectionID      string              `json:"collection_id"`
+	SnapshotID        int64               `json:"snapshot_id"`
+	DryRun            bool                `json:"dry_run"`
+	Applied           bool                `json:"applied"`
+	CanApply          bool                `json:"can_apply"`
+	BlockedReason     string              `json:"blocked_reason,omitempty"`
+	MaskedPaths       []string            `json:"masked_paths,omitempty"`
+	UnrestorablePaths []string            `json:"unrestorable_paths,omitempty"`
+	DroppedFields     []string            `json:"dropped_fields,omitempty"`
+	ChangeCount       int                 `json:"change_count"`
+	Changes           []RestoreChange     `json:"changes"`
+	EndpointChanges   []db.EndpointChange `json:"endpoint_changes"`
+}
+
+// MaskedSnapshotPaths returns the paths masked before a snapshot was stored, as recorded in its
+// masking report. Pushing those values to Postman would overwrite real values with their masked
+// form. Snapshots stored before masking reports existed return ErrMaskingRecordMissing.
+func MaskedSnapshotPaths(snapshotID int64) ([]string, error) {
+	report, err := db.GetSnapshotMaskingReport(snapshotID)
+	if errors.Is(err, db.ErrMaskingReportNotFound) {
+		return nil, ErrMaskingRecordMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []MaskedValue
+	if err := json.Unmarshal(report.Entries, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse masking report: %w", err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// MaskedSnapshotOriginals returns the stored originals of the given masked paths of a snapshot,
+// and the paths that have none and so cannot be restored.
+func MaskedSnapshotOriginals(snapshotID int64, paths []string) (map[string]string, []string, error) {
+	if len(paths) == 0 {
+		return map[string]string{}, nil, nil
+	}
+
+	originals, err := StoredOriginals(snapshotID, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var missing []string
+	for _, path := range paths {
+		if _, ok := originals[path]; !ok {
+			missing = append(missing, path)
+		}
+	}
+	return originals, missing, nil
+}
+
+func PlanRestore(apiKey, collectionID string, snapshotID int64, content json.RawMessage, masking *MaskingConfig) (*RestorePlan, error) {
+	document, err := GetCollectionDocument(apiKey, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch live collection: %w", err)
+	}
+
+	var live PostmanCollectionStructure
+	if err := json.Unmarshal(document, &live); err != nil {
+		return nil, fmt.Errorf("failed to parse live collection: %w", err)
+	}
+
+	maskedLive, err := MaskCollectionWithConfig(&live, masking)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mask live collection: %w", err)
+	}
+
+	liveContent, err := json.Marshal(maskedLive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process live collection: %w", err)
+	}
+
+	changes, err := ComparePostmanSnapshots(liveContent, content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare snapshot with live collection: %w", err)
+	}
+
+	endpointChanges, err := CompareEndpoints(liveContent, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare endpoints: %w", err)
+	}
+
+	droppedFields, err := DroppedFields(document)
+	if err != nil {
+		return nil, err
+	}
+
+	maskedPaths, maskingErr := MaskedSnapshotPaths(snapshotID)
+	if maskingErr != nil && !errors.Is(maskingErr, ErrMaskingRecordMissing) {
+		return nil, maskingErr
+	}
+	_, unrestorable, err := MaskedSnapshotOriginals(snapshotID, maskedPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RestorePlan{
+		CollectionID:      collectionID,
+		SnapshotID:        snapshotID,
+		CanApply:          maskingErr == nil && len(unrestorable) == 0 && len(droppedFields) == 0,
+		MaskedPaths:       maskedPaths,
+		UnrestorablePaths: unrestorable,
+		DroppedFields:     droppedFields,
+		ChangeCount:       len(changes),
+		Changes:           make([]RestoreChange, 0, len(changes)),
+		EndpointChanges:   endpointChanges,
+	}
+	switch {
+	case maskingErr != nil:
+		plan.BlockedReason = maskingErr.Error()
+	case len(unrestorable) > 0:
+		plan.BlockedReason = ErrMaskedValuesPresent.Error()
+	case len(droppedFields) > 0:
+		plan.BlockedReason = ErrUnmodelledFields.Error()
+	}
+	for _, change := range changes {
+		plan.Changes = append(plan.Changes, RestoreChange{
+			ChangeType:   change.Type,
+			Path:         change.Path,
+			Modification: change.Modification,
+		})
+	}
+
+	return plan, nil
+}
+
+// ApplyRestore replaces the collection in Postman with the snapshot, with its masked values
+// restored from their stored originals.
+func ApplyRestore(apiKey, collectionID string, snapshotID int64, content json.RawMessage) error {
+	document, err := GetCollectionDocument(apiKey, collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch live collection: %w", err)
+	}
+
+	collectionJSON, err := prepareRestore(document, snapshotID, content)
+	if err != nil {
+		return err
+	}
+
+	if err := UpdateCollection(apiKey, collectionID, collectionJSON); err != nil {
+		return fmt.Errorf("failed to update collection in Postman: %w", err)
+	}
+
+	return nil
+}
+
+// prepareRestore returns the collection to push to Postman in place of the live document. It
+// refuses when the push would delete fields of the live document or leave masked values behind.
+func prepareRestore(live json.RawMessage, snapshotID int64, content json.RawMessage) (json.RawMessage, error) {
+	droppedFields, err := DroppedFields(live)
+	if err != nil {
+		return nil, err
+	}
+	if len(droppedFields) > 0 {
+		return nil, fmt.Errorf("%w: %d field(s), first at %s", ErrUnmodelledFields, len(droppedFields), droppedFields[0])
+	}
+
+	maskedPaths, err := MaskedSnapshotPaths(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	originals, unrestorable, err := MaskedSnapshotOriginals(snapshotID, maskedPaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(unrestorable) > 0 {
+		return nil, fmt.Errorf("%w: %d masked value(s), first at %s", ErrMaskedValuesPresent, len(unrestorable), unrestorable[0])
+	}
+
+	collection, err := ParseCollectionJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	collectionJSON, err := json.Marshal(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode collection: %w", err)
+	}
+
+	return restoreMaskedValues(collectionJSON, originals)
+}
+
+// postmanManagedFields are set by Postman itself, which assigns them again when a collection is
+// replaced, so a restore does not need to carry them.
+var postmanManagedFields = map[string]bool{
+	"id":            true,
+	"uid":           true,
+	"owner":         true,
+	"fork":          true,
+	"createdAt":     true,
+	"updatedAt":     true,
+	"lastUpdatedBy": true,
+}
+
+// DroppedFields returns the paths of the fields of a Postman collection document that
+// PostmanCollectionStructure does not keep, such as descriptions, disabled headers and form data
+// bodies. Fields Postman manages itself and fields holding their default value are left out.
+func DroppedFields(document json.RawMessage) ([]string, error) {
+	var collection PostmanCollectionStructure
+	if err := json.Unmarshal(document, &collection); err != nil {
+		return nil, fmt.Errorf("invalid collection JSON: %v", err)
+	}
+	modelled, err := json.Marshal(collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode collection: %w", err)
+	}
+
+	original, err := decodeJSONTree(document)
+	if err != nil {
+		return nil, err
+	}
+	kept, err := decodeJSONTree(modelled)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped := []string{}
+	collectDroppedFields(original, kept, "", &dropped)
+	sort.Strings(dropped)
+	return dropped, nil
+}
+
+func collectDroppedFields(original, kept interface{}, path string, dropped *[]string) {
+	switch v := original.(type) {
+	case map[string]interface{}:
+		keptFields, ok := kept.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range v {
+			if isDefaultField(key, value) {
+				continue
+			}
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+			keptValue, ok := keptFields[key]
+			if !ok {
+				*dropped = append(*dropped, fieldPath)
+				continue
+			}
+			collectDroppedFields(value, keptValue, fieldPath, dropped)
+		}
+	case []interface{}:
+		keptItems, ok := kept.([]interface{})
+		if !ok {
+			return
+		}
+		for i, value := range v {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= len(keptItems) {
+				*dropped = append(*dropped, itemPath)
+				continue
+			}
+			collectDroppedFields(value, keptItems[i], itemPath, dropped)
+		}
+	}
+}
+
+// isDefaultField reports whether dropping a field loses nothing: Postman manages it, or it holds
+// the value Postman assumes when it is missing. Key-value entries default to the type text.
+func isDefaultField(key string, value interface{}) bool {
+	if strings.HasPrefix(key, "_postman_") || postmanManagedFields[key] {
+		return true
+	}
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case string:
+		return v == "" || key == "type" && v == "text"
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func decodeJSONTree(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return tree, nil
+}
+
+// restoreMaskedValues puts the originals of masked values back into a snapshot's content at
+// their masking paths.
+func restoreMaskedValues(content json.RawMessage, originals map[string]string) (json.RawMessage, error) {
+	if len(originals) == 0 {
+		return content, nil
+	}
+
+	tree, err := decodeJSONTree(content)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(originals))
+	for path := range originals {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		tokens, err := parseMaskingPath(path)
+		if err == nil {
+			tree, err = setMaskedValue(tree, tokens, originals[path])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore masked value at %s: %w", path, err)
+		}
+	}
+
+	restored, err := json.Marshal(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode collection: %w", err)
+	}
+	return restored, nil
+}
+
+// maskingPathToken is a field name or an array index of a masking path.
+type maskingPathToken struct {
+	key   string
+	index int
+}
+
+// parseMaskingPath splits a masking path such as item[0].request.body.raw.user.password into its
+// field names and array indexes.
+func parseMaskingPath(path string) ([]maskingPathToken, error) {
+	var tokens []maskingPathToken
+	for _, part := range strings.Split(path, ".") {
+		name, indexes, _ := strings.Cut(part, "[")
+		if name == "" && indexes == "" {
+			return nil, fmt.Errorf("empty path segment")
+		}
+		if name != "" {
+			tokens = append(tokens, maskingPathToken{key: name, index: -1})
+		}
+		if indexes == "" {
+			continue
+		}
+		for _, index := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index in path segment %q", part)
+			}
+			tokens = append(tokens, maskingPathToken{index: i})
+		}
+	}
+	return tokens, nil
+}
+
+// setMaskedValue sets the value at tokens below node and returns the updated node. A string with
+// tokens left is a raw JSON body, which is decoded, updated and encoded again the way the masker
+// encodes masked bodies. A path ending at a header sets the header's value.
+func setMaskedValue(node interface{}, tokens []maskingPathToken, value string) (interface{}, error) {
+	if len(tokens) == 0 {
+		if header, ok := node.(map[string]interface{}); ok {
+			header["value"] = value
+			return header, nil
+		}
+		return value, nil
+	}
+
+	token, rest := tokens[0], tokens[1:]
+	switch v := node.(type) {
+	case map[string]interface{}:
+		child, ok := v[token.key]
+		if token.index >= 0 || !ok {
+			return nil, fmt.Errorf("no field %q", token.key)
+		}
+		updated, err := setMaskedValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[token.key] = updated
+		return v, nil
+	case []interface{}:
+		if token.index < 0 || token.index >= len(v) {
+			return nil, fmt.Errorf("no element at index %d", token.index)
+		}
+		updated, err := setMaskedValue(v[token.index], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		v[token.index] = updated
+		return v, nil
+	case string:
+		body, err := decodeJSONTree([]byte(v))
+		if err != nil {
+			return nil, fmt.Errorf("body is not JSON: %w", err)
+		}
+		updated, err := setMaskedValue(body, tokens, value)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.MarshalIndent(updated, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	}
+	return nil, fmt.Errorf("cannot descend into %T", node)
+}
@@ -0,0 +1,348 @@
+package postman
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
+)
+
+// liveCollection only uses fields snapshots keep, besides fields Postman manages and defaults.
+const liveCollection = `{
+	"info": {"_postman_id": "c1", "name": "Users", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json", "updatedAt": "2026-01-01T00:00:00.000Z"},
+	"item": [
+		{
+			"id": "i1",
+			"name": "Log in",
+			"request": {
+				"method": "POST",
+				"header": [
+					{"key": "Authorization", "value": "Bearer s3cr3t-t0ken-value", "type": "text"},
+					{"key": "Accept", "value": "application/json", "type": "text"}
+				],
+				"body": {"mode": "raw", "raw": "{\n    \"password\": \"hunter2\",\n    \"username\": \"ada\"\n}", "options": {"raw": {"language": "json"}}},
+				"url": {
+					"raw": "https://api.example.com/login?api_key=abc123",
+					"host": ["api", "example", "com"],
+					"path": ["login"],
+					"query": [{"key": "api_key", "value": "abc123"}]
+				}
+			},
+			"response": [
+				{"id": "r1", "name": "OK", "status": "OK", "code": 200, "_postman_previewlanguage": "json", "header": [], "cookie": [], "body": "{\n    \"id\": 7\n}"}
+			]
+		}
+	],
+	"variable": [{"key": "client_secret", "value": "very-secret-value", "type": "string"}]
+}`
+
+func mockRestoreDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	previous := db.DB
+	db.DB = sqlx.NewDb(conn, "postgres")
+	t.Cleanup(func() {
+		db.DB = previous
+		conn.Close()
+	})
+	return mock
+}
+
+func useTestEncryptor(t *testing.T) {
+	t.Helper()
+	previous := encryption.Active()
+	t.Cleanup(func() { encryption.SetEncryptor(previous) })
+
+	encryptor, err := encryption.NewLocalEncryptor("1", map[string][]byte{"1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("NewLocalEncryptor() error = %v", err)
+	}
+	encryption.SetEncryptor(encryptor)
+}
+
+// maskedSnapshot masks the live collection the way imports do with reversible masking, and
+// returns the snapshot content, the sealed masking key and the masked values.
+func maskedSnapshot(t *testing.T) (json.RawMessage, string, []MaskedValue) {
+	t.Helper()
+	collection, err := ParseCollectionJSON([]byte(liveCollection))
+	if err != nil {
+		t.Fatalf("ParseCollectionJSON() error = %v", err)
+	}
+
+	config := DefaultMaskingConfig()
+	config.MaskHeaders = true
+	config.PreserveMaskedValues = true
+	sealedKey, err := PrepareReversibleMasking(config)
+	if err != nil {
+		t.Fatalf("PrepareReversibleMasking() error = %v", err)
+	}
+	result, err := MaskCollectionWithConfig(collection, config)
+	if err != nil {
+		t.Fatalf("MaskCollectionWithConfig() error = %v", err)
+	}
+	if len(result.MaskedValues) == 0 {
+		t.Fatal("nothing was masked")
+	}
+
+	content, err := json.Marshal(result.Collection)
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+	return content, sealedKey, result.MaskedValues
+}
+
+func expectMaskingReport(t *testing.T, mock sqlmock.Sqlmock, masked []MaskedValue) {
+	t.Helper()
+	entries, err := json.Marshal(masked)
+	if err != nil {
+		t.Fatalf("failed to encode masking report: %v", err)
+	}
+	mock.ExpectQuery(`FROM snapshot_masking_reports`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"snapshot_id", "collection_id", "masking_enabled", "policy_source", "policy_id",
+			"masked_count", "counts_by_type", "entries", "created_at",
+		}).AddRow(5, "c1", true, "default", nil, len(masked), []byte(`{}`), entries, time.Now()))
+}
+
+func expectMaskedOriginals(mock sqlmock.Sqlmock, sealedKey string, masked []MaskedValue) {
+	mock.ExpectQuery(`SELECT encrypted_key FROM snapshot_masking_keys`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"encrypted_key"}).AddRow(sealedKey))
+
+	rows := sqlmock.NewRows([]string{"snapshot_id", "path", "value_type", "encrypted_value"})
+	for _, value := range masked {
+		rows.AddRow(5, value.Path, value.Type, *value.Encrypted)
+	}
+	mock.ExpectQuery(`FROM snapshot_masked_values`).
+		WithArgs(int64(5), sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func decodeTree(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	tree, err := decodeJSONTree(data)
+	if err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+	return tree
+}
+
+func TestPrepareRestoreRoundTrip(t *testing.T) {
+	useTestEncryptor(t)
+	mock := mockRestoreDB(t)
+
+	content, sealedKey, masked := maskedSnapshot(t)
+	expectMaskingReport(t, mock, masked)
+	expectMaskedOriginals(mock, sealedKey, masked)
+
+	restored, err := prepareRestore(json.RawMessage(liveCollection), 5, content)
+	if err != nil {
+		t.Fatalf("prepareRestore() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// Pushing the restored snapshot must leave nothing of the live collection behind but the
+	// fields Postman assigns again.
+	dropped, err := DroppedFields(json.RawMessage(liveCollection))
+	if err != nil {
+		t.Fatalf("DroppedFields() error = %v", err)
+	}
+	if len(dropped) != 0 {
+		t.Fatalf("DroppedFields() = %v, want none", dropped)
+	}
+
+	original, err := ParseCollectionJSON([]byte(liveCollection))
+	if err != nil {
+		t.Fatalf("ParseCollectionJSON() error = %v", err)
+	}
+	want, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("failed to encode collection: %v", err)
+	}
+	if got := decodeTree(t, restored); !reflect.DeepEqual(got, decodeTree(t, want)) {
+		t.Errorf("restored collection = %s\nwant %s", restored, want)
+	}
+}
+
+func TestPrepareRestoreRefusals(t *testing.T) {
+	tests := []struct {
+		name    string
+		live    string
+		expect  func(t *testing.T, mock sqlmock.Sqlmock, masked []MaskedValue)
+		wantErr error
+	}{
+		{
+			name: "live collection with fields snapshots do not keep",
+			live: `{
+				"info": {"name": "Users", "description": "Login endpoints"},
+				"item": [{"name": "Upload", "request": {
+					"method": "POST",
+					"header": [{"key": "X-Debug", "value": "1", "disabled": true}],
+					"body": {"mode": "formdata", "formdata": [{"key": "file", "type": "file", "src": "a.png"}]},
+					"url": "https://api.example.com/upload"
+				}}]
+			}`,
+			expect:  func(t *testing.T, mock sqlmock.Sqlmock, masked []MaskedValue) {},
+			wantErr: ErrUnmodelledFields,
+		},
+		{
+			name: "masked values without stored originals",
+			live: liveCollection,
+			expect: func(t *testing.T, mock sqlmock.Sqlmock, masked []MaskedValue) {
+				expectMaskingReport(t, mock, masked)
+				mock.ExpectQuery(`SELECT encrypted_key FROM snapshot_masking_keys`).
+					WithArgs(int64(5)).
+					WillReturnRows(sqlmock.NewRows([]string{"encrypted_key"}))
+			},
+			wantErr: ErrMaskedValuesPresent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestEncryptor(t)
+			mock := mockRestoreDB(t)
+			content, _, masked := maskedSnapshot(t)
+			tt.expect(t, mock, masked)
+
+			restored, err := prepareRestore(json.RawMessage(tt.live), 5, content)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("prepareRestore() error = %v, want %v", err, tt.wantErr)
+			}
+			if restored != nil {
+				t.Errorf("prepareRestore() returned a collection to push: %s", restored)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestDroppedFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+	}{
+		{
+			name:     "modelled fields only",
+			document: liveCollection,
+			want:     []string{},
+		},
+		{
+			name: "descriptions and disabled headers",
+			document: `{
+				"info": {"name": "Users", "description": "Login endpoints"},
+				"item": [{"name": "Log in", "description": "Starts a session", "request": {
+					"method": "POST",
+					"header": [{"key": "X-Debug", "value": "1", "disabled": true, "type": "text"}],
+					"url": "/login"
+				}}]
+			}`,
+			want: []string{"info.description", "item[0].description", "item[0].request.header[0].disabled"},
+		},
+		{
+			name: "form data and urlencoded bodies",
+			document: `{
+				"info": {"name": "Users"},
+				"item": [
+					{"name": "Upload", "request": {"method": "POST", "url": "/upload",
+					 "body": {"mode": "formdata", "formdata": [{"key": "file", "type": "file"}]}}},
+					{"name": "Token", "request": {"method": "POST", "url": "/token",
+					 "body": {"mode": "urlencoded", "urlencoded": [{"key": "grant_type", "value": "password"}]}}}
+				]
+			}`,
+			want: []string{"item[0].request.body.formdata", "item[1].request.body.urlencoded"},
+		},
+		{
+			name: "defaults and fields Postman manages",
+			document: `{
+				"info": {"_postman_id": "c1", "name": "Users", "uid": "1-c1", "updatedAt": "2026-01-01"},
+				"item": [{"id": "i1", "name": "Log in", "protocolProfileBehavior": {}, "request": {
+					"method": "GET",
+					"header": [{"key": "Accept", "value": "*/*", "type": "text", "disabled": false}],
+					"url": "/login"
+				}}]
+			}`,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DroppedFields(json.RawMessage(tt.document))
+			if err != nil {
+				t.Fatalf("DroppedFields() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DroppedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreMaskedValues(t *testing.T) {
+	content := json.RawMessage(`{"item": [{"request": {
+		"header": [{"key": "Authorization", "value": "Bearer ****"}],
+		"body": {"mode": "raw", "raw": "[\n    {\n        \"token\": \"****\"\n    }\n]"},
+		"url": "https://api.example.com/?api_key=****"
+	}}]}`)
+
+	tests := []struct {
+		name      string
+		originals map[string]string
+		want      string
+		wantErr   string
+	}{
+		{
+			name:      "header",
+			originals: map[string]string{"item[0].request.header[0]": "Bearer abc"},
+			want:      `{"item": [{"request": {"header": [{"key": "Authorization", "value": "Bearer abc"}], "body": {"mode": "raw", "raw": "[\n    {\n        \"token\": \"****\"\n    }\n]"}, "url": "https://api.example.com/?api_key=****"}}]}`,
+		},
+		{
+			name:      "value inside a raw JSON body",
+			originals: map[string]string{"item[0].request.body.raw[0].token": "t0k"},
+			want:      `{"item": [{"request": {"header": [{"key": "Authorization", "value": "Bearer ****"}], "body": {"mode": "raw", "raw": "[\n    {\n        \"token\": \"t0k\"\n    }\n]"}, "url": "https://api.example.com/?api_key=****"}}]}`,
+		},
+		{
+			name:      "plain url",
+			originals: map[string]string{"item[0].request.url": "https://api.example.com/?api_key=abc"},
+			want:      `{"item": [{"request": {"header": [{"key": "Authorization", "value": "Bearer ****"}], "body": {"mode": "raw", "raw": "[\n    {\n        \"token\": \"****\"\n    }\n]"}, "url": "https://api.example.com/?api_key=abc"}}]}`,
+		},
+		{
+			name:      "path that is not in the snapshot",
+			originals: map[string]string{"item[1].request.url": "https://api.example.com/"},
+			wantErr:   "no element at index 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restoreMaskedValues(content, tt.originals)
+			if tt.wantErr != "" {
+				if err == nil || !bytes.Contains([]byte(err.Error()), []byte(tt.wantErr)) {
+					t.Fatalf("restoreMaskedValues() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("restoreMaskedValues() error = %v", err)
+			}
+			if !reflect.DeepEqual(decodeTree(t, got), decodeTree(t, []byte(tt.want))) {
+				t.Errorf("restoreMaskedValues() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
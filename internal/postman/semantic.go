@@ -0,0 +1,306 @@
+package postman
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"integratorV2/internal/db"
+)
+
+const (
+	EventEndpointAdded        = "endpoint_added"
+	EventEndpointRemoved      = "endpoint_removed"
+	EventMethodChanged        = "method_changed"
+	EventQueryParamAdded      = "query_param_added"
+	EventQueryParamRemoved    = "query_param_removed"
+	EventHeaderAdded          = "header_added"
+	EventHeaderRemoved        = "header_removed"
+	EventBodyFieldAdded       = "body_field_added"
+	EventBodyFieldRemoved     = "body_field_removed"
+	EventBodyFieldTypeChanged = "body_field_type_changed"
+	EventResponseCodeAdded    = "response_code_added"
+	EventResponseCodeRemoved  = "response_code_removed"
+)
+
+type semanticEndpoint struct {
+	key           string
+	name          string
+	method        string
+	path          string
+	template      string
+	queryParams   map[string]bool
+	headers       map[string]string
+	bodyFields    map[string]string
+	responseCodes map[int]bool
+}
+
+// CompareEndpoints matches requests by method and normalized URL template instead of their
+// position in the item tree, so reordering or moving requests between folders is not reported.
+func CompareEndpoints(oldContent, newContent json.RawMessage) ([]db.EndpointChange, error) {
+	oldCollection, err := ParseCollectionJSON(oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old snapshot: %w", err)
+	}
+	newCollection, err := ParseCollectionJSON(newContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new snapshot: %w", err)
+	}
+
+	oldEndpoints := indexEndpoints(oldCollection.Item)
+	newEndpoints := indexEndpoints(newCollection.Item)
+
+	changes := []db.EndpointChange{}
+	var removed, added []*semanticEndpoint
+
+	for _, key := range sortedKeys(oldEndpoints) {
+		oldEndpoint := oldEndpoints[key]
+		newEndpoint, ok := newEndpoints[key]
+		if !ok {
+			removed = append(removed, oldEndpoint)
+			continue
+		}
+		changes = append(changes, compareEndpointDetails(oldEndpoint, newEndpoint)...)
+	}
+
+	for _, key := range sortedKeys(newEndpoints) {
+		if _, ok := oldEndpoints[key]; !ok {
+			added = append(added, newEndpoints[key])
+		}
+	}
+
+	for _, pair := range pairMethodChanges(removed, added) {
+		oldEndpoint, newEndpoint := pair[0], pair[1]
+		changes = append(changes, endpointEvent(newEndpoint, EventMethodChanged, "method", &oldEndpoint.method, &newEndpoint.method))
+		changes = append(changes, compareEndpointDetails(oldEndpoint, newEndpoint)...)
+	}
+
+	for _, endpoint := range removed {
+		if endpoint != nil {
+			changes = append(changes, endpointEvent(endpoint, EventEndpointRemoved, "", nil, nil))
+		}
+	}
+	for _, endpoint := range added {
+		if endpoint != nil {
+			changes = append(changes, endpointEvent(endpoint, EventEndpointAdded, "", nil, nil))
+		}
+	}
+
+	return changes, nil
+}
+
+// pairMethodChanges matches removed and added endpoints that share a URL template, preferring
+// requests with the same name, and clears the paired entries from both slices.
+func pairMethodChanges(removed, added []*semanticEndpoint) [][2]*semanticEndpoint {
+	var pairs [][2]*semanticEndpoint
+
+	match := func(sameName bool) {
+		for i, oldEndpoint := range removed {
+			if oldEndpoint == nil {
+				continue
+			}
+			candidates := []int{}
+			for j, newEndpoint := range added {
+				if newEndpoint == nil || newEndpoint.template != oldEndpoint.template || newEndpoint.method == oldEndpoint.method {
+					continue
+				}
+				if sameName && newEndpoint.name != oldEndpoint.name {
+					continue
+				}
+				candidates = append(candidates, j)
+			}
+			if len(candidates) != 1 {
+				continue
+			}
+			j := candidates[0]
+			pairs = append(pairs, [2]*semanticEndpoint{oldEndpoint, added[j]})
+			removed[i], added[j] = nil, nil
+		}
+	}
+
+	match(true)
+	match(false)
+	return pairs
+}
+
+func compareEndpointDetails(oldEndpoint, newEndpoint *semanticEndpoint) []db.EndpointChange {
+	var changes []db.EndpointChange
+
+	for _, key := range sortedKeys(newEndpoint.queryParams) {
+		if !oldEndpoint.queryParams[key] {
+			changes = append(changes, endpointEvent(newEndpoint, EventQueryParamAdded, key, nil, nil))
+		}
+	}
+	for _, key := range sortedKeys(oldEndpoint.queryParams) {
+		if !newEndpoint.queryParams[key] {
+			changes = append(changes, endpointEvent(newEndpoint, EventQueryParamRemoved, key, nil, nil))
+		}
+	}
+
+	for _, key := range sortedKeys(newEndpoint.headers) {
+		if _, ok := oldEndpoint.headers[key]; !ok {
+			changes = append(changes, endpointEvent(newEndpoint, EventHeaderAdded, newEndpoint.headers[key], nil, nil))
+		}
+	}
+	for _, key := range sortedKeys(oldEndpoint.headers) {
+		if _, ok := newEndpoint.headers[key]; !ok {
+			changes = append(changes, endpointEvent(newEndpoint, EventHeaderRemoved, oldEndpoint.headers[key], nil, nil))
+		}
+	}
+
+	for _, field := range sortedKeys(newEndpoint.bodyFields) {
+		newType := newEndpoint.bodyFields[field]
+		oldType, ok := oldEndpoint.bodyFields[field]
+		if !ok {
+			changes = append(changes, endpointEvent(newEndpoint, EventBodyFieldAdded, field, nil, &newType))
+			continue
+		}
+		if oldType != newType {
+			changes = append(changes, endpointEvent(newEndpoint, EventBodyFieldTypeChanged, field, &oldType, &newType))
+		}
+	}
+	for _, field := range sortedKeys(oldEndpoint.bodyFields) {
+		if _, ok := newEndpoint.bodyFields[field]; !ok {
+			oldType := oldEndpoint.bodyFields[field]
+			changes = append(changes, endpointEvent(newEndpoint, EventBodyFieldRemoved, field, &oldType, nil))
+		}
+	}
+
+	for _, code := range sortedCodes(newEndpoint.responseCodes) {
+		if !oldEndpoint.responseCodes[code] {
+			changes = append(changes, endpointEvent(newEndpoint, EventResponseCodeAdded, strconv.Itoa(code), nil, nil))
+		}
+	}
+	for _, code := range sortedCodes(oldEndpoint.responseCodes) {
+		if !newEndpoint.responseCodes[code] {
+			changes = append(changes, endpointEvent(newEndpoint, EventResponseCodeRemoved, strconv.Itoa(code), nil, nil))
+		}
+	}
+
+	return changes
+}
+
+func endpointEvent(endpoint *semanticEndpoint, eventType, field string, oldValue, newValue *string) db.EndpointChange {
+	change := db.EndpointChange{
+		EventType:    eventType,
+		Method:       endpoint.method,
+		Path:         endpoint.path,
+		EndpointName: endpoint.name,
+		OldValue:     oldValue,
+		NewValue:     newValue,
+	}
+	if field != "" {
+		change.Field = &field
+	}
+	return change
+}
+
+func indexEndpoints(items []CollectionItem) map[string]*semanticEndpoint {
+	endpoints := make(map[string]*semanticEndpoint)
+	var walk func(items []CollectionItem)
+	walk = func(items []CollectionItem) {
+		for _, item := range items {
+			if item.Request == nil {
+				walk(item.Item)
+				continue
+			}
+			endpoint := newSemanticEndpoint(item)
+			key := endpoint.key
+			for n := 2; endpoints[key] != nil; n++ {
+				key = fmt.Sprintf("%s#%d", endpoint.key, n)
+			}
+			endpoint.key = key
+			endpoints[key] = endpoint
+		}
+	}
+	walk(items)
+	return endpoints
+}
+
+func newSemanticEndpoint(item CollectionItem) *semanticEndpoint {
+	requestURL := parseOpenAPIRequestURL(item.Request.URL)
+
+	method := strings.ToUpper(item.Request.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	template := openAPIPathParamPattern.ReplaceAllString(requestURL.path, "{}")
+
+	endpoint := &semanticEndpoint{
+		key:           method + " " + template,
+		name:          item.Name,
+		method:        method,
+		path:          requestURL.path,
+		template:      template,
+		queryParams:   make(map[string]bool),
+		headers:       make(map[string]string),
+		bodyFields:    make(map[string]string),
+		responseCodes: make(map[int]bool),
+	}
+
+	for _, query := range requestURL.queryParams {
+		endpoint.queryParams[query.key] = true
+	}
+	for _, header := range item.Request.Header {
+		if header.Key != "" {
+			endpoint.headers[strings.ToLower(header.Key)] = header.Key
+		}
+	}
+
+	if body := item.Request.Body; body != nil && body.Mode == "raw" {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(body.Raw), &decoded); err == nil {
+			flattenBodyFields(decoded, "", endpoint.bodyFields)
+		}
+	}
+
+	for _, response := range item.Response {
+		if response.Code > 0 {
+			endpoint.responseCodes[response.Code] = true
+		}
+	}
+
+	return endpoint
+}
+
+func flattenBodyFields(value interface{}, path string, fields map[string]string) {
+	if path != "" {
+		fields[path] = getJSONType(value)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			flattenBodyFields(child, childPath, fields)
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			flattenBodyFields(v[0], path+"[]", fields)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedCodes(codes map[int]bool) []int {
+	keys := make([]int, 0, len(codes))
+	for code := range codes {
+		keys = append(keys, code)
+	}
+	sort.Ints(keys)
+	return keys
+}
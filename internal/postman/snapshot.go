@@ -13,6 +13,8 @@ import (
 	"errors"
 	"integratorV2/utils"
 
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 var ErrIdenticalSnapshotFound = errors.New("snapshot with identical content already exists")
@@ -22,13 +24,20 @@ type NormalizedSnapshot struct {
 	
 }
 
+type SnapshotResult struct {
+	SnapshotID         int64  `json:"snapshot_id"`
+	PreviousSnapshotID *int64 `json:"previous_snapshot_id,omitempty"`
+	ChangeCount        int    `json:"change_count"`
+	Identical          bool   `json:"identical"`
+}
+
 type SnapshotInfo struct {
 	ID          int64     `json:"id"`
 	ContentHash string    `json:"hash"`
 	CreatedAt   time.Time `json:"created_at"`
 }
 
-func StoreCollectionSnapshot(collectionID string, content json.RawMessage, userID int64,) error {
+func StoreCollectionSnapshot(collectionID string, content json.RawMessage, scope db.Scope,) error {
 	slog.Info("Starting collection snapshot process", "collection_id", collectionID)
 
 	
@@ -38,51 +47,104 @@ func StoreCollectionSnapshot(collectionID string, content json.RawMessage, userI
 		return fmt.Errorf("error parsing collection metadata: %v", err)
 	}
 
-	if err := storeCollectionMetadata(collectionID, collection.Collection.Name, userID); err != nil {
+	if err := storeCollectionMetadata(collectionID, collection.Collection.Name, scope); err != nil {
 		slog.Error("Failed to store collection metadata", "error", err, "collection_id", collectionID)
 		return err
 	}
 
-	snapshotID, err := createSnapshot(collectionID, content)
+	tx, err := db.BeginCollectionTx(collectionID)
 	if err != nil {
-		slog.Error("Failed to create snapshot", "error", err, "collection_id", collectionID)
+		slog.Error("Failed to lock collection", "error", err, "collection_id", collectionID)
 		return err
 	}
+	defer tx.Rollback()
 
+	snapshotID, err := createSnapshot(tx, collectionID, content)
+	if err != nil {
+		slog.Error("Failed to create snapshot", "error", err, "collection_id", collectionID)
+		return err
+	}
 
-	if err := processSnapshotChanges(collectionID, snapshotID); err != nil {
+	if _, _, err := processSnapshotChanges(tx, collectionID, snapshotID); err != nil {
 		slog.Error("Failed to process snapshot changes", "error", err, "collection_id", collectionID)
 		return err
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+	indexNewSnapshot(collectionID, snapshotID, content)
 
 	slog.Info("Successfully completed collection snapshot process", "collection_id", collectionID)
 	return nil
 }
 
-func StoreCollectionSnapshotWithName(collectionID, name string, content json.RawMessage, userID int64) error {
+func StoreCollectionSnapshotWithName(collectionID, name string, content json.RawMessage, scope db.Scope) (*SnapshotResult, error) {
+	return StoreCollectionSnapshotWithProgress(collectionID, name, content, scope, nil, nil)
+}
+
+// StoreCollectionSnapshotWithProgress is StoreCollectionSnapshotWithName, calling progress with
+// db.JobStatusSnapshotting and db.JobStatusDiffing as it reaches each step. A new snapshot is
+// handed to record before it is committed, so whatever record stores commits with it; an error
+// from record discards the snapshot.
+func StoreCollectionSnapshotWithProgress(collectionID, name string, content json.RawMessage, scope db.Scope, progress func(stage string), record func(tx *sqlx.Tx, snapshotID int64) error) (*SnapshotResult, error) {
 	slog.Info("Starting collection snapshot process", "collection_id", collectionID, "name", name)
+	if progress == nil {
+		progress = func(string) {}
+	}
+	progress(db.JobStatusSnapshotting)
 
-	if err := storeCollectionMetadata(collectionID, name, userID); err != nil {
+	if err := storeCollectionMetadata(collectionID, name, scope); err != nil {
 		slog.Error("Failed to store collection metadata", "error", err, "collection_id", collectionID)
-		return err
+		return nil, err
+	}
+
+	// Imports of the same collection take turns from here, so each one checks for an identical
+	// snapshot and diffs against its predecessor only after the previous import has stored both.
+	// The snapshot is committed together with its changes: an import that fails in between leaves
+	// no snapshot behind for the next one to mistake for identical content.
+	tx, err := db.BeginCollectionTx(collectionID)
+	if err != nil {
+		slog.Error("Failed to lock collection", "error", err, "collection_id", collectionID)
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	snapshotID, err := createSnapshot(collectionID, content)
+	snapshotID, err := createSnapshot(tx, collectionID, content)
 	if err != nil {
 		if errors.Is(err, ErrIdenticalSnapshotFound) {
-			return nil
+			return &SnapshotResult{Identical: true}, nil
 		}
 		slog.Error("Failed to create snapshot", "error", err, "collection_id", collectionID)
-		return err
+		return nil, err
 	}
 
-	if err := processSnapshotChanges(collectionID, snapshotID); err != nil {
+	progress(db.JobStatusDiffing)
+	previousSnapshotID, changeCount, err := processSnapshotChanges(tx, collectionID, snapshotID)
+	if err != nil {
 		slog.Error("Failed to process snapshot changes", "error", err, "collection_id", collectionID)
-		return err
+		return nil, err
+	}
+	if record != nil {
+		if err := record(tx, snapshotID); err != nil {
+			slog.Error("Failed to record snapshot details", "error", err, "collection_id", collectionID, "snapshot_id", snapshotID)
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Failed to commit snapshot", "error", err, "collection_id", collectionID)
+		return nil, fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+
+	indexNewSnapshot(collectionID, snapshotID, content)
+
+	result := &SnapshotResult{
+		SnapshotID:         snapshotID,
+		PreviousSnapshotID: previousSnapshotID,
+		ChangeCount:        changeCount,
 	}
 
 	slog.Info("Successfully completed collection snapshot process", "collection_id", collectionID, "name", name)
-	return nil
+	return result, nil
 }
 
 
@@ -95,8 +157,8 @@ func parseCollectionMetadata(content json.RawMessage) (*PostmanCollectionRespons
 }
 
 
-func storeCollectionMetadata(collectionID, name string, userID int64) error {
-	if err := db.StoreCollection(collectionID, name, userID); err != nil {
+func storeCollectionMetadata(collectionID, name string, scope db.Scope) error {
+	if err := db.StoreCollection(collectionID, name, scope); err != nil {
 		return fmt.Errorf("error storing collection metadata: %v", err)
 	}
 	slog.Info("Stored collection metadata", "collection_id", collectionID, "name", name)
@@ -146,15 +208,15 @@ func generateSemanticHash(content json.RawMessage) (string, error) {
 		return "", fmt.Errorf("no collection found in snapshot")
 	}
 
-	
-	normalized := normalizeForHashing(collection)
-	
-	
+	return semanticHash(collection), nil
+}
+
+// semanticHash hashes the canonical JSON of a decoded document with volatile fields removed.
+func semanticHash(document interface{}) string {
+	normalized := normalizeForHashing(document)
 	canonical := createCanonicalJSON(normalized)
-	
-	
 	hash := sha256.Sum256([]byte(canonical))
-	return fmt.Sprintf("%x", hash), nil
+	return fmt.Sprintf("%x", hash)
 }
 
 
@@ -243,7 +305,7 @@ func createCanonicalJSON(obj interface{}) string {
 }
 
 
-func createSnapshot(collectionID string, content json.RawMessage) (int64, error) {
+func createSnapshot(tx *sqlx.Tx, collectionID string, content json.RawMessage) (int64, error) {
 	
 	contentHash, err := generateSemanticHash(content)
 
@@ -266,7 +328,7 @@ func createSnapshot(collectionID string, content json.RawMessage) (int64, error)
 	formatGeneratedSnapshotID := "s-" + generatedSnapshotID
 	
 	var existingID int64
-	err = db.DB.QueryRow(`
+	err = tx.QueryRow(`
 		SELECT id FROM snapshots 
 		WHERE collection_id = $1 AND hash = $2
 		ORDER BY created_at DESC LIMIT 1
@@ -281,10 +343,12 @@ func createSnapshot(collectionID string, content json.RawMessage) (int64, error)
 	}
 
 	
+	// The snapshot is timed when it is written rather than when its transaction began, which may
+	// have been before another import of the collection took the lock and wrote its own.
 	var snapshotID int64
-	err = db.DB.QueryRow(`
-		INSERT INTO snapshots (collection_id, content, hash, snapshot_id)
-		VALUES ($1, $2, $3, $4)
+	err = tx.QueryRow(`
+		INSERT INTO snapshots (collection_id, content, hash, snapshot_id, snapshot_time, created_at)
+		VALUES ($1, $2, $3, $4, clock_timestamp(), clock_timestamp())
 		RETURNING id
 	`, collectionID, content, contentHash, formatGeneratedSnapshotID).Scan(&snapshotID)
 	
@@ -323,43 +387,52 @@ func hasContentChanged(collectionID string, newContent json.RawMessage) (bool, e
 
 
 
-func processSnapshotChanges(collectionID string, newSnapshotID int64) error {
+func processSnapshotChanges(tx *sqlx.Tx, collectionID string, newSnapshotID int64) (*int64, int, error) {
 	
-	hasChanges, err := quickChangeCheck(collectionID, newSnapshotID)
+	hasChanges, err := quickChangeCheck(tx, collectionID, newSnapshotID)
 	if err != nil {
-		return fmt.Errorf("quick change detection check failed: %w", err)
+		return nil, 0, fmt.Errorf("quick change detection check failed: %w", err)
 	}
 
 	if !hasChanges {
 		slog.Info("No content changes detected via hash comparison, skipping detailed analysis",
 			"collection_id", collectionID,
 			"snapshot_id", newSnapshotID)
-		return nil
+		return nil, 0, nil
 	}
 
 	//TODO refactor to get the previous snapshot content as well
-	oldSnapshot, err := getPreviousSnapshot(collectionID, newSnapshotID)
+	oldSnapshot, err := getPreviousSnapshot(tx, collectionID, newSnapshotID)
 	if err != nil {
-		return fmt.Errorf("failed to get previous snapshot: %w", err)
+		return nil, 0, fmt.Errorf("failed to get previous snapshot: %w", err)
 	}
 
 	if oldSnapshot == nil {
 		slog.Info("No previous snapshot found, this is the first snapshot",
 			"collection_id", collectionID,
 			"snapshot_id", newSnapshotID)
-		return nil
+		return nil, 0, nil
 	}
 
-	
-	newContent, err := getSnapshotContent(newSnapshotID)
+
+	changeCount, err := diffSnapshots(tx, collectionID, oldSnapshot, newSnapshotID)
 	if err != nil {
-		return fmt.Errorf("failed to get new snapshot content: %w", err)
+		return nil, 0, err
+	}
+	return &oldSnapshot.ID, changeCount, nil
+}
+
+// diffSnapshots compares a snapshot with its predecessor and stores the changes in tx.
+func diffSnapshots(tx *sqlx.Tx, collectionID string, oldSnapshot *SnapshotInfo, newSnapshotID int64) (int, error) {
+	newContent, err := getSnapshotContent(tx, newSnapshotID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get new snapshot content: %w", err)
 	}
 
 	//TODO refactor to prevent multiple calls to the db to fetch old snapshot content/data
-	oldContent, err := getSnapshotContent(oldSnapshot.ID)
+	oldContent, err := getSnapshotContent(tx, oldSnapshot.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get old snapshot content: %w", err)
+		return 0, fmt.Errorf("failed to get old snapshot content: %w", err)
 	}
 
 	slog.Info("Starting detailed change analysis",
@@ -376,12 +449,22 @@ func processSnapshotChanges(collectionID string, newSnapshotID int64) error {
 			"collection_id", collectionID,
 			"old_snapshot_id", oldSnapshot.ID,
 			"new_snapshot_id", newSnapshotID)
-		return nil
+		return 0, nil
 	}
 
 	
-	if err := storeChanges(collectionID, &oldSnapshot.ID, newSnapshotID, changes); err != nil {
-		return fmt.Errorf("failed to store changes: %w", err)
+	if err := storeChanges(tx, collectionID, &oldSnapshot.ID, newSnapshotID, changes); err != nil {
+		return 0, fmt.Errorf("failed to store changes: %w", err)
+	}
+
+	// Endpoint changes feed webhooks and the change feed, so a snapshot is not committed without
+	// them; the import fails and its retry diffs again.
+	endpointChanges, err := CompareEndpoints(oldContent, newContent)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute endpoint changes: %w", err)
+	}
+	if err := db.StoreEndpointChanges(tx, collectionID, &oldSnapshot.ID, newSnapshotID, endpointChanges); err != nil {
+		return 0, fmt.Errorf("failed to store endpoint changes: %w", err)
 	}
 
 	slog.Info("Successfully processed snapshot changes",
@@ -390,15 +473,15 @@ func processSnapshotChanges(collectionID string, newSnapshotID int64) error {
 		"new_snapshot_id", newSnapshotID,
 		"change_count", len(changes))
 
-	return nil
+	return len(changes), nil
 }
 
 
-func quickChangeCheck(collectionID string, currentSnapshotID int64) (bool, error) {
+func quickChangeCheck(tx *sqlx.Tx, collectionID string, currentSnapshotID int64) (bool, error) {
 	var currentHash, previousHash string
 
 	
-	err := db.DB.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT hash FROM snapshots WHERE id = $1
 	`, currentSnapshotID).Scan(&currentHash)
 	if err != nil {
@@ -406,7 +489,7 @@ func quickChangeCheck(collectionID string, currentSnapshotID int64) (bool, error
 	}
 
 	
-	err = db.DB.QueryRow(`
+	err = tx.QueryRow(`
 		SELECT hash FROM snapshots
 		WHERE collection_id = $1 AND id != $2
 		ORDER BY created_at DESC
@@ -432,10 +515,10 @@ func quickChangeCheck(collectionID string, currentSnapshotID int64) (bool, error
 }
 
 
-func getPreviousSnapshot(collectionID string, currentSnapshotID int64) (*SnapshotInfo, error) {
+func getPreviousSnapshot(tx *sqlx.Tx, collectionID string, currentSnapshotID int64) (*SnapshotInfo, error) {
 	var snapshot SnapshotInfo
 
-	err := db.DB.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT id, hash, created_at 
 		FROM snapshots
 		WHERE collection_id = $1 AND id != $2
@@ -454,9 +537,9 @@ func getPreviousSnapshot(collectionID string, currentSnapshotID int64) (*Snapsho
 }
 
 
-func getSnapshotContent(snapshotID int64) (json.RawMessage, error) {
+func getSnapshotContent(tx *sqlx.Tx, snapshotID int64) (json.RawMessage, error) {
 	var content json.RawMessage
-	err := db.DB.QueryRow(`
+	err := tx.QueryRow(`
 		SELECT content FROM snapshots WHERE id = $1
 	`, snapshotID).Scan(&content)
 	if err != nil {
@@ -466,49 +549,34 @@ func getSnapshotContent(snapshotID int64) (json.RawMessage, error) {
 }
 
 
-func storeChanges(collectionID string, oldSnapshotID *int64, newSnapshotID int64, changes []Change) error {
+// storeChanges copies the changes of a snapshot in tx. COPY keeps large change sets to a single
+// round trip.
+func storeChanges(tx *sqlx.Tx, collectionID string, oldSnapshotID *int64, newSnapshotID int64, changes []Change) error {
 	if len(changes) == 0 {
 		slog.Debug("No changes to store", "collection_id", collectionID)
 		return nil
 	}
 
-	
-	tx, err := db.DB.Begin()
+	stmt, err := tx.Prepare(pq.CopyIn("changes",
+		"collection_id", "old_snapshot_id", "new_snapshot_id",
+		"change_type", "path", "modification",
+	))
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
-	
-	stmt, err := tx.Prepare(`
-		INSERT INTO changes (
-			collection_id, old_snapshot_id, new_snapshot_id,
-			change_type, path, modification, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, NOW())
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to prepare insert statement: %w", err)
+		return fmt.Errorf("failed to prepare copy statement: %w", err)
 	}
 	defer stmt.Close()
 
-	
 	for i, change := range changes {
 		_, err := stmt.Exec(
 			collectionID, oldSnapshotID, newSnapshotID,
 			change.Type, change.Path, change.Modification,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to insert change %d: %w", i, err)
+			return fmt.Errorf("failed to copy change %d: %w", i, err)
 		}
 	}
-
-	
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit changes transaction: %w", err)
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("failed to copy changes: %w", err)
 	}
 
 	slog.Info("Successfully stored changes",
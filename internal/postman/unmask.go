@@ -0,0 +1,100 @@
+package postman
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
+)
+
+// PrepareReversibleMasking gives config a fresh masking key when its policy keeps masked
+// originals, and returns that key sealed by the configured encryption provider. Each snapshot
+// gets its own key so one provider call protects all of its values.
+func PrepareReversibleMasking(config *MaskingConfig) (string, error) {
+	if !config.PreserveMaskedValues {
+		return "", nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate masking key: %w", err)
+	}
+	maskingKey := base64.StdEncoding.EncodeToString(key)
+
+	sealedKey, err := encryption.EncryptString(maskingKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt masking key: %w", err)
+	}
+
+	config.MaskingKey = maskingKey
+	return sealedKey, nil
+}
+
+// StoreMaskedOriginals stores the encrypted originals of the masked values of a snapshot being
+// stored in tx.
+func StoreMaskedOriginals(tx *sqlx.Tx, snapshotID int64, sealedKey string, result *MaskingResult) error {
+	values := make([]db.SnapshotMaskedValue, 0, len(result.MaskedValues))
+	for _, value := range result.MaskedValues {
+		if value.Encrypted == nil {
+			continue
+		}
+		values = append(values, db.SnapshotMaskedValue{
+			SnapshotID:     snapshotID,
+			Path:           value.Path,
+			ValueType:      value.Type,
+			EncryptedValue: *value.Encrypted,
+		})
+	}
+	return db.SaveSnapshotMaskedValues(tx, snapshotID, sealedKey, values)
+}
+
+// UnmaskValues decrypts stored originals with the snapshot's sealed masking key and returns them
+// by path.
+func UnmaskValues(sealedKey string, values []db.SnapshotMaskedValue) (map[string]string, error) {
+	maskingKey, err := encryption.DecryptString(sealedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt masking key: %w", err)
+	}
+
+	config := DefaultMaskingConfig()
+	config.PreserveMaskedValues = true
+	config.MaskingKey = maskingKey
+	masker, err := NewCollectionMasker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	originals := make(map[string]string, len(values))
+	for _, value := range values {
+		original, err := masker.DecryptValue(value.EncryptedValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt masked value at %s: %w", value.Path, err)
+		}
+		originals[value.Path] = original
+	}
+	return originals, nil
+}
+
+// StoredOriginals decrypts the stored originals of the given masked paths of a snapshot and
+// returns them by path. Paths without a stored original are left out, as are all paths of a
+// snapshot stored without reversible masking.
+func StoredOriginals(snapshotID int64, paths []string) (map[string]string, error) {
+	sealedKey, err := db.GetSnapshotMaskingKey(snapshotID)
+	if errors.Is(err, db.ErrMaskingKeyNotFound) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := db.GetSnapshotMaskedValues(snapshotID, paths)
+	if err != nil {
+		return nil, err
+	}
+	return UnmaskValues(sealedKey, values)
+}
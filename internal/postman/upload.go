@@ -0,0 +1,56 @@
+package postman
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrUnsupportedCollectionSchema = errors.New("unsupported collection schema, expected a Postman v2.0 or v2.1 collection export")
+
+var supportedCollectionSchemas = []string{
+	"/json/collection/v2.0.0/",
+	"/json/collection/v2.1.0/",
+}
+
+func ParseCollectionExport(data []byte) (*PostmanCollectionStructure, error) {
+	collection, err := ParseCollectionJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isSupportedCollectionSchema(collection.Info.Schema) {
+		return nil, ErrUnsupportedCollectionSchema
+	}
+
+	if err := validateCollectionItems(collection.Item, "item"); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func isSupportedCollectionSchema(schema string) bool {
+	for _, supported := range supportedCollectionSchemas {
+		if strings.Contains(schema, supported) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateCollectionItems(items []CollectionItem, path string) error {
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if item.Request == nil && item.Item == nil {
+			return fmt.Errorf("%s must contain either a request or an item array", itemPath)
+		}
+		if item.Request != nil && item.Request.URL == nil {
+			return fmt.Errorf("%s.request.url is required", itemPath)
+		}
+		if err := validateCollectionItems(item.Item, itemPath+".item"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	TaskBulkImportCheck = "bulk_import_check"
+)
+
+type BulkImportCheckPayload struct {
+	ParentJobID int64 `json:"parent_job_id"`
+}
+
+// EnqueueBulkImportCheck asks the worker to finish a bulk import whose last child was finished
+// outside the worker, such as by a cancellation or a failed enqueue.
+func EnqueueBulkImportCheck(parentJobID int64) error {
+	payloadBytes, err := json.Marshal(BulkImportCheckPayload{ParentJobID: parentJobID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	task := asynq.NewTask(TaskBulkImportCheck, payloadBytes)
+
+	_, err = client.Enqueue(task,
+		asynq.Queue(QueueCollectionImport),
+		asynq.MaxRetry(3),
+		asynq.Timeout(time.Minute),
+		asynq.Retention(time.Hour),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+)
+
+const (
+	TaskEnvironmentImport = "environment_import"
+)
+
+type EnvironmentImportPayload struct {
+	UserID         int64  `json:"user_id"`
+	OrganizationID *int64 `json:"organization_id,omitempty"`
+	EnvironmentID  string `json:"environment_id"`
+	Name           string `json:"name"`
+}
+
+func (p EnvironmentImportPayload) Scope() db.Scope {
+	return db.Scope{UserID: p.UserID, OrganizationID: p.OrganizationID}
+}
+
+func EnqueueEnvironmentImport(payload EnvironmentImportPayload) (string, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	task := asynq.NewTask(TaskEnvironmentImport, payloadBytes)
+
+	info, err := client.Enqueue(task, CollectionImportOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue task: %v", err)
+	}
+
+	return info.ID, nil
+}
@@ -0,0 +1,176 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// ErrTaskNotArchived is returned when requeueing a task that is missing or not archived.
+var ErrTaskNotArchived = errors.New("task is not archived")
+
+// DeadLetterQueues are the queues whose archived tasks can be inspected, requeued and deleted.
+var DeadLetterQueues = []string{QueueCollectionImport, QueueWebhookDelivery, QueueKMSRotation}
+
+// DeadLetterTask is a task that exhausted its retries or failed with asynq.SkipRetry. The job,
+// collection and user are read from the payload of import, upload and restore tasks.
+type DeadLetterTask struct {
+	ID           string    `json:"id"`
+	Queue        string    `json:"queue"`
+	Type         string    `json:"type"`
+	JobID        int64     `json:"job_id,omitempty"`
+	UserID       int64     `json:"user_id,omitempty"`
+	CollectionID string    `json:"collection_id,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Retried      int       `json:"retried"`
+	MaxRetry     int       `json:"max_retry"`
+	LastError    string    `json:"last_error"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+}
+
+func IsDeadLetterQueue(queueName string) bool {
+	for _, q := range DeadLetterQueues {
+		if q == queueName {
+			return true
+		}
+	}
+	return false
+}
+
+// JobQueue returns the queue the tasks of a collection job type are enqueued on. Imports,
+// uploads, restores and bulk import checks all share the collection import queue; callers look
+// tasks up through here so a job type given its own queue is still found.
+func JobQueue(jobType string) string {
+	return QueueCollectionImport
+}
+
+// CancelTask stops a collection task: a running task is told to stop and a waiting one is
+// deleted. Tasks that already finished or no longer exist are left alone.
+func CancelTask(queueName, taskID string) error {
+	info, err := inspector.GetTaskInfo(queueName, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get task info: %v", err)
+	}
+
+	switch info.State {
+	case asynq.TaskStateActive:
+		if err := inspector.CancelProcessing(taskID); err != nil {
+			return fmt.Errorf("failed to cancel task: %v", err)
+		}
+	case asynq.TaskStatePending, asynq.TaskStateScheduled, asynq.TaskStateRetry, asynq.TaskStateAggregating:
+		if err := inspector.DeleteTask(queueName, taskID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+			return fmt.Errorf("failed to delete task: %v", err)
+		}
+	}
+	return nil
+}
+
+// RequeueTask moves an archived task back to pending.
+func RequeueTask(queueName, taskID string) error {
+	info, err := inspector.GetTaskInfo(queueName, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return ErrTaskNotArchived
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get task info: %v", err)
+	}
+	if info.State != asynq.TaskStateArchived {
+		return ErrTaskNotArchived
+	}
+
+	if err := inspector.RunTask(queueName, taskID); err != nil {
+		return fmt.Errorf("failed to requeue task: %v", err)
+	}
+	return nil
+}
+
+func ListDeadLetterTasks(queueName string, page, pageSize int) ([]DeadLetterTask, int, error) {
+	tasks := []DeadLetterTask{}
+
+	queueInfo, err := inspector.GetQueueInfo(queueName)
+	if errors.Is(err, asynq.ErrQueueNotFound) {
+		return tasks, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get queue info: %v", err)
+	}
+
+	infos, err := inspector.ListArchivedTasks(queueName, asynq.Page(page), asynq.PageSize(pageSize))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list archived tasks: %v", err)
+	}
+
+	for _, info := range infos {
+		task := DeadLetterTask{
+			ID:           info.ID,
+			Queue:        info.Queue,
+			Type:         info.Type,
+			Retried:      info.Retried,
+			MaxRetry:     info.MaxRetry,
+			LastError:    info.LastErr,
+			LastFailedAt: info.LastFailedAt,
+		}
+
+		var payload struct {
+			JobID        int64  `json:"job_id"`
+			UserID       int64  `json:"user_id"`
+			CollectionID string `json:"collection_id"`
+			Name         string `json:"name"`
+		}
+		if json.Unmarshal(info.Payload, &payload) == nil {
+			task.JobID = payload.JobID
+			task.UserID = payload.UserID
+			task.CollectionID = payload.CollectionID
+			task.Name = payload.Name
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, queueInfo.Archived, nil
+}
+
+// DeadLetterTaskIDs lists the IDs of every archived task in a queue.
+func DeadLetterTaskIDs(queueName string) ([]string, error) {
+	const pageSize = 100
+
+	var ids []string
+	for page := 1; ; page++ {
+		infos, err := inspector.ListArchivedTasks(queueName, asynq.Page(page), asynq.PageSize(pageSize))
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list archived tasks: %v", err)
+		}
+		for _, info := range infos {
+			ids = append(ids, info.ID)
+		}
+		if len(infos) < pageSize {
+			return ids, nil
+		}
+	}
+}
+
+func DeleteDeadLetterTask(queueName, taskID string) error {
+	info, err := inspector.GetTaskInfo(queueName, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return ErrTaskNotArchived
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get task info: %v", err)
+	}
+	if info.State != asynq.TaskStateArchived {
+		return ErrTaskNotArchived
+	}
+
+	if err := inspector.DeleteTask(queueName, taskID); err != nil {
+		return fmt.Errorf("failed to delete task: %v", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,6 +11,8 @@ import (
 
 const (
 	QueueKMSRotation = "kms_rotation"
+
+	KMSRotationInterval = 3 * 30 * 24 * time.Hour
 )
 
 type KMSRotationPayload struct {
@@ -17,26 +20,39 @@ type KMSRotationPayload struct {
 }
 
 
-func ScheduleKMSRotation(keyID string) error {
-	payload := KMSRotationPayload{
-		KeyID: keyID,
+// ScheduleKMSRotation schedules the rotation of keyID at the given time. Every replica schedules
+// the rotation on startup, so the task ID keeps a single task per key.
+func ScheduleKMSRotation(keyID string, at time.Time) error {
+	task, err := newKMSRotationTask(keyID)
+	if err != nil {
+		return err
 	}
 
-	
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %v", err)
+	_, err = client.Enqueue(task,
+		asynq.Queue(QueueKMSRotation),
+		asynq.TaskID(QueueKMSRotation+":"+keyID),
+		asynq.ProcessAt(at),
+		asynq.MaxRetry(3),
+		asynq.Timeout(30*time.Minute),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("failed to enqueue KMS rotation task: %v", err)
 	}
 
-	
-	task := asynq.NewTask(QueueKMSRotation, payloadBytes)
+	return nil
+}
+
+// EnqueueKMSRotation rotates keyID right away, or resumes a rotation that did not finish.
+func EnqueueKMSRotation(keyID string) error {
+	task, err := newKMSRotationTask(keyID)
+	if err != nil {
+		return err
+	}
 
-	// Schedule task for 3 months from now
 	_, err = client.Enqueue(task,
 		asynq.Queue(QueueKMSRotation),
-		asynq.ProcessIn(3*30*24*time.Hour),
 		asynq.MaxRetry(3),
-		asynq.Timeout(10*time.Minute),
+		asynq.Timeout(30*time.Minute),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to enqueue KMS rotation task: %v", err)
@@ -44,3 +60,11 @@ func ScheduleKMSRotation(keyID string) error {
 
 	return nil
 }
+
+func newKMSRotationTask(keyID string) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(KMSRotationPayload{KeyID: keyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	return asynq.NewTask(QueueKMSRotation, payloadBytes), nil
+}
@@ -8,16 +8,38 @@ import (
 	"time"
 
 	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
 )
 
 const (
 	QueueCollectionImport = "collection_import"
+
+	TaskCollectionUpload = "collection_upload"
 )
 
+// CollectionImportPayload describes an import. JobID is the collection_jobs row tracking it;
+// scheduled imports have none and get one when they run. ParentJobID is set for the imports of
+// a bulk import.
 type CollectionImportPayload struct {
-	UserID       int64  `json:"user_id"`
-	CollectionID string `json:"collection_id"`
-	Name         string `json:"name"`
+	JobID          int64  `json:"job_id,omitempty"`
+	ParentJobID    int64  `json:"parent_job_id,omitempty"`
+	UserID         int64  `json:"user_id"`
+	OrganizationID *int64 `json:"organization_id,omitempty"`
+	CollectionID   string `json:"collection_id"`
+	Name           string `json:"name"`
+	Scheduled      bool   `json:"scheduled,omitempty"`
+}
+
+func (p CollectionImportPayload) Scope() db.Scope {
+	return db.Scope{UserID: p.UserID, OrganizationID: p.OrganizationID}
+}
+
+// CollectionUploadPayload describes the import of an uploaded export. The export is not part of
+// the payload: it is stored sealed for the job with db.SaveCollectionUpload, keeping it out of
+// Redis and the dead-letter view.
+type CollectionUploadPayload struct {
+	CollectionImportPayload
 }
 
 var (
@@ -54,19 +76,46 @@ func InitQueue() error {
 
 func EnqueueCollectionImport(payload CollectionImportPayload) (string, error) {
 
+	task, err := NewCollectionImportTask(payload)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := client.Enqueue(task, CollectionImportOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue task: %v", err)
+	}
+
+	return info.ID, nil
+}
+
+func NewCollectionImportTask(payload CollectionImportPayload) (*asynq.Task, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal payload: %v", err)
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
 	}
 
-	task := asynq.NewTask(QueueCollectionImport, payloadBytes)
+	return asynq.NewTask(QueueCollectionImport, payloadBytes), nil
+}
 
-	info, err := client.Enqueue(task,
+func CollectionImportOptions() []asynq.Option {
+	return []asynq.Option{
 		asynq.Queue(QueueCollectionImport),
 		asynq.MaxRetry(3),
-		asynq.Timeout(10*time.Minute),
-		asynq.Retention(24*time.Hour),
-	)
+		asynq.Timeout(10 * time.Minute),
+		asynq.Retention(24 * time.Hour),
+	}
+}
+
+func EnqueueCollectionUpload(payload CollectionUploadPayload) (string, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	task := asynq.NewTask(TaskCollectionUpload, payloadBytes)
+
+	info, err := client.Enqueue(task, CollectionImportOptions()...)
 	if err != nil {
 		return "", fmt.Errorf("failed to enqueue task: %v", err)
 	}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+)
+
+const (
+	TaskCollectionRestore = "collection_restore"
+)
+
+type CollectionRestorePayload struct {
+	JobID          int64  `json:"job_id"`
+	UserID         int64  `json:"user_id"`
+	OrganizationID *int64 `json:"organization_id,omitempty"`
+	CollectionID   string `json:"collection_id"`
+	Name           string `json:"name"`
+	SnapshotID     int64  `json:"snapshot_id"`
+	DryRun         bool   `json:"dry_run"`
+}
+
+func (p CollectionRestorePayload) Scope() db.Scope {
+	return db.Scope{UserID: p.UserID, OrganizationID: p.OrganizationID}
+}
+
+func EnqueueCollectionRestore(payload CollectionRestorePayload) (string, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	task := asynq.NewTask(TaskCollectionRestore, payloadBytes)
+
+	info, err := client.Enqueue(task,
+		asynq.Queue(QueueCollectionImport),
+		asynq.MaxRetry(3),
+		asynq.Timeout(10*time.Minute),
+		asynq.Retention(24*time.Hour),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue task: %v", err)
+	}
+
+	return info.ID, nil
+}
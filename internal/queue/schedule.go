@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+)
+
+const MinScheduleIntervalMinutes = 5
+
+// ValidateCronExpression parses a standard cron expression and rejects schedules that can run
+// twice within MinScheduleIntervalMinutes. Runs over a full year are compared, which covers every
+// combination of minute, hour, day and month fields.
+func ValidateCronExpression(expression string) error {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression: %v", err)
+	}
+
+	minGap := MinScheduleIntervalMinutes * time.Minute
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 1)
+	for run := schedule.Next(start); !run.IsZero() && run.Before(end); {
+		next := schedule.Next(run)
+		if !next.IsZero() && next.Sub(run) < minGap {
+			return fmt.Errorf("cron expression must not run more often than every %d minutes", MinScheduleIntervalMinutes)
+		}
+		run = next
+	}
+	return nil
+}
+
+// NextScheduledRun returns when a schedule runs next, counting from its last run so interval
+// schedules keep their rhythm. A run missed while no scheduler was up is reported as due now.
+func NextScheduledRun(cronspec string, lastRunAt *time.Time, now time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(cronspec)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule: %v", err)
+	}
+	if lastRunAt != nil {
+		if next := schedule.Next(*lastRunAt); next.After(now) {
+			return next, nil
+		}
+	}
+	return schedule.Next(now), nil
+}
+
+func ScheduledCollectionImportConfig(cronspec string, payload CollectionImportPayload) (*asynq.PeriodicTaskConfig, error) {
+	payload.Scheduled = true
+
+	task, err := NewCollectionImportTask(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every replica runs a scheduler, so the uniqueness lock keeps a single import per tick.
+	opts := append(CollectionImportOptions(), asynq.Unique(time.Minute))
+
+	return &asynq.PeriodicTaskConfig{
+		Cronspec: cronspec,
+		Task:     task,
+		Opts:     opts,
+	}, nil
+}
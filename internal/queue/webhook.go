@@ -0,0 +1,40 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	QueueWebhookDelivery = "webhook_delivery"
+
+	WebhookDeliveryMaxRetry = 8
+)
+
+type WebhookDeliveryPayload struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+func EnqueueWebhookDelivery(deliveryID int64) error {
+	payloadBytes, err := json.Marshal(WebhookDeliveryPayload{DeliveryID: deliveryID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	task := asynq.NewTask(QueueWebhookDelivery, payloadBytes)
+
+	_, err = client.Enqueue(task,
+		asynq.Queue(QueueWebhookDelivery),
+		asynq.MaxRetry(WebhookDeliveryMaxRetry),
+		asynq.Timeout(30*time.Second),
+		asynq.Retention(24*time.Hour),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook delivery: %v", err)
+	}
+
+	return nil
+}
@@ -16,10 +16,17 @@ func SetupRoutes(api *echo.Group) {
 	authGroup.Use(security.ValidateEmail)
 	authGroup.POST("/signup", handlers.Signup)
 	authGroup.POST("/login", handlers.Login)
+	authGroup.POST("/refresh", handlers.RefreshToken)
+	authGroup.POST("/logout", handlers.Logout)
 
 
 	api.Use(auth.JWTMiddleware)
 
+	api.POST("/auth/logout-all", handlers.LogoutAll)
+	api.POST("/auth/tokens", handlers.CreateAccessToken)
+	api.GET("/auth/tokens", handlers.GetAccessTokens)
+	api.DELETE("/auth/tokens/:id", handlers.RevokeAccessToken)
+
 	keys := api.Group("/keys")
 	keys.POST("/api-key", handlers.StoreAPIKey)
 	keys.GET("/api-keys", handlers.GetAPIKeys)
@@ -27,54 +34,120 @@ func SetupRoutes(api *echo.Group) {
 
 
 	collections := api.Group("/collections")
+	collections.Use(auth.CollectionOwnership)
 	collections.POST("/api-key/rotate", handlers.RotateAPIKey)
-	collections.GET("", handlers.GetCollections)
-	collections.GET("/user", handlers.GetUserCollections)
+	auth.TokenScope(collections.GET("", handlers.GetCollections), auth.ScopeCollectionsRead)
+	auth.TokenScope(collections.GET("/user", handlers.GetUserCollections), auth.ScopeCollectionsRead)
 	
-	collections.POST("/save-collection", handlers.SaveCollection)
+	auth.TokenScope(collections.POST("/save-collection", handlers.SaveCollection), auth.ScopeCollectionsWrite)
+	auth.TokenScope(collections.POST("/import-all", handlers.ImportAllCollections), auth.ScopeCollectionsWrite)
+	auth.TokenScope(collections.POST("/upload", handlers.UploadCollection), auth.ScopeCollectionsWrite)
 
-	collections.GET("/:id/snapshots", handlers.GetCollectionSnapshots)
-	collections.GET("/:id/snapshots/:snapshotId", handlers.GetSnapshotDetail)
-	collections.GET("/:id/snapshots/:snapshotId/items", handlers.GetSnapshotItems)
-	collections.DELETE("/snapshot/:id", handlers.DeleteSnapshot)
+	auth.TokenScope(collections.GET("/:id/snapshots", handlers.GetCollectionSnapshots), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:id/snapshots/:snapshotId", handlers.GetSnapshotDetail), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:id/snapshots/:snapshotId/items", handlers.GetSnapshotItems), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:id/snapshots/:snapshotId/openapi", handlers.GetSnapshotOpenAPI), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:id/snapshots/:snapshotId/masking-report", handlers.GetSnapshotMaskingReport), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.POST("/:id/snapshots/:snapshotId/restore", handlers.RestoreSnapshot), auth.ScopeSnapshotsWrite)
+	collections.POST("/:id/snapshots/:snapshotId/unmask", handlers.UnmaskSnapshotValues)
+	auth.TokenScope(collections.DELETE("/snapshot/:snapshotId", handlers.DeleteSnapshot), auth.ScopeSnapshotsWrite)
 	
-	collections.DELETE("snapshot/changes/:id", handlers.DeleteSnapshotChanges)
+	auth.TokenScope(collections.DELETE("/snapshot/changes/:snapshotId", handlers.DeleteSnapshotChanges), auth.ScopeSnapshotsWrite)
+
+	auth.TokenScope(collections.GET("/:id/changes", handlers.GetCollectionChanges), auth.ScopeSnapshotsRead)
 
-	collections.GET("/:id/changes", handlers.GetCollectionChanges)
-	collections.GET("/snapshot/compare/:collectionId", handlers.CompareSnapShots)
+	auth.TokenScope(collections.GET("/schedules", handlers.GetCollectionSchedules), auth.ScopeCollectionsRead)
+	auth.TokenScope(collections.POST("/:id/schedule", handlers.CreateCollectionSchedule), auth.ScopeCollectionsWrite)
+	auth.TokenScope(collections.GET("/:id/schedule", handlers.GetCollectionSchedule), auth.ScopeCollectionsRead)
+	auth.TokenScope(collections.PUT("/:id/schedule", handlers.UpdateCollectionSchedule), auth.ScopeCollectionsWrite)
+	auth.TokenScope(collections.DELETE("/:id/schedule", handlers.DeleteCollectionSchedule), auth.ScopeCollectionsWrite)
+	auth.TokenScope(collections.GET("/:id/masking-policy", handlers.GetCollectionMaskingPolicy), auth.ScopeCollectionsRead)
+	auth.TokenScope(collections.PUT("/:id/masking-policy", handlers.SaveCollectionMaskingPolicy), auth.ScopeCollectionsWrite)
+	auth.TokenScope(collections.DELETE("/:id/masking-policy", handlers.DeleteCollectionMaskingPolicy), auth.ScopeCollectionsWrite)
+	maskingPreview := collections.POST("/:id/masking/preview", handlers.PreviewCollectionMasking)
+	auth.TokenScope(maskingPreview, auth.ScopeCollectionsRead)
+	auth.ViewerRoute(maskingPreview)
+	collections.POST("/:id/transfer", handlers.TransferCollection)
+	auth.TokenScope(collections.GET("/snapshot/compare/:collectionId", handlers.CompareSnapShots), auth.ScopeSnapshotsRead)
 
 
-	collections.GET("/:collectionId/snapshot-id", handlers.GetSnapshotID)
-	collections.GET("/:collectionId/changes/summary/:oldSnapshot/:newSnapshot", handlers.GetChangeSummary)
+	auth.TokenScope(collections.GET("/:collectionId/snapshot-id", handlers.GetSnapshotID), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:collectionId/changes/summary/:oldSnapshot/:newSnapshot", handlers.GetChangeSummary), auth.ScopeSnapshotsRead)
 	
 	
 	
-	collections.GET("/:collectionId/changes", handlers.GetChanges)
+	auth.TokenScope(collections.GET("/:collectionId/changes", handlers.GetChanges), auth.ScopeSnapshotsRead)
 	
 	
-	collections.GET("/:id/snapshots/:snapshotId/hierarchy", handlers.GetChangeHierarchy)
+	auth.TokenScope(collections.GET("/:id/snapshots/:snapshotId/hierarchy", handlers.GetChangeHierarchy), auth.ScopeSnapshotsRead)
 	
 	
-	collections.GET("/:collectionId/snapshots/:snapshotId/by-endpoint", handlers.GetChangesByEndpoint)
+	auth.TokenScope(collections.GET("/:collectionId/snapshots/:snapshotId/by-endpoint", handlers.GetChangesByEndpoint), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:collectionId/snapshots/:snapshotId/endpoint-changes", handlers.GetEndpointChanges), auth.ScopeSnapshotsRead)
 	
 	
-	collections.GET("/changes/:changeId", handlers.GetChangeDetails)
+	auth.TokenScope(collections.GET("/changes/:changeId", handlers.GetChangeDetails), auth.ScopeSnapshotsRead)
 
-	collections.GET("/:collectionId/change/summary", handlers.GetCollectionChangeSummary)
+	auth.TokenScope(collections.GET("/:collectionId/change/summary", handlers.GetCollectionChangeSummary), auth.ScopeSnapshotsRead)
 	
 	
-	collections.GET("/:id/changes/export", handlers.ExportChanges)
+	auth.TokenScope(collections.GET("/:id/changes/export", handlers.ExportChanges), auth.ScopeSnapshotsRead)
 
 	
-	collections.GET("/:collectionId/changes/diff/:snapshotId", handlers.GetSnapshotDiff)
-	collections.GET("/:collectionId/diff/:snapshotId", handlers.GetSnapshotDiffID)
+	auth.TokenScope(collections.GET("/:collectionId/changes/diff/:snapshotId", handlers.GetSnapshotDiff), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:collectionId/diff/:snapshotId", handlers.GetSnapshotDiffID), auth.ScopeSnapshotsRead)
 	
 	
-	collections.GET("/:collectionId/snapshots/:snapshotId/impact-analysis", handlers.GetChangeImpactAnalysis)
-	collections.GET("/:collectionId/changes/frequency-analysis", handlers.GetChangeFrequencyAnalysis)
-	collections.GET("/:collectionId/snapshots/compare", handlers.CompareSnapshots)
+	auth.TokenScope(collections.GET("/:collectionId/snapshots/:snapshotId/impact-analysis", handlers.GetChangeImpactAnalysis), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:collectionId/changes/frequency-analysis", handlers.GetChangeFrequencyAnalysis), auth.ScopeSnapshotsRead)
+	auth.TokenScope(collections.GET("/:collectionId/snapshots/compare", handlers.CompareSnapshots), auth.ScopeSnapshotsRead)
+	gate := collections.POST("/:collectionId/gate", handlers.RunBreakingChangeGate)
+	auth.TokenScope(gate, auth.ScopeGateRun)
+	auth.ViewerRoute(gate)
+
+	environments := api.Group("/environments")
+	environments.Use(auth.EnvironmentOwnership)
+	auth.TokenScope(environments.GET("", handlers.GetEnvironments), auth.ScopeCollectionsRead)
+	auth.TokenScope(environments.GET("/user", handlers.GetUserEnvironments), auth.ScopeCollectionsRead)
+	auth.TokenScope(environments.POST("/save-environment", handlers.SaveEnvironment), auth.ScopeCollectionsWrite)
+	auth.TokenScope(environments.GET("/:id/snapshots", handlers.GetEnvironmentSnapshots), auth.ScopeSnapshotsRead)
+	auth.TokenScope(environments.GET("/:id/snapshots/:snapshotId", handlers.GetEnvironmentSnapshot), auth.ScopeSnapshotsRead)
+	auth.TokenScope(environments.GET("/:id/changes", handlers.GetEnvironmentChanges), auth.ScopeSnapshotsRead)
+
+	auth.TokenScope(api.GET("/changes", handlers.GetChangeFeed), auth.ScopeSnapshotsRead)
+	auth.TokenScope(api.GET("/endpoints/search", handlers.SearchAllEndpoints), auth.ScopeSnapshotsRead)
+
+	organizations := api.Group("/organizations")
+	organizations.POST("", handlers.CreateOrganization)
+	organizations.GET("", handlers.GetOrganizations)
+	organizations.GET("/:orgId/members", handlers.GetOrganizationMembers)
+	organizations.POST("/:orgId/members", handlers.AddOrganizationMember)
+	organizations.PUT("/:orgId/members/:userId", handlers.UpdateOrganizationMember)
+	organizations.DELETE("/:orgId/members/:userId", handlers.RemoveOrganizationMember)
+
+	webhooks := api.Group("/webhooks")
+	webhooks.POST("", handlers.CreateWebhook)
+	webhooks.GET("", handlers.GetWebhooks)
+	webhooks.DELETE("/:id", handlers.DeleteWebhook)
+	webhooks.GET("/:id/deliveries", handlers.GetWebhookDeliveries)
+
+	api.GET("/masking-policy", handlers.GetDefaultMaskingPolicy)
+	api.PUT("/masking-policy", handlers.SaveDefaultMaskingPolicy)
+	api.DELETE("/masking-policy", handlers.DeleteDefaultMaskingPolicy)
+
+	api.GET("/audit", handlers.GetAuditEvents)
+
+	admin := api.Group("/admin")
+	admin.Use(auth.RequireAdmin)
+	admin.GET("/kms/rotation", handlers.GetKMSRotationStatus)
+	admin.POST("/kms/rotation", handlers.TriggerKMSRotation)
 
 	jobs := api.Group("/jobs")
-	jobs.GET("", handlers.GetUserJobs)
-	jobs.GET("/:id", handlers.GetJobStatus)
+	auth.TokenScope(jobs.GET("", handlers.GetUserJobs), auth.ScopeJobsRead)
+	auth.TokenScope(jobs.GET("/:id", handlers.GetJobStatus), auth.ScopeJobsRead)
+	auth.TokenScope(jobs.POST("/:id/cancel", handlers.CancelJob), auth.ScopeJobsWrite)
+	auth.TokenScope(jobs.POST("/:id/retry", handlers.RetryJob), auth.ScopeJobsWrite)
+	jobs.GET("/dead-letter", handlers.GetDeadLetterTasks, auth.RequireAdmin)
+	jobs.POST("/dead-letter/requeue", handlers.RequeueDeadLetterTasks, auth.RequireAdmin)
+	jobs.POST("/dead-letter/delete", handlers.DeleteDeadLetterTasks, auth.RequireAdmin)
 }
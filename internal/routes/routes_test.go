@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+
+	"integratorV2/internal/auth"
+	"integratorV2/internal/db"
+)
+
+const (
+	apiPrefix    = "/integrator/api/v1"
+	testSession  = "session-1"
+	callerUserID = int64(3)
+	callerOrgID  = int64(8)
+)
+
+// caller is someone who owns none of the resources the requests name.
+type caller struct {
+	name  string
+	orgID *int64
+}
+
+func newTestServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	if err := auth.InitJWT(); err != nil {
+		t.Fatalf("failed to init JWT: %v", err)
+	}
+	e := echo.New()
+	SetupRoutes(e.Group(apiPrefix))
+	return e
+}
+
+func accessToken(t *testing.T) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": callerUserID,
+		"sid":     testSession,
+		"typ":     "access",
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	})
+	signed, err := token.SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	previous := db.DB
+	db.DB = sqlx.NewDb(conn, "postgres")
+	t.Cleanup(func() {
+		db.DB = previous
+		conn.Close()
+	})
+	return mock
+}
+
+// resourceRoute is a collections route naming a collection, snapshot or change in its path.
+type resourceRoute struct {
+	method       string
+	path         string
+	collectionID string
+	snapshotID   int64
+	changeID     int64
+}
+
+func (r resourceRoute) url() string {
+	var parts []string
+	for _, part := range strings.Split(r.path, "/") {
+		switch part {
+		case ":id", ":collectionId":
+			part = r.collectionID
+		case ":snapshotId", ":oldSnapshot", ":newSnapshot":
+			part = "10"
+		case ":changeId":
+			part = "20"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// collectionResourceRoutes lists every route of the collections group taking a resource ID.
+func collectionResourceRoutes(e *echo.Echo) []resourceRoute {
+	var routes []resourceRoute
+	for _, route := range e.Routes() {
+		if !strings.HasPrefix(route.Path, apiPrefix+"/collections") {
+			continue
+		}
+		r := resourceRoute{method: route.Method, path: route.Path}
+		for _, part := range strings.Split(route.Path, "/") {
+			switch part {
+			case ":id", ":collectionId":
+				r.collectionID = "col-1"
+			case ":snapshotId":
+				r.snapshotID = 10
+			case ":changeId":
+				r.changeID = 20
+			}
+		}
+		if r.collectionID != "" || r.snapshotID != 0 || r.changeID != 0 {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+func existsRows(exists bool) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"exists"}).AddRow(exists)
+}
+
+func orgArg(orgID *int64) driver.Value {
+	if orgID == nil {
+		return nil
+	}
+	return *orgID
+}
+
+func expectAuthentication(mock sqlmock.Sqlmock, who caller) {
+	mock.ExpectQuery(`FROM user_sessions`).WithArgs(testSession).WillReturnRows(existsRows(true))
+	if who.orgID != nil {
+		mock.ExpectQuery(`FROM organization_members m`).
+			WithArgs(*who.orgID, callerUserID).
+			WillReturnRows(sqlmock.NewRows([]string{"organization_id", "user_id", "email", "role", "created_at", "updated_at"}).
+				AddRow(*who.orgID, callerUserID, "caller@example.com", db.RoleEditor, time.Now(), time.Now()))
+	}
+}
+
+func TestCollectionRoutesHideResourcesOutsideScope(t *testing.T) {
+	e := newTestServer(t)
+	token := accessToken(t)
+
+	routes := collectionResourceRoutes(e)
+	if len(routes) == 0 {
+		t.Fatal("no collection routes taking resource IDs were registered")
+	}
+
+	orgID := callerOrgID
+	callers := []caller{
+		{name: "another user"},
+		{name: "another organization", orgID: &orgID},
+	}
+
+	tests := []struct {
+		name string
+		// applies reports whether the route names the resource this case hides.
+		applies func(r resourceRoute) bool
+		expect  func(mock sqlmock.Sqlmock, r resourceRoute, who caller)
+		want    string
+	}{
+		{
+			name:    "collection",
+			applies: func(r resourceRoute) bool { return r.collectionID != "" },
+			expect: func(mock sqlmock.Sqlmock, r resourceRoute, who caller) {
+				mock.ExpectQuery(`SELECT 1 FROM collections`).
+					WithArgs(r.collectionID, callerUserID, orgArg(who.orgID)).
+					WillReturnRows(existsRows(false))
+			},
+			want: "Collection not found",
+		},
+		{
+			name:    "snapshot",
+			applies: func(r resourceRoute) bool { return r.snapshotID != 0 },
+			expect: func(mock sqlmock.Sqlmock, r resourceRoute, who caller) {
+				if r.collectionID != "" {
+					mock.ExpectQuery(`SELECT 1 FROM collections`).
+						WithArgs(r.collectionID, callerUserID, orgArg(who.orgID)).
+						WillReturnRows(existsRows(true))
+				}
+				mock.ExpectQuery(`FROM snapshots s`).
+					WithArgs(r.snapshotID, callerUserID, orgArg(who.orgID), r.collectionID).
+					WillReturnRows(existsRows(false))
+			},
+			want: "Snapshot not found",
+		},
+		{
+			name:    "change",
+			applies: func(r resourceRoute) bool { return r.changeID != 0 },
+			expect: func(mock sqlmock.Sqlmock, r resourceRoute, who caller) {
+				mock.ExpectQuery(`FROM changes ch`).
+					WithArgs(r.changeID, callerUserID, orgArg(who.orgID)).
+					WillReturnRows(existsRows(false))
+			},
+			want: "Change not found",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, r := range routes {
+			if !tt.applies(r) {
+				continue
+			}
+			for _, who := range callers {
+				t.Run(tt.name+"/"+r.method+" "+r.path+"/"+who.name, func(t *testing.T) {
+					mock := mockDB(t)
+					expectAuthentication(mock, who)
+					tt.expect(mock, r, who)
+
+					req := httptest.NewRequest(r.method, r.url(), nil)
+					req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+					if who.orgID != nil {
+						req.Header.Set(auth.OrganizationHeader, "8")
+					}
+					rec := httptest.NewRecorder()
+					e.ServeHTTP(rec, req)
+
+					if rec.Code != http.StatusNotFound {
+						t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body.String())
+					}
+					var body map[string]string
+					if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+						t.Fatalf("failed to decode response: %v", err)
+					}
+					if body["error"] != tt.want {
+						t.Errorf("error = %q, want %q", body["error"], tt.want)
+					}
+					if err := mock.ExpectationsWereMet(); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		}
+	}
+}
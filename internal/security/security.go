@@ -1,6 +1,8 @@
 package security
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"sync"
@@ -8,7 +10,8 @@ import (
 	"github.com/labstack/echo/v4"
 	"golang.org/x/time/rate"
 
-	"integratorV2/internal/config"
+	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
 	"integratorV2/internal/kms"
 )
 
@@ -28,13 +31,19 @@ func InitSecurity() error {
 	initRateLimiters()
 
 	
-	if err := config.InitKMS(); err != nil {
+	if err := encryption.Init(context.Background()); err != nil {
 		return err
 	}
 
 	
-	if err := kms.InitRotation(); err != nil {
-		return err
+	if encryption.Active().Provider() == encryption.ProviderAWSKMS {
+		if err := kms.InitRotation(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.SealPlaintextWebhookSecrets(); err != nil {
+		slog.Error("Failed to encrypt plaintext webhook secrets", "error", err)
 	}
 
 	return nil
@@ -0,0 +1,250 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/queue"
+)
+
+const (
+	EventSnapshotCreated = "snapshot.created"
+	EventChangesDetected = "changes.detected"
+	EventBreakingChange  = "breaking_change.detected"
+	EventImportFailed    = "import.failed"
+
+	SignatureHeader = "X-Integrator-Signature"
+	TimestampHeader = "X-Integrator-Timestamp"
+	EventHeader     = "X-Integrator-Event"
+	DeliveryHeader  = "X-Integrator-Delivery"
+
+	// maxDrainedBody bounds how much of a response is read, and discarded, to reuse the connection.
+	maxDrainedBody = 4096
+)
+
+// Failed deliveries record one of these classes instead of the error text or response body, so
+// deliveries never reveal what an endpoint, or a host behind it, returned.
+const (
+	ErrorClassBlockedAddress = "blocked_address"
+	ErrorClassTimeout        = "timeout"
+	ErrorClassConnection     = "connection_failed"
+	ErrorClassHTTPStatus     = "http_status"
+	ErrorClassInvalidRequest = "invalid_request"
+	ErrorClassSecret         = "secret_unavailable"
+	ErrorClassEnqueue        = "enqueue_failed"
+)
+
+// ErrBlockedAddress is returned for webhook URLs that resolve to loopback, private, link-local or
+// otherwise internal addresses.
+var ErrBlockedAddress = errors.New("webhook address is not allowed")
+
+var Events = []string{
+	EventSnapshotCreated,
+	EventChangesDetected,
+	EventBreakingChange,
+	EventImportFailed,
+}
+
+// httpClient checks every address it connects to, including after redirects and DNS changes
+// since the webhook was created. It ignores proxy settings, which would hide the real address.
+var httpClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: blockInternalAddresses,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+func blockInternalAddresses(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || IsBlockedAddress(addrPort.Addr()) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// IsBlockedAddress reports whether webhooks may not be delivered to addr.
+func IsBlockedAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which cloud providers also use internally.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+type Envelope struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type DeliveryError struct {
+	StatusCode int
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("webhook endpoint responded with status %d", e.StatusCode)
+}
+
+// ErrorClass classifies a delivery error for storing with the delivery.
+func ErrorClass(err error) string {
+	var deliveryErr *DeliveryError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrBlockedAddress):
+		return ErrorClassBlockedAddress
+	case errors.As(err, &deliveryErr):
+		return ErrorClassHTTPStatus
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.Is(err, db.ErrWebhookSecret):
+		return ErrorClassSecret
+	case errors.Is(err, errInvalidRequest):
+		return ErrorClassInvalidRequest
+	default:
+		return ErrorClassConnection
+	}
+}
+
+var errInvalidRequest = errors.New("invalid webhook request")
+
+func IsValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign signs a delivery sent at timestamp, in Unix seconds. The signed message is the timestamp,
+// a dot and the body, so receivers can reject old deliveries without the signature still
+// matching a replayed body under a new timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff doubles the wait after every failed attempt, starting at 30s and capped at one hour.
+func Backoff(retryCount int) time.Duration {
+	delay := 30 * time.Second
+	for i := 0; i < retryCount && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// Dispatch records a delivery for every active webhook of the scope subscribed to the event
+// and queues it. Failures are logged and never propagated to the caller.
+func Dispatch(scope db.Scope, event string, data interface{}) {
+	webhooks, err := db.GetActiveWebhooksForEvent(scope, event)
+	if err != nil {
+		slog.Error("Failed to load webhooks", "error", err, "user_id", scope.UserID, "organization_id", scope.OrganizationID, "event", event)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(Envelope{
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		slog.Error("Failed to marshal webhook payload", "error", err, "event", event)
+		return
+	}
+
+	for _, hook := range webhooks {
+		deliveryID, err := db.CreateWebhookDelivery(hook.ID, event, body)
+		if err != nil {
+			slog.Error("Failed to record webhook delivery", "error", err, "webhook_id", hook.ID, "event", event)
+			continue
+		}
+
+		if err := queue.EnqueueWebhookDelivery(deliveryID); err != nil {
+			slog.Error("Failed to enqueue webhook delivery", "error", err, "webhook_id", hook.ID, "delivery_id", deliveryID)
+			errClass := ErrorClassEnqueue
+			if err := db.UpdateWebhookDelivery(deliveryID, "failed", 0, nil, &errClass); err != nil {
+				slog.Error("Failed to update webhook delivery", "error", err, "delivery_id", deliveryID)
+			}
+		}
+	}
+}
+
+// Deliver posts a delivery to its webhook and returns the response status. The response body is
+// discarded.
+func Deliver(ctx context.Context, hook *db.Webhook, delivery *db.WebhookDelivery) (int, error) {
+	secret, err := hook.SigningSecret()
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errInvalidRequest, err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "integrator-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, delivery.Payload))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, &DeliveryError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/notification"
+	"integratorV2/internal/queue"
+)
+
+// bulkImportSummary is stored as the result of a finished bulk import.
+type bulkImportSummary struct {
+	Succeeded []string            `json:"succeeded"`
+	Unchanged []string            `json:"unchanged"`
+	Failed    []bulkImportFailure `json:"failed"`
+}
+
+type bulkImportFailure struct {
+	CollectionID string  `json:"collection_id"`
+	Name         string  `json:"name"`
+	Status       string  `json:"status"`
+	ErrorCode    *string `json:"error_code"`
+	Error        *string `json:"error"`
+}
+
+func (w *Worker) handleBulkImportCheck(ctx context.Context, t *asynq.Task) error {
+	var payload queue.BulkImportCheckPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %v: %w", err, asynq.SkipRetry)
+	}
+	return finishBulkImport(ctx, payload.ParentJobID)
+}
+
+// finishBulkImport completes a bulk import once all of its children have finished, and sends
+// the summary notification. It does nothing while children are still running, or when another
+// child already finished the bulk import.
+func finishBulkImport(ctx context.Context, parentJobID int64) error {
+	children, err := db.GetChildCollectionJobs(parentJobID)
+	if err != nil {
+		return err
+	}
+
+	summary := bulkImportSummary{Succeeded: []string{}, Unchanged: []string{}, Failed: []bulkImportFailure{}}
+	for _, child := range children {
+		switch {
+		case child.Status == db.JobStatusCompleted && child.ErrorCode != nil && *child.ErrorCode == db.JobErrorIdenticalSnapshot:
+			summary.Unchanged = append(summary.Unchanged, child.Name)
+		case child.Status == db.JobStatusCompleted:
+			summary.Succeeded = append(summary.Succeeded, child.Name)
+		case child.Status == db.JobStatusFailed || child.Status == db.JobStatusCanceled:
+			summary.Failed = append(summary.Failed, bulkImportFailure{
+				CollectionID: child.CollectionID,
+				Name:         child.Name,
+				Status:       child.Status,
+				ErrorCode:    child.ErrorCode,
+				Error:        child.Error,
+			})
+		default:
+			return nil
+		}
+	}
+
+	status := db.JobStatusCompleted
+	if len(children) > 0 && len(summary.Failed) == len(children) {
+		status = db.JobStatusFailed
+	}
+
+	result, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("failed to encode bulk import summary: %v", err)
+	}
+	finished, err := db.FinishBulkImportJob(parentJobID, status, result)
+	if err != nil || !finished {
+		return err
+	}
+
+	parent, err := db.GetCollectionJob(parentJobID)
+	if err != nil {
+		return err
+	}
+
+	notificationType := notification.NotificationType("success")
+	if status == db.JobStatusFailed {
+		notificationType = "fail"
+	}
+	notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+		UserID:  strconv.Itoa(int(parent.UserID)),
+		Type:    notificationType,
+		Title:   "Bulk collection import finished",
+		Message: bulkImportMessage(summary),
+	})
+
+	slog.Info("Finished bulk import",
+		"job_id", parentJobID,
+		"succeeded", len(summary.Succeeded),
+		"unchanged", len(summary.Unchanged),
+		"failed", len(summary.Failed),
+	)
+	return nil
+}
+
+func bulkImportMessage(summary bulkImportSummary) string {
+	failed := make([]string, len(summary.Failed))
+	for i, failure := range summary.Failed {
+		failed[i] = failure.Name
+	}
+
+	message := fmt.Sprintf("%d imported, %d unchanged, %d failed.",
+		len(summary.Succeeded), len(summary.Unchanged), len(summary.Failed))
+	for _, list := range []struct {
+		label string
+		names []string
+	}{
+		{"Imported", summary.Succeeded},
+		{"Unchanged", summary.Unchanged},
+		{"Failed", failed},
+	} {
+		if len(list.names) == 0 {
+			continue
+		}
+		quoted := make([]string, len(list.names))
+		for i, name := range list.names {
+			quoted[i] = fmt.Sprintf("'%s'", name)
+		}
+		message += fmt.Sprintf(" %s: %s.", list.label, strings.Join(quoted, ", "))
+	}
+	return message
+}
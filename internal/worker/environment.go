@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/notification"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+)
+
+func (w *Worker) handleEnvironmentImport(ctx context.Context, t *asynq.Task) error {
+	var payload queue.EnvironmentImportPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	userIDStr := strconv.Itoa(int(payload.UserID))
+
+	fail := func(stage string, err error) error {
+		notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+			UserID:  userIDStr,
+			Type:    "fail",
+			Title:   "import environment failed",
+			Message: fmt.Sprintf("import environment failed '%s'", payload.Name),
+		})
+		slog.Error("Failed to import environment", "error", err, "stage", stage, "user_id", payload.UserID, "environment_id", payload.EnvironmentID)
+		return err
+	}
+
+	apiKey, err := db.GetPostmanAPIKey(payload.Scope())
+	if err != nil {
+		return fail("api_key", err)
+	}
+
+	environment, err := postman.GetEnvironment(apiKey, payload.EnvironmentID)
+	if err != nil {
+		return fail("fetch", err)
+	}
+
+	result, err := postman.StoreEnvironmentSnapshot(payload.EnvironmentID, payload.Name, environment, payload.Scope())
+	if err != nil {
+		return fail("store", err)
+	}
+
+	slog.Info("Successfully processed environment import",
+		"user_id", payload.UserID,
+		"environment_id", payload.EnvironmentID,
+		"name", payload.Name,
+		"identical", result.Identical,
+		"change_count", result.ChangeCount,
+	)
+
+	notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+		UserID:  userIDStr,
+		Type:    "success",
+		Title:   "Environment Import Successful",
+		Message: fmt.Sprintf("Successfully imported environment '%s'", payload.Name),
+	})
+
+	return nil
+}
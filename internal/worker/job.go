@@ -0,0 +1,205 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+)
+
+// importJob tracks an import attempt in its collection_jobs row. Failing to update the row is
+// logged and never fails the import; a job with no row is not tracked.
+type importJob struct {
+	ctx        context.Context
+	id         int64
+	parentID   int64
+	stage      string
+	stageStart time.Time
+	durations  map[string]int64
+}
+
+// startImportJob starts a new attempt of the job in the payload. Scheduled imports are enqueued
+// without a job, so one is created on their first attempt and found by task ID on retries.
+func startImportJob(ctx context.Context, payload queue.CollectionImportPayload, stage string) *importJob {
+	job := &importJob{ctx: ctx, id: payload.JobID, parentID: payload.ParentJobID}
+	if job.id == 0 {
+		job.id = findOrCreateImportJob(ctx, payload)
+	}
+
+	job.stage = stage
+	job.stageStart = time.Now()
+	job.durations = make(map[string]int64)
+	if job.id != 0 {
+		if err := db.StartCollectionJobAttempt(job.id, stage); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", job.id)
+		}
+	}
+	return job
+}
+
+func findOrCreateImportJob(ctx context.Context, payload queue.CollectionImportPayload) int64 {
+	taskID, _ := asynq.GetTaskID(ctx)
+	if taskID != "" {
+		job, err := db.GetCollectionJobByTaskID(taskID)
+		if err == nil {
+			return job.ID
+		}
+		if !errors.Is(err, db.ErrCollectionJobNotFound) {
+			slog.Warn("Failed to find job for task", "error", err, "task_id", taskID)
+			return 0
+		}
+	}
+
+	job, err := db.CreateCollectionJob(payload.Scope(), payload.CollectionID, payload.Name)
+	if err != nil {
+		slog.Warn("Failed to create collection job", "error", err, "collection_id", payload.CollectionID)
+		return 0
+	}
+	if taskID != "" {
+		if err := db.SetCollectionJobTaskID(job.ID, taskID); err != nil {
+			slog.Warn("Failed to link job to task", "error", err, "job_id", job.ID, "task_id", taskID)
+		}
+	}
+	return job.ID
+}
+
+// enter ends the current stage, recording its duration, and starts the next one.
+func (j *importJob) enter(stage string) {
+	j.endStage()
+	j.stage = stage
+	if j.id == 0 {
+		return
+	}
+	if err := db.UpdateCollectionJobStage(j.id, stage, j.durations); err != nil {
+		slog.Warn("Failed to update job status", "error", err, "job_id", j.id)
+	}
+}
+
+func (j *importJob) endStage() {
+	j.durations[j.stage] += time.Since(j.stageStart).Milliseconds()
+	j.stageStart = time.Now()
+}
+
+func (j *importJob) complete(result *postman.SnapshotResult) {
+	j.endStage()
+	outcome := db.JobOutcome{Status: db.JobStatusCompleted, StageDurations: j.durations}
+	if result.Identical {
+		code := db.JobErrorIdenticalSnapshot
+		outcome.ErrorCode = &code
+	} else {
+		outcome.SnapshotID = &result.SnapshotID
+		outcome.ChangeCount = &result.ChangeCount
+	}
+	j.finish(outcome)
+}
+
+// fail records a failed attempt. The job stays retrying while asynq has attempts left.
+func (j *importJob) fail(stage string, err error) {
+	j.endStage()
+	status := db.JobStatusRetrying
+	if isFinalAttempt(j.ctx) || errors.Is(err, asynq.SkipRetry) {
+		status = db.JobStatusFailed
+	}
+	code := classifyImportError(stage, err)
+	errMsg := err.Error()
+	j.finish(db.JobOutcome{
+		Status:         status,
+		ErrorCode:      &code,
+		Error:          &errMsg,
+		StageDurations: j.durations,
+	})
+}
+
+func (j *importJob) finish(outcome db.JobOutcome) {
+	if j.id == 0 {
+		return
+	}
+	if err := db.FinishCollectionJobAttempt(j.id, outcome); err != nil {
+		slog.Warn("Failed to update job status", "error", err, "job_id", j.id)
+	}
+
+	if j.parentID != 0 && outcome.Status != db.JobStatusRetrying {
+		if err := finishBulkImport(j.ctx, j.parentID); err != nil {
+			slog.Warn("Failed to finish bulk import", "error", err, "job_id", j.parentID)
+		}
+	}
+}
+
+// classifyImportError maps the stage an import failed in, and the Postman response status for
+// fetch failures, to a job error code.
+func classifyImportError(stage string, err error) string {
+	switch stage {
+	case "api_key":
+		return db.JobErrorAPIKeyMissing
+	case "parse":
+		return db.JobErrorInvalidCollection
+	case "mask", "marshal":
+		return db.JobErrorMaskingFailed
+	case "store":
+		return db.JobErrorDatabaseWriteFailed
+	}
+
+	var apiErr *postman.APIError
+	if !errors.As(err, &apiErr) {
+		return db.JobErrorPostmanUnreachable
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized:
+		return db.JobErrorPostmanUnauthorized
+	case apiErr.StatusCode == http.StatusForbidden:
+		return db.JobErrorPostmanForbidden
+	case apiErr.StatusCode == http.StatusNotFound:
+		return db.JobErrorPostmanNotFound
+	case apiErr.StatusCode == http.StatusTooManyRequests:
+		return db.JobErrorPostmanRateLimited
+	case apiErr.StatusCode >= http.StatusInternalServerError:
+		return db.JobErrorPostmanUnavailable
+	default:
+		return db.JobErrorPostmanError
+	}
+}
+
+// skipCanceledJobs ends the task of a canceled job instead of letting asynq retry it. A canceled
+// task fails with the context error, or with whatever the step it was in returned.
+func skipCanceledJobs(next asynq.HandlerFunc) asynq.HandlerFunc {
+	return func(ctx context.Context, t *asynq.Task) error {
+		err := next(ctx, t)
+		if err == nil {
+			return nil
+		}
+
+		taskID, _ := asynq.GetTaskID(ctx)
+		job, lookupErr := db.GetCollectionJobByTaskID(taskID)
+		if lookupErr == nil && job.Status == db.JobStatusCanceled {
+			slog.Info("Stopped canceled job", "job_id", job.ID, "task_id", taskID)
+			return nil
+		}
+		return err
+	}
+}
+
+// enqueueTrackedImport creates the job for an import and enqueues it.
+func enqueueTrackedImport(payload queue.CollectionImportPayload) error {
+	job, err := db.CreateCollectionJob(payload.Scope(), payload.CollectionID, payload.Name)
+	if err != nil {
+		return err
+	}
+	payload.JobID = job.ID
+
+	taskID, err := queue.EnqueueCollectionImport(payload)
+	if err != nil {
+		errMsg := "failed to enqueue collection import"
+		if err := db.UpdateCollectionJobStatus(job.ID, db.JobStatusFailed, &errMsg); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", job.ID)
+		}
+		return err
+	}
+	return db.SetCollectionJobTaskID(job.ID, taskID)
+}
@@ -3,13 +3,13 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
-	"integratorV2/internal/config"
 	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
 	"integratorV2/internal/queue"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -18,55 +18,90 @@ import (
 	"github.com/hibiken/asynq"
 )
 
+const kmsReencryptBatchSize = 100
 
+
+// HandleKMSRotation creates a new KMS key, makes it the active key for every replica and
+// re-encrypts the stored Postman API keys under it in batches. A rotation that fails part way
+// is picked up again by the retry, or by the next startup.
 func (w *Worker) HandleKMSRotation(ctx context.Context, t *asynq.Task) error {
 	var payload queue.KMSRotationPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
 		return fmt.Errorf("failed to unmarshal payload: %v", err)
 	}
 
-	
-	input := &kms.CreateKeyInput{
-		Description: aws.String("Auto-rotated KMS key"),
-		Tags: []types.Tag{
-			{
-				TagKey:   aws.String("AutoRotated"),
-				TagValue: aws.String("true"),
-			},
-		},
+	kmsEncryptor, ok := encryption.Active().(*encryption.KMSEncryptor)
+	if !ok {
+		slog.Warn("Skipping KMS rotation, secrets are not encrypted with AWS KMS", "key_id", payload.KeyID)
+		return nil
 	}
 
-	result, err := config.KMSClient.CreateKey(ctx, input)
+	nextRotation := time.Now().Add(queue.KMSRotationInterval)
+	key, err := db.StartKMSRotation(payload.KeyID, nextRotation, func() (string, error) {
+		
+		result, err := kmsEncryptor.Client().CreateKey(ctx, &kms.CreateKeyInput{
+			Description: aws.String("Auto-rotated KMS key"),
+			Tags: []types.Tag{
+				{
+					TagKey:   aws.String("AutoRotated"),
+					TagValue: aws.String("true"),
+				},
+			},
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create new KMS key: %v", err)
+		}
+		return *result.KeyMetadata.KeyId, nil
+	})
+	if errors.Is(err, db.ErrKMSRotationStale) {
+		slog.Info("Skipping KMS rotation, the key was already rotated", "key_id", payload.KeyID)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create new KMS key: %v", err)
+		return fmt.Errorf("failed to start KMS rotation: %v", err)
 	}
 
+	slog.Info("Re-encrypting API keys under new KMS key",
+		"old_key_id", payload.KeyID,
+		"new_key_id", key.KeyID,
+		"version", key.Version)
+
 	
-	if err := os.Setenv("AWS_KMS_KEY_ID", *result.KeyMetadata.KeyId); err != nil {
-		return fmt.Errorf("failed to update KMS key ID: %v", err)
+	var afterID int64
+	failed := 0
+	for {
+		batch, err := db.ReencryptAPIKeyBatch(key, afterID, kmsReencryptBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt API keys: %v", err)
+		}
+		failed += batch.Failed
+		if batch.Processed == 0 {
+			break
+		}
+		afterID = batch.LastID
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d API keys could not be re-encrypted under KMS key version %d", failed, key.Version)
 	}
 
 	
-	nextRotation := time.Now().Add(3 * 30 * 24 * time.Hour) // 3 months
-	_, err = db.DB.Exec(`
-		UPDATE kms_key_rotation
-		SET last_rotated_at = CURRENT_TIMESTAMP,
-			next_rotation_at = $1,
-			updated_at = CURRENT_TIMESTAMP
-		WHERE key_id = $2
-	`, nextRotation, payload.KeyID)
+	remaining, err := db.CompleteKMSRotation(key)
+	if errors.Is(err, db.ErrKMSRotationIncomplete) {
+		return fmt.Errorf("%d API keys are still being re-encrypted under KMS key version %d", remaining, key.Version)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update rotation record: %v", err)
+		return err
 	}
 
 	
-	if err := queue.ScheduleKMSRotation(*result.KeyMetadata.KeyId); err != nil {
+	if err := queue.ScheduleKMSRotation(key.KeyID, key.NextRotationAt); err != nil {
 		return fmt.Errorf("failed to schedule next rotation: %v", err)
 	}
 
 	slog.Info("Successfully rotated KMS key",
 		"old_key_id", payload.KeyID,
-		"new_key_id", *result.KeyMetadata.KeyId)
+		"new_key_id", key.KeyID,
+		"version", key.Version)
 
 	return nil
 }
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/notification"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+)
+
+func (w *Worker) handleCollectionRestore(ctx context.Context, t *asynq.Task) error {
+	var payload queue.CollectionRestorePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	if err := db.UpdateCollectionJobStatus(payload.JobID, "processing", nil); err != nil {
+		slog.Warn("Failed to update job status", "error", err, "job_id", payload.JobID)
+	}
+
+	fail := func(err error, result json.RawMessage) error {
+		status := "retrying"
+		if isFinalAttempt(ctx) || errors.Is(err, asynq.SkipRetry) {
+			status = "failed"
+			notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+				UserID:  strconv.Itoa(int(payload.UserID)),
+				Type:    "fail",
+				Title:   "collection restore failed",
+				Message: fmt.Sprintf("restore collection failed '%s'", payload.Name),
+			})
+		}
+
+		errMsg := err.Error()
+		if err := db.CompleteCollectionJob(payload.JobID, status, result, &errMsg); err != nil {
+			slog.Warn("Failed to update job status", "error", err, "job_id", payload.JobID)
+		}
+
+		slog.Error("Failed to restore collection snapshot", "error", err, "user_id", payload.UserID, "collection_id", payload.CollectionID, "snapshot_id", payload.SnapshotID, "job_id", payload.JobID)
+		return err
+	}
+
+	apiKey, err := db.GetPostmanAPIKey(payload.Scope())
+	if err != nil {
+		return fail(fmt.Errorf("failed to get API key: %v", err), nil)
+	}
+
+	snapshot, err := db.GetCollectionSnapshot(payload.CollectionID, payload.SnapshotID)
+	if err != nil {
+		return fail(fmt.Errorf("%v: %w", err, asynq.SkipRetry), nil)
+	}
+
+	masking, _, err := postman.LoadMaskingConfig(payload.CollectionID, payload.Scope())
+	if err != nil {
+		return fail(err, nil)
+	}
+
+	plan, err := postman.PlanRestore(apiKey, payload.CollectionID, payload.SnapshotID, snapshot.Content, masking)
+	if err != nil {
+		return fail(err, nil)
+	}
+	plan.DryRun = payload.DryRun
+
+	if !payload.DryRun {
+		if !plan.CanApply {
+			result, _ := json.Marshal(plan)
+			return fail(fmt.Errorf("%s: %w", plan.BlockedReason, asynq.SkipRetry), result)
+		}
+
+		if err := postman.ApplyRestore(apiKey, payload.CollectionID, payload.SnapshotID, snapshot.Content); err != nil {
+			return fail(err, nil)
+		}
+		plan.Applied = true
+
+		if err := enqueueTrackedImport(queue.CollectionImportPayload{
+			UserID:         payload.UserID,
+			OrganizationID: payload.OrganizationID,
+			CollectionID:   payload.CollectionID,
+			Name:           payload.Name,
+		}); err != nil {
+			slog.Warn("Failed to enqueue snapshot of restored collection", "error", err, "collection_id", payload.CollectionID)
+		}
+	}
+
+	result, err := json.Marshal(plan)
+	if err != nil {
+		return fail(fmt.Errorf("failed to encode restore result: %v", err), nil)
+	}
+
+	if err := db.CompleteCollectionJob(payload.JobID, "completed", result, nil); err != nil {
+		slog.Warn("Failed to update job status", "error", err, "job_id", payload.JobID)
+	}
+
+	slog.Info("Processed collection restore",
+		"user_id", payload.UserID,
+		"collection_id", payload.CollectionID,
+		"snapshot_id", payload.SnapshotID,
+		"dry_run", payload.DryRun,
+		"change_count", plan.ChangeCount,
+	)
+
+	if !payload.DryRun {
+		notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+			UserID:  strconv.Itoa(int(payload.UserID)),
+			Type:    "success",
+			Title:   "Collection Restore Successful",
+			Message: fmt.Sprintf("Successfully restored collection '%s' to snapshot %d", payload.Name, payload.SnapshotID),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/queue"
+)
+
+type Scheduler struct {
+	manager *asynq.PeriodicTaskManager
+}
+
+type collectionScheduleProvider struct{}
+
+func (p *collectionScheduleProvider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	schedules, err := db.GetEnabledCollectionSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*asynq.PeriodicTaskConfig, 0, len(schedules))
+	for _, schedule := range schedules {
+		cronspec := schedule.Cronspec()
+		if cronspec == "" {
+			continue
+		}
+
+		config, err := queue.ScheduledCollectionImportConfig(cronspec, queue.CollectionImportPayload{
+			UserID:         schedule.UserID,
+			OrganizationID: schedule.OrganizationID,
+			CollectionID:   schedule.CollectionID,
+			Name:           schedule.CollectionName,
+		})
+		if err != nil {
+			slog.Error("Failed to build scheduled import", "error", err, "collection_id", schedule.CollectionID)
+			continue
+		}
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
+
+func NewScheduler() (*Scheduler, error) {
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+
+	manager, err := asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+		RedisConnOpt:               asynq.RedisClientOpt{Addr: redisAddr},
+		PeriodicTaskConfigProvider: &collectionScheduleProvider{},
+		SyncInterval:               time.Minute,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{
+		manager: manager,
+	}, nil
+}
+
+func (s *Scheduler) Start(ctx context.Context) error {
+	if err := s.manager.Start(); err != nil {
+		return err
+	}
+
+	slog.Info("Collection scheduler started")
+
+	<-ctx.Done()
+
+	s.manager.Shutdown()
+	slog.Info("Collection scheduler stopped")
+	return nil
+}
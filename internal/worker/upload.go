@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/encryption"
+	"integratorV2/internal/notification"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+)
+
+func (w *Worker) handleCollectionUpload(ctx context.Context, t *asynq.Task) error {
+	var payload queue.CollectionUploadPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %v: %w", err, asynq.SkipRetry)
+	}
+
+	job := startImportJob(ctx, payload.CollectionImportPayload, db.JobStatusMasking)
+
+	fail := func(stage string, err error) error {
+		job.fail(stage, err)
+		if isFinalAttempt(ctx) || errors.Is(err, asynq.SkipRetry) {
+			notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+				UserID:  strconv.Itoa(int(payload.UserID)),
+				Type:    "fail",
+				Title:   "collection upload failed",
+				Message: fmt.Sprintf("import uploaded collection failed '%s'", payload.Name),
+			})
+		}
+
+		slog.Error("Failed to import uploaded collection", "error", err, "stage", stage, "user_id", payload.UserID, "collection_id", payload.CollectionID, "job_id", payload.JobID)
+		dispatchImportFailed(ctx, payload.CollectionImportPayload, stage, err)
+		return err
+	}
+
+	content, err := loadCollectionUpload(payload.JobID)
+	if err != nil {
+		return fail("parse", err)
+	}
+	collection, err := postman.ParseCollectionExport(content)
+	if err != nil {
+		return fail("parse", fmt.Errorf("%v: %w", err, asynq.SkipRetry))
+	}
+
+	result, stage, err := storeCollectionSnapshot(payload.CollectionImportPayload, collection, job)
+	if err != nil {
+		return fail(stage, err)
+	}
+	job.complete(result)
+	if err := db.DeleteCollectionUpload(payload.JobID); err != nil {
+		slog.Warn("Failed to delete collection upload", "error", err, "job_id", payload.JobID)
+	}
+
+	slog.Info("Successfully processed collection upload",
+		"user_id", payload.UserID,
+		"collection_id", payload.CollectionID,
+		"name", payload.Name,
+		"job_id", payload.JobID,
+	)
+
+	dispatchSnapshotEvents(payload.CollectionImportPayload, result)
+
+	notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
+		UserID:  strconv.Itoa(int(payload.UserID)),
+		Type:    "success",
+		Title:   "Collection Import Successful",
+		Message: fmt.Sprintf("Successfully imported collection '%s'", payload.Name),
+	})
+
+	return nil
+}
+
+// loadCollectionUpload opens the export stored sealed for an upload job. The export is kept until
+// the job succeeds, so a failed upload can be retried.
+func loadCollectionUpload(jobID int64) ([]byte, error) {
+	sealedContent, err := db.GetCollectionUpload(jobID)
+	if errors.Is(err, db.ErrCollectionUploadNotFound) {
+		return nil, fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	content, err := encryption.DecryptString(sealedContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt collection upload: %v", err)
+	}
+	return []byte(content), nil
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/hibiken/asynq"
+
+	"integratorV2/internal/db"
+	"integratorV2/internal/postman"
+	"integratorV2/internal/queue"
+	"integratorV2/internal/webhook"
+)
+
+func (w *Worker) handleWebhookDelivery(ctx context.Context, t *asynq.Task) error {
+	var payload queue.WebhookDeliveryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %v", err)
+	}
+
+	delivery, hook, err := db.GetWebhookDelivery(payload.DeliveryID)
+	if err != nil {
+		slog.Error("Failed to load webhook delivery", "error", err, "delivery_id", payload.DeliveryID)
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+
+	if !hook.IsActive {
+		if err := db.UpdateWebhookDelivery(delivery.ID, "skipped", delivery.Attempts, nil, nil); err != nil {
+			slog.Error("Failed to update webhook delivery", "error", err, "delivery_id", delivery.ID)
+		}
+		return nil
+	}
+
+	retryCount, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	attempts := retryCount + 1
+
+	statusCode, deliverErr := webhook.Deliver(ctx, hook, delivery)
+
+	var responseStatus *int
+	if statusCode != 0 {
+		responseStatus = &statusCode
+	}
+
+	if deliverErr == nil {
+		if err := db.UpdateWebhookDelivery(delivery.ID, "delivered", attempts, responseStatus, nil); err != nil {
+			slog.Error("Failed to update webhook delivery", "error", err, "delivery_id", delivery.ID)
+		}
+		slog.Info("Delivered webhook", "webhook_id", hook.ID, "delivery_id", delivery.ID, "event", delivery.Event, "attempts", attempts)
+		return nil
+	}
+
+	errClass := webhook.ErrorClass(deliverErr)
+	status := "retrying"
+	if retryCount >= maxRetry {
+		status = "failed"
+	}
+
+	// A blocked address stays blocked, so the delivery is not retried.
+	if errClass == webhook.ErrorClassBlockedAddress {
+		status = "failed"
+		deliverErr = fmt.Errorf("%w: %w", deliverErr, asynq.SkipRetry)
+	}
+
+	if err := db.UpdateWebhookDelivery(delivery.ID, status, attempts, responseStatus, &errClass); err != nil {
+		slog.Error("Failed to update webhook delivery", "error", err, "delivery_id", delivery.ID)
+	}
+
+	slog.Warn("Webhook delivery failed",
+		"error", deliverErr,
+		"error_class", errClass,
+		"webhook_id", hook.ID,
+		"delivery_id", delivery.ID,
+		"event", delivery.Event,
+		"attempts", attempts,
+		"status", status,
+	)
+	return deliverErr
+}
+
+func retryDelay(n int, err error, t *asynq.Task) time.Duration {
+	if t.Type() == queue.QueueWebhookDelivery {
+		return webhook.Backoff(n)
+	}
+	return asynq.DefaultRetryDelayFunc(n, err, t)
+}
+
+func isFinalAttempt(ctx context.Context) bool {
+	retryCount, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return true
+	}
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	return retryCount >= maxRetry
+}
+
+// webhookScope returns the scope whose webhooks receive the events of the payload's collection:
+// the collection's current owner, or the payload's scope before the collection is first stored.
+func webhookScope(payload queue.CollectionImportPayload) db.Scope {
+	scope, err := db.GetCollectionScope(payload.CollectionID)
+	if errors.Is(err, db.ErrCollectionNotFound) {
+		return payload.Scope()
+	}
+	if err != nil {
+		slog.Error("Failed to get collection owner for webhooks", "error", err, "collection_id", payload.CollectionID)
+		return payload.Scope()
+	}
+	return scope
+}
+
+func dispatchImportFailed(ctx context.Context, payload queue.CollectionImportPayload, stage string, err error) {
+	if !isFinalAttempt(ctx) && !errors.Is(err, asynq.SkipRetry) {
+		return
+	}
+
+	webhook.Dispatch(webhookScope(payload), webhook.EventImportFailed, map[string]interface{}{
+		"collection_id": payload.CollectionID,
+		"name":          payload.Name,
+		"scheduled":     payload.Scheduled,
+		"stage":         stage,
+		"error":         err.Error(),
+	})
+}
+
+func dispatchSnapshotEvents(payload queue.CollectionImportPayload, result *postman.SnapshotResult) {
+	if result == nil || result.Identical {
+		return
+	}
+
+	data := map[string]interface{}{
+		"collection_id":        payload.CollectionID,
+		"name":                 payload.Name,
+		"scheduled":            payload.Scheduled,
+		"snapshot_id":          result.SnapshotID,
+		"previous_snapshot_id": result.PreviousSnapshotID,
+		"change_count":         result.ChangeCount,
+	}
+
+	scope := webhookScope(payload)
+	webhook.Dispatch(scope, webhook.EventSnapshotCreated, data)
+
+	if result.ChangeCount == 0 {
+		return
+	}
+
+	webhook.Dispatch(scope, webhook.EventChangesDetected, data)
+
+	analysis, err := db.AnalyzeChangeImpact(payload.CollectionID, result.SnapshotID)
+	if err != nil {
+		slog.Error("Failed to analyze change impact for webhooks", "error", err, "collection_id", payload.CollectionID)
+		return
+	}
+	if analysis.Summary.TotalBreaking == 0 {
+		return
+	}
+
+	breaking := make([]map[string]interface{}, 0, len(analysis.BreakingChanges))
+	for _, impact := range analysis.BreakingChanges {
+		breaking = append(breaking, map[string]interface{}{
+			"change_type": impact.Change.ChangeType,
+			"path":        impact.Change.Path,
+			"human_path":  impact.Change.HumanPath,
+			"impact":      impact.Impact,
+			"severity":    impact.Severity,
+		})
+	}
+
+	webhook.Dispatch(scope, webhook.EventBreakingChange, map[string]interface{}{
+		"collection_id":        payload.CollectionID,
+		"name":                 payload.Name,
+		"snapshot_id":          result.SnapshotID,
+		"previous_snapshot_id": result.PreviousSnapshotID,
+		"risk_score":           analysis.Summary.RiskScore,
+		"recommendation":       analysis.Summary.Recommendation,
+		"breaking_changes":     breaking,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 
 	"github.com/hibiken/asynq"
+	"github.com/jmoiron/sqlx"
 
 	"integratorV2/internal/db"
 	"integratorV2/internal/notification"
@@ -37,7 +38,9 @@ func NewWorker() *Worker {
 			Queues: map[string]int{
 				queue.QueueCollectionImport: 10,
 				queue.QueueKMSRotation:      1,
+				queue.QueueWebhookDelivery:  5,
 			},
+			RetryDelayFunc: retryDelay,
 		},
 	)
 
@@ -50,11 +53,16 @@ func (w *Worker) Start(ctx context.Context) error {
 
 	mux := asynq.NewServeMux()
 
-	mux.HandleFunc(queue.QueueCollectionImport, w.handleCollectionImport)
+	mux.HandleFunc(queue.QueueCollectionImport, skipCanceledJobs(w.handleCollectionImport))
+	mux.HandleFunc(queue.TaskCollectionUpload, skipCanceledJobs(w.handleCollectionUpload))
+	mux.HandleFunc(queue.TaskCollectionRestore, skipCanceledJobs(w.handleCollectionRestore))
+	mux.HandleFunc(queue.TaskBulkImportCheck, w.handleBulkImportCheck)
+	mux.HandleFunc(queue.TaskEnvironmentImport, w.handleEnvironmentImport)
 	mux.HandleFunc(queue.QueueKMSRotation, w.HandleKMSRotation)
+	mux.HandleFunc(queue.QueueWebhookDelivery, w.handleWebhookDelivery)
 
 	slog.Info("Starting worker",
-		"queues", []string{queue.QueueCollectionImport, queue.QueueKMSRotation},
+		"queues", []string{queue.QueueCollectionImport, queue.QueueKMSRotation, queue.QueueWebhookDelivery},
 		"concurrency", 10)
 
 	
@@ -80,8 +88,9 @@ func (w *Worker) handleCollectionImport(ctx context.Context, t *asynq.Task) erro
 	}
 
 	userIDStr := strconv.Itoa(int(payload.UserID))
+	job := startImportJob(ctx, payload, db.JobStatusFetching)
 
-	apiKey, err := db.GetPostmanAPIKey(payload.UserID)
+	apiKey, err := db.GetPostmanAPIKey(payload.Scope())
 	if err != nil {
 		errMsg := "Failed to get API key"
 
@@ -92,6 +101,8 @@ func (w *Worker) handleCollectionImport(ctx context.Context, t *asynq.Task) erro
 			Message: fmt.Sprintf("fetch collection snapshot failed '%s'", payload.Name),
 		})
 		slog.Error(errMsg, "error", err, "user_id", payload.UserID)
+		job.fail("api_key", err)
+		dispatchImportFailed(ctx, payload, "api_key", err)
 		return err
 	}
 
@@ -107,58 +118,47 @@ func (w *Worker) handleCollectionImport(ctx context.Context, t *asynq.Task) erro
 			Message: fmt.Sprintf("fetch collection snapshot failed '%s'", payload.Name),
 		})
 		slog.Error(errMsg, "error", err, "user_id", payload.UserID, "collection_id", payload.CollectionID)
+		job.fail("fetch", err)
+		dispatchImportFailed(ctx, payload, "fetch", err)
 		return err
 	}
 
 
-	maskedCollection, err := postman.MaskCollection(collection)
+	result, stage, err := storeCollectionSnapshot(payload, collection, job)
 	if err != nil {
-		errMsg := "Failed to mask sensitive data in collection"
+		title, message := "fetch collection snapshot failed", fmt.Sprintf("fetch collection snapshot data failed '%s'", payload.Name)
+		if stage == "store" {
+			title, message = "store collection snapshot failed", fmt.Sprintf("import collection failed '%s'", payload.Name)
+		}
 
 		notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
 			UserID:  userIDStr,
 			Type:    "fail",
-			Title:   "fetch collection snapshot failed",
-			Message: fmt.Sprintf("fetch collection snapshot data failed '%s'", payload.Name),
+			Title:   title,
+			Message: message,
 		})
-
-		slog.Error(errMsg, "error", err, "user_id", payload.UserID, "collection_id", payload.CollectionID)
+		slog.Error("Failed to snapshot collection", "error", err, "stage", stage, "user_id", payload.UserID, "collection_id", payload.CollectionID)
+		job.fail(stage, err)
+		dispatchImportFailed(ctx, payload, stage, err)
 		return err
 	}
+	job.complete(result)
 
-	content, err := json.Marshal(maskedCollection)
-	if err != nil {
-		errMsg := "Failed to process collection data"
-
-		notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
-			UserID:  userIDStr,
-			Type:    "fail",
-			Title:   "fetch collection snapshot failed",
-			Message: fmt.Sprintf("fetch collection snapshot data failed '%s'", payload.Name),
-		})
-		slog.Error(errMsg, "error", err, "user_id", payload.UserID, "collection_id", payload.CollectionID)
-		return err
-	}
-
-	if err := postman.StoreCollectionSnapshotWithName(payload.CollectionID, payload.Name, content, payload.UserID); err != nil {
-		errMsg := "Failed to store collection"
-
-		notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
-			UserID:  userIDStr,
-			Type:    "fail",
-			Title:   "store collection snapshot failed",
-			Message: fmt.Sprintf("import collection failed '%s'", payload.Name),
-		})
-		slog.Error(errMsg, "error", err, "user_id", payload.UserID, "collection_id", payload.CollectionID)
-		return err
+	if payload.Scheduled {
+		if err := db.MarkCollectionScheduleRun(payload.CollectionID); err != nil {
+			slog.Warn("Failed to record scheduled run", "error", err, "collection_id", payload.CollectionID)
+		}
 	}
 
 	slog.Info("Successfully processed collection import",
 		"user_id", payload.UserID,
 		"collection_id", payload.CollectionID,
 		"name", payload.Name,
+		"scheduled", payload.Scheduled,
 	)
 
+	dispatchSnapshotEvents(payload, result)
+
 	notification.NotificationServices.SendNotification(ctx, &notification.NotificationRequest{
 		UserID:  userIDStr,
 		Type:    "success",
@@ -168,3 +168,51 @@ func (w *Worker) handleCollectionImport(ctx context.Context, t *asynq.Task) erro
 
 	return nil
 }
+
+func storeCollectionSnapshot(payload queue.CollectionImportPayload, collection *postman.PostmanCollectionStructure, job *importJob) (*postman.SnapshotResult, string, error) {
+	job.enter(db.JobStatusMasking)
+	config, source, err := postman.LoadMaskingConfig(payload.CollectionID, payload.Scope())
+	if err != nil {
+		return nil, "mask", err
+	}
+
+	sealedKey, err := postman.PrepareReversibleMasking(config)
+	if err != nil {
+		return nil, "mask", err
+	}
+
+	maskedCollection, err := postman.MaskCollectionWithConfig(collection, config)
+	if err != nil {
+		return nil, "mask", err
+	}
+
+	content, err := json.Marshal(maskedCollection)
+	if err != nil {
+		return nil, "marshal", err
+	}
+
+	// A job canceled while it was fetched or masked stops before anything is stored.
+	if err := job.ctx.Err(); err != nil {
+		return nil, "mask", err
+	}
+
+	// The masking report and masked originals commit with the snapshot: restores and unmasking
+	// rely on them, so a snapshot is never stored without them.
+	report := postman.NewMaskingReport(maskedCollection, config, source)
+	recordMasking := func(tx *sqlx.Tx, snapshotID int64) error {
+		if err := postman.StoreMaskingReport(tx, payload.CollectionID, snapshotID, report); err != nil {
+			return err
+		}
+		if sealedKey == "" {
+			return nil
+		}
+		return postman.StoreMaskedOriginals(tx, snapshotID, sealedKey, maskedCollection)
+	}
+
+	result, err := postman.StoreCollectionSnapshotWithProgress(payload.CollectionID, payload.Name, content, payload.Scope(), job.enter, recordMasking)
+	if err != nil {
+		return nil, "store", err
+	}
+
+	return result, "", nil
+}
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS collection_schedules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    collection_id TEXT NOT NULL UNIQUE,
+    cron_expression TEXT,
+    interval_minutes INTEGER,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+    CHECK (cron_expression IS NOT NULL OR interval_minutes IS NOT NULL)
+);
+
+CREATE INDEX idx_collection_schedules_user_id ON collection_schedules(user_id);
+CREATE INDEX idx_collection_schedules_enabled ON collection_schedules(enabled);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS endpoint_changes;
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS endpoint_changes (
+    id SERIAL PRIMARY KEY,
+    collection_id TEXT NOT NULL,
+    old_snapshot_id INTEGER,
+    new_snapshot_id INTEGER NOT NULL,
+    event_type TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    endpoint_name TEXT NOT NULL DEFAULT '',
+    field TEXT,
+    old_value TEXT,
+    new_value TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+    FOREIGN KEY (old_snapshot_id) REFERENCES snapshots(id) ON DELETE CASCADE,
+    FOREIGN KEY (new_snapshot_id) REFERENCES snapshots(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_endpoint_changes_new_snapshot ON endpoint_changes(collection_id, new_snapshot_id);
+CREATE INDEX idx_endpoint_changes_event_type ON endpoint_changes(event_type);
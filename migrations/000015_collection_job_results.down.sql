@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS result;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS job_type;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS job_type TEXT NOT NULL DEFAULT 'import';
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS result JSONB;
@@ -0,0 +1,5 @@
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE IF EXISTS postman_api_keys DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE IF EXISTS collections DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
\ No newline at end of file
@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_postman_api_keys_key_version;
+DROP INDEX IF EXISTS idx_kms_key_rotation_active;
+
+ALTER TABLE kms_key_rotation
+    DROP CONSTRAINT IF EXISTS kms_key_rotation_status_check,
+    DROP CONSTRAINT IF EXISTS kms_key_rotation_version_key,
+    DROP COLUMN IF EXISTS completed_at,
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS failed_keys,
+    DROP COLUMN IF EXISTS reencrypted_keys,
+    DROP COLUMN IF EXISTS total_keys,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS version;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
\ No newline at end of file
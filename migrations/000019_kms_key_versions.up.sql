@@ -0,0 +1,30 @@
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE kms_key_rotation
+    ADD COLUMN version INTEGER,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active',
+    ADD COLUMN total_keys INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN reencrypted_keys INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN failed_keys INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN completed_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE kms_key_rotation k
+SET version = v.version,
+    status = CASE WHEN v.version = v.latest THEN 'active' ELSE 'retired' END,
+    completed_at = k.created_at
+FROM (
+    SELECT id,
+           ROW_NUMBER() OVER (ORDER BY id) AS version,
+           COUNT(*) OVER () AS latest
+    FROM kms_key_rotation
+) v
+WHERE k.id = v.id;
+
+ALTER TABLE kms_key_rotation
+    ALTER COLUMN version SET NOT NULL,
+    ADD CONSTRAINT kms_key_rotation_version_key UNIQUE (version),
+    ADD CONSTRAINT kms_key_rotation_status_check CHECK (status IN ('active', 'retiring', 'retired'));
+
+CREATE UNIQUE INDEX idx_kms_key_rotation_active ON kms_key_rotation(status) WHERE status = 'active';
+CREATE INDEX idx_postman_api_keys_key_version ON postman_api_keys(key_version);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS masking_policies;
\ No newline at end of file
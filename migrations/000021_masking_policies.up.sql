@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS masking_policies (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    organization_id INTEGER,
+    collection_id TEXT,
+    config JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_masking_policies_collection ON masking_policies(collection_id)
+    WHERE collection_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_masking_policies_organization_default ON masking_policies(organization_id)
+    WHERE collection_id IS NULL AND organization_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_masking_policies_user_default ON masking_policies(user_id)
+    WHERE collection_id IS NULL AND organization_id IS NULL;
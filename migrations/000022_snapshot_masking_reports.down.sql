@@ -0,0 +1 @@
+DROP TABLE IF EXISTS snapshot_masking_reports;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS snapshot_masking_reports (
+    snapshot_id INTEGER PRIMARY KEY,
+    collection_id TEXT NOT NULL,
+    masking_enabled BOOLEAN NOT NULL,
+    policy_source TEXT NOT NULL,
+    policy_id INTEGER,
+    masked_count INTEGER NOT NULL,
+    counts_by_type JSONB NOT NULL,
+    entries JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (snapshot_id) REFERENCES snapshots(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snapshot_masking_reports_collection_id ON snapshot_masking_reports(collection_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS snapshot_masked_values;
+DROP TABLE IF EXISTS snapshot_masking_keys;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS snapshot_masking_keys (
+    snapshot_id INTEGER PRIMARY KEY,
+    encrypted_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (snapshot_id) REFERENCES snapshots(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS snapshot_masked_values (
+    snapshot_id INTEGER NOT NULL,
+    path TEXT NOT NULL,
+    value_type TEXT NOT NULL,
+    encrypted_value TEXT NOT NULL,
+    PRIMARY KEY (snapshot_id, path),
+    FOREIGN KEY (snapshot_id) REFERENCES snapshot_masking_keys(snapshot_id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS environment_changes;
+DROP TABLE IF EXISTS environment_snapshots;
+DROP TABLE IF EXISTS environments;
\ No newline at end of file
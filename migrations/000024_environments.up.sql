@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS environments (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    organization_id INTEGER,
+    first_seen TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_seen TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_environments_user_id ON environments(user_id);
+CREATE INDEX idx_environments_organization_id ON environments(organization_id);
+
+CREATE TABLE IF NOT EXISTS environment_snapshots (
+    id SERIAL PRIMARY KEY,
+    environment_id TEXT NOT NULL,
+    content JSONB NOT NULL,
+    hash TEXT NOT NULL,
+    variable_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (environment_id) REFERENCES environments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_environment_snapshots_environment ON environment_snapshots(environment_id, created_at DESC);
+CREATE INDEX idx_environment_snapshots_hash ON environment_snapshots(environment_id, hash);
+
+CREATE TABLE IF NOT EXISTS environment_changes (
+    id SERIAL PRIMARY KEY,
+    environment_id TEXT NOT NULL,
+    old_snapshot_id INTEGER,
+    new_snapshot_id INTEGER NOT NULL,
+    change_type TEXT NOT NULL,
+    variable_key TEXT NOT NULL,
+    field TEXT,
+    old_value TEXT,
+    new_value TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (environment_id) REFERENCES environments(id) ON DELETE CASCADE,
+    FOREIGN KEY (old_snapshot_id) REFERENCES environment_snapshots(id) ON DELETE CASCADE,
+    FOREIGN KEY (new_snapshot_id) REFERENCES environment_snapshots(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_environment_changes_new_snapshot ON environment_changes(environment_id, new_snapshot_id);
+CREATE INDEX idx_environment_changes_created_at ON environment_changes(created_at DESC);
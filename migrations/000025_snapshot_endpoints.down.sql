@@ -0,0 +1 @@
+DROP TABLE IF EXISTS snapshot_endpoints;
\ No newline at end of file
@@ -0,0 +1,24 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE IF NOT EXISTS snapshot_endpoints (
+    id SERIAL PRIMARY KEY,
+    snapshot_id INTEGER NOT NULL,
+    collection_id TEXT NOT NULL,
+    item_path TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    header_names TEXT[] NOT NULL DEFAULT '{}',
+    body_fields TEXT[] NOT NULL DEFAULT '{}',
+    search_text TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (snapshot_id) REFERENCES snapshots(id) ON DELETE CASCADE,
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snapshot_endpoints_snapshot ON snapshot_endpoints(snapshot_id);
+CREATE INDEX idx_snapshot_endpoints_endpoint ON snapshot_endpoints(collection_id, method, path);
+CREATE INDEX idx_snapshot_endpoints_path_trgm ON snapshot_endpoints USING GIN (path gin_trgm_ops);
+CREATE INDEX idx_snapshot_endpoints_search_trgm ON snapshot_endpoints USING GIN (search_text gin_trgm_ops);
+CREATE INDEX idx_snapshot_endpoints_header_names ON snapshot_endpoints USING GIN (header_names);
+CREATE INDEX idx_snapshot_endpoints_body_fields ON snapshot_endpoints USING GIN (body_fields);
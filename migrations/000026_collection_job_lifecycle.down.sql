@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_collection_jobs_task_id;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS error_code;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS change_count;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS snapshot_id;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS stage_durations;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS finished_at;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS started_at;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS attempts;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS task_id;
\ No newline at end of file
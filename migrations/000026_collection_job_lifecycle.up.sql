@@ -0,0 +1,10 @@
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS task_id TEXT;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS stage_durations JSONB;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS snapshot_id INTEGER REFERENCES snapshots(id) ON DELETE SET NULL;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS change_count INTEGER;
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS error_code TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_collection_jobs_task_id ON collection_jobs(task_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_collection_jobs_parent_job_id;
+ALTER TABLE IF EXISTS collection_jobs DROP COLUMN IF EXISTS parent_job_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS collection_jobs ADD COLUMN IF NOT EXISTS parent_job_id INTEGER REFERENCES collection_jobs(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_collection_jobs_parent_job_id ON collection_jobs(parent_job_id);
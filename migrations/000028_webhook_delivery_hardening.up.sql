@@ -0,0 +1,9 @@
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS response_body;
+
+UPDATE webhook_deliveries
+SET error = CASE
+    WHEN status = 'skipped' THEN NULL
+    WHEN response_status IS NOT NULL THEN 'http_status'
+    ELSE 'connection_failed'
+END
+WHERE error IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webhooks_organization_id;
+ALTER TABLE IF EXISTS webhooks DROP COLUMN IF EXISTS organization_id;
\ No newline at end of file
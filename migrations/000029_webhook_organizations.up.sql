@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS webhooks ADD COLUMN IF NOT EXISTS organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_webhooks_organization_id ON webhooks(organization_id);
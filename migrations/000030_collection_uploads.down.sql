@@ -0,0 +1 @@
+DROP TABLE IF EXISTS collection_uploads;
\ No newline at end of file
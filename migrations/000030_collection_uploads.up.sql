@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS collection_uploads (
+    job_id INTEGER PRIMARY KEY,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (job_id) REFERENCES collection_jobs(id) ON DELETE CASCADE
+);
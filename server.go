@@ -5,10 +5,12 @@ import (
 	"embed"
 	"flag"
 	"fmt"
+	"integratorV2/internal/auth"
 	"integratorV2/internal/config"
 	"integratorV2/internal/db"
 	"integratorV2/internal/migrations"
 	"integratorV2/internal/notification"
+	"integratorV2/internal/postman"
 	"integratorV2/internal/queue"
 	"integratorV2/internal/routes"
 	"integratorV2/internal/security"
@@ -39,13 +41,15 @@ func LoadEnv() error {
 
 
 var (
-	migrateUp      = flag.Bool("migrate", false, "Run database migrations and exit")
-	migrateDown    = flag.Bool("migrate-down", false, "Roll back one migration and exit")
-	migrateReset   = flag.Bool("migrate-reset", false, "Reset all migrations and exit")
-	migrateForce   = flag.String("migrate-force", "", "Force database to specific version and exit")
-	migrateVersion = flag.Bool("migrate-version", false, "Show current migration version and exit")
-	migrateDrop    = flag.Bool("migrate-drop", false, "Drop entire database and exit (DANGEROUS)")
-	autoMigrate    = flag.Bool("auto-migrate", false, "Run migrations automatically on startup")
+	migrateUp        = flag.Bool("migrate", false, "Run database migrations and exit")
+	migrateDown      = flag.Bool("migrate-down", false, "Roll back one migration and exit")
+	migrateReset     = flag.Bool("migrate-reset", false, "Reset all migrations and exit")
+	migrateForce     = flag.String("migrate-force", "", "Force database to specific version and exit")
+	migrateVersion   = flag.Bool("migrate-version", false, "Show current migration version and exit")
+	migrateDrop      = flag.Bool("migrate-drop", false, "Drop entire database and exit (DANGEROUS)")
+	autoMigrate      = flag.Bool("auto-migrate", false, "Run migrations automatically on startup")
+	reindexEndpoints = flag.Bool("reindex-endpoints", false, "Index the endpoints of snapshots missing from endpoint search and exit")
+	repairChanges    = flag.Bool("repair-changes", false, "Recompute the changes of snapshots stored without them and exit")
 )
 
 func main() {
@@ -117,6 +121,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := auth.InitJWT(); err != nil {
+		slog.Error("Invalid authentication configuration", "error", err)
+		os.Exit(1)
+	}
+
 	if *autoMigrate {
 		slog.Info("Running auto-migration...")
 		if err := migrations.Up(migrationFiles); err != nil {
@@ -132,6 +141,26 @@ func main() {
 	}
 	defer db.Close()
 
+	if *reindexEndpoints {
+		indexed, err := postman.ReindexSnapshotEndpoints()
+		if err != nil {
+			slog.Error("Endpoint reindex failed", "error", err, "indexed", indexed)
+			os.Exit(1)
+		}
+		slog.Info("Endpoint reindex completed successfully", "indexed", indexed)
+		return
+	}
+
+	if *repairChanges {
+		repaired, err := postman.RepairSnapshotChanges()
+		if err != nil {
+			slog.Error("Change repair failed", "error", err, "repaired", repaired)
+			os.Exit(1)
+		}
+		slog.Info("Change repair completed successfully", "repaired", repaired)
+		return
+	}
+
 	if err := queue.InitQueue(); err != nil {
 		slog.Error("Failed to initialize task queue", "error", err)
 		os.Exit(1)
@@ -162,7 +191,7 @@ func main() {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, auth.OrganizationHeader},
 	}))
 	e.Use(security.RateLimiter)
 	e.Use(security.ValidateEmail)
@@ -183,6 +212,17 @@ func main() {
 		}
 	}()
 
+	scheduler, err := worker.NewScheduler()
+	if err != nil {
+		slog.Error("Failed to initialize collection scheduler", "error", err)
+	} else {
+		go func() {
+			if err := scheduler.Start(ctx); err != nil {
+				slog.Error("Scheduler error", "error", err)
+			}
+		}()
+	}
+
 	
 	go func() {
 		sigChan := make(chan os.Signal, 1)